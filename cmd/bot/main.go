@@ -4,21 +4,37 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"perezvonish/factorio-server-manager/internal/config"
 	"perezvonish/factorio-server-manager/internal/docker"
+	"perezvonish/factorio-server-manager/internal/domain"
+	"perezvonish/factorio-server-manager/internal/factorio/backup"
+	"perezvonish/factorio-server-manager/internal/factorio/mapgen"
 	"perezvonish/factorio-server-manager/internal/factorio/mods"
 	rconClient "perezvonish/factorio-server-manager/internal/factorio/rcon"
 	"perezvonish/factorio-server-manager/internal/factorio/saves"
 	"perezvonish/factorio-server-manager/internal/factorio/settings"
 	"perezvonish/factorio-server-manager/internal/factorio/status"
 	"perezvonish/factorio-server-manager/internal/password"
+	"perezvonish/factorio-server-manager/internal/safefile"
+	"perezvonish/factorio-server-manager/internal/storage"
 	"perezvonish/factorio-server-manager/internal/telegram"
 	"perezvonish/factorio-server-manager/internal/webapp"
 )
 
+// shutdownTimeout — сколько при остановке ждём фоновые выгрузки снапшотов; должно
+// укладываться в stop_grace_period контейнера бота.
+const shutdownTimeout = 50 * time.Second
+
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	cfg, err := config.Init()
 	if err != nil {
 		log.Fatalf("config: %v", err)
@@ -26,6 +42,21 @@ func main() {
 
 	allowedUsers := parseAllowedUsers(cfg.Telegram.AllowedUsers)
 
+	// Файл, испорченный падением посреди записи, заменяем предыдущей версией (.bak).
+	for _, file := range []string{
+		cfg.FactorioServer.ServerSettingsFile,
+		cfg.FactorioServer.MapGenSettingsFile,
+		cfg.FactorioServer.MapSettingsFile,
+		cfg.FactorioServer.ActiveSaveFile,
+		cfg.ModPortal.ModListFile,
+		cfg.ModPortal.LockFile,
+		cfg.Monitor.HistoryFile,
+	} {
+		if _, err := safefile.Recover(file, safefile.ValidJSON); err != nil {
+			log.Printf("recover: WARN %v", err)
+		}
+	}
+
 	// Generate a fresh RCON password on every startup and persist it to disk.
 	// The Factorio container reads the rconpw file when it starts.
 	pwManager := password.NewManager(cfg.FactorioServer.RconPwFile)
@@ -41,21 +72,106 @@ func main() {
 		cfg.FactorioServer.RconHost,
 		cfg.FactorioServer.RconPort,
 		pwManager,
+		rconClient.Timeouts{
+			Dial: cfg.FactorioServer.RconDialTimeout,
+			Exec: cfg.FactorioServer.RconTimeout,
+		},
 	)
 
-	dockerMgr := docker.NewManager(cfg.Docker.ContainerName)
-	saveMgr := saves.NewManager(cfg.FactorioServer.SavesDir)
-	statusChecker := status.NewChecker(cfg.FactorioServer.GameHost, cfg.FactorioServer.GamePort)
+	dockerMgr := docker.NewManager(cfg.Docker.ContainerName, cfg.Docker.SocketPath, cfg.Docker.StopTimeout)
+	backupStore, err := storage.New(storage.Options{
+		Backend:  cfg.Backup.Store,
+		LocalDir: cfg.Backup.Dir,
+		S3: storage.S3Config{
+			Endpoint:  cfg.Backup.S3.Endpoint,
+			Region:    cfg.Backup.S3.Region,
+			Bucket:    cfg.Backup.S3.Bucket,
+			Prefix:    cfg.Backup.S3.Prefix,
+			AccessKey: cfg.Backup.S3.AccessKey,
+			SecretKey: cfg.Backup.S3.SecretKey,
+		},
+		SFTP: storage.SFTPConfig{
+			Addr:           cfg.Backup.SFTP.Addr,
+			User:           cfg.Backup.SFTP.User,
+			Password:       cfg.Backup.SFTP.Password,
+			KeyFile:        cfg.Backup.SFTP.KeyFile,
+			KnownHostsFile: cfg.Backup.SFTP.KnownHostsFile,
+			Dir:            cfg.Backup.SFTP.Dir,
+		},
+	})
+	if err != nil {
+		log.Fatalf("backup store: %v", err)
+	}
+
+	// Снапшоты и так лежат на локальном диске — дублировать их имеет смысл только вовне.
+	var snapshotRemote domain.BackupStore
+	if cfg.Backup.Store != storage.BackendLocal && cfg.Backup.Store != "" {
+		snapshotRemote = backupStore
+	}
+
+	saveMgr := saves.NewManager(cfg.FactorioServer.SavesDir, cfg.FactorioServer.SnapshotsDir, snapshotRemote)
+	saveMgr.SetMaxSaveSize(int64(cfg.FactorioServer.MaxSaveSizeMB) << 20)
+	saveMgr.SetSnapshotRetention(cfg.FactorioServer.SnapshotsKeep)
+	if err := saveMgr.LoadActive(cfg.FactorioServer.ActiveSaveFile); err != nil {
+		log.Fatalf("active save: %v", err)
+	}
+	backupSchedule, err := backup.ParseSchedule(cfg.Backup.Schedule)
+	if err != nil {
+		log.Fatalf("backup schedule: %v", err)
+	}
+	backupScheduler := backup.NewScheduler(rcon, saveMgr, backup.NewStore(backupStore), backupSchedule, backup.Policy{
+		Hourly: cfg.Backup.KeepHourly,
+		Daily:  cfg.Backup.KeepDaily,
+		Weekly: cfg.Backup.KeepWeekly,
+	})
+
+	statusChecker := status.NewChecker(cfg.FactorioServer.GameHost, cfg.FactorioServer.GamePort, rcon)
+	statusHistory, err := status.LoadHistory(cfg.Monitor.HistoryFile)
+	if err != nil {
+		log.Fatalf("status history: %v", err)
+	}
 	modsMgr := mods.NewManager(
 		cfg.ModPortal.ModsDir,
 		cfg.ModPortal.ModListFile,
+		cfg.ModPortal.LockFile,
 		cfg.ModPortal.Username,
 		cfg.ModPortal.Token,
 		cfg.ModPortal.FactorioVersion,
 	)
+	modsMgr.SetDownloadWorkers(cfg.ModPortal.DownloadWorkers)
+	mapGenerator := mapgen.NewGenerator(dockerMgr, mapgen.Options{
+		Binary:             cfg.Docker.FactorioBinary,
+		MapGenSettingsFile: cfg.FactorioServer.MapGenSettingsFile,
+		MapSettingsFile:    cfg.FactorioServer.MapSettingsFile,
+		PresetsFile:        cfg.FactorioServer.MapPresetsFile,
+		SavesDir:           cfg.FactorioServer.SavesDir,
+		ModsDir:            cfg.ModPortal.ModsDir,
+	})
+
+	// Загружаемые сейвы должны открываться этой версией сервера с этим набором модов.
+	// Версию берём у запущенного сервера (или последнюю увиденную): в FACTORIO_VERSION
+	// только major.minor, а сейв 2.0.73 сервер 2.0.60 не откроет.
+	saveMgr.SetRequirements(func() (saves.Requirements, error) {
+		installed, err := modsMgr.EnabledModVersions()
+		if err != nil {
+			return saves.Requirements{}, err
+		}
+		version := statusChecker.ServerVersion(context.Background())
+		if version == "" {
+			version = cfg.ModPortal.FactorioVersion
+		}
+		return saves.Requirements{
+			FactorioVersion: version,
+			Mods:            installed,
+		}, nil
+	})
 
 	// Start the WebApp HTTP server immediately so /health responds during SyncMods.
-	webAppSrv := webapp.NewServer(cfg.Telegram.BotToken, allowedUsers, saveMgr)
+	downloadLinks := webapp.NewDownloadLinks(cfg.Telegram.BotToken, cfg.WebApp.URL, cfg.WebApp.DownloadTTL)
+	if err := downloadLinks.LoadUsed(cfg.WebApp.DownloadUsedFile); err != nil {
+		log.Fatalf("download links: %v", err)
+	}
+	webAppSrv := webapp.NewServer(cfg.Telegram.BotToken, allowedUsers, saveMgr, cfg.WebApp.UploadDir, downloadLinks, mapGenerator)
 	go func() {
 		if err := webAppSrv.ListenAndServe(":" + cfg.WebApp.Port); err != nil {
 			log.Fatalf("webapp server: %v", err)
@@ -66,7 +182,7 @@ func main() {
 	// The Factorio container waits for /health (condition: service_healthy),
 	// which only returns 200 after SetReady() — i.e. after SyncMods finishes.
 	log.Println("mods: синхронизация при старте...")
-	count, failures, err := modsMgr.SyncMods(context.Background())
+	count, failures, err := modsMgr.SyncMods(context.Background(), nil)
 	if err != nil {
 		log.Printf("mods: WARN ошибка при старте: %v", err)
 	} else {
@@ -87,15 +203,36 @@ func main() {
 		Container:    dockerMgr,
 		Saves:        saveMgr,
 		Status:       statusChecker,
+		History:      statusHistory,
+		Backups:      backupScheduler,
 		PasswordMgr:  pwManager,
 		Mods:         modsMgr,
 		WebAppURL:    cfg.WebApp.URL,
+		Downloads:    downloadLinks,
+		MapGen:       mapGenerator,
+		Settings:     settings.NewManager(cfg.FactorioServer.ServerSettingsFile),
+
+		ModsUpdateOnlyWhenEmpty: cfg.ModPortal.UpdateOnlyWhenEmpty,
 	})
 	if err != nil {
 		log.Fatalf("telegram bot: %v", err)
 	}
 
-	bot.Start()
+	// Фоновый мониторинг: пишет историю и оповещает о падениях/восстановлении.
+	monitor := status.NewMonitor(statusChecker, statusHistory, cfg.Monitor.Interval, cfg.Monitor.FailThreshold)
+	monitor.OnChange(bot.NotifyStatusChange)
+	monitor.OnCheck(bot.OnStatusCheck) // отложенный /mods update при MODS_UPDATE_ONLY_WHEN_EMPTY
+	go monitor.Run(ctx)
+	go backupScheduler.Run(ctx)
+
+	// Start не возвращается сам: long polling держит запрос до 60 секунд.
+	go bot.Start()
+	<-ctx.Done()
+
+	log.Println("остановка: жду завершения выгрузки снапшотов...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	saveMgr.Shutdown(shutdownCtx)
 }
 
 func parseAllowedUsers(s string) map[int64]struct{} {
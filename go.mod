@@ -5,4 +5,11 @@ go 1.25.5
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/gorcon/rcon v1.4.0
+	github.com/pkg/sftp v1.13.10
+	golang.org/x/crypto v0.45.0
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
 )
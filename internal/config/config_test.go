@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInitDefaults(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "token")
+
+	cfg, err := Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Telegram.BotToken != "token" {
+		t.Errorf("BotToken = %q", cfg.Telegram.BotToken)
+	}
+	if cfg.FactorioServer.RconPort != "27015" || cfg.FactorioServer.MaxSaveSizeMB != 500 {
+		t.Errorf("FactorioServer = %+v", cfg.FactorioServer)
+	}
+	if cfg.FactorioServer.RconTimeout != 10*time.Second {
+		t.Errorf("RconTimeout = %s", cfg.FactorioServer.RconTimeout)
+	}
+	if cfg.ModPortal.UpdateOnlyWhenEmpty {
+		t.Error("UpdateOnlyWhenEmpty is on by default")
+	}
+}
+
+func TestInitFromEnv(t *testing.T) {
+	t.Setenv("TELEGRAM_BOT_TOKEN", "token")
+	t.Setenv("MODS_UPDATE_ONLY_WHEN_EMPTY", "true")
+	t.Setenv("FACTORIO_MAX_SAVE_SIZE_MB", "64")
+	t.Setenv("STATUS_CHECK_INTERVAL", "30s")
+
+	cfg, err := Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.ModPortal.UpdateOnlyWhenEmpty || cfg.FactorioServer.MaxSaveSizeMB != 64 || cfg.Monitor.Interval != 30*time.Second {
+		t.Errorf("config ignores the environment: %+v %+v %+v", cfg.ModPortal, cfg.FactorioServer, cfg.Monitor)
+	}
+}
+
+func TestInitErrors(t *testing.T) {
+	t.Run("required", func(t *testing.T) {
+		t.Setenv("TELEGRAM_BOT_TOKEN", "")
+		var required FieldRequiredError
+		if _, err := Init(); !errors.As(err, &required) {
+			t.Errorf("err = %v, want FieldRequiredError", err)
+		}
+	})
+	for env, value := range map[string]string{
+		"MODS_UPDATE_ONLY_WHEN_EMPTY": "maybe",
+		"FACTORIO_MAX_SAVE_SIZE_MB":   "lots",
+		"STATUS_CHECK_INTERVAL":       "soon",
+	} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv("TELEGRAM_BOT_TOKEN", "token")
+			t.Setenv(env, value)
+			if _, err := Init(); err == nil {
+				t.Errorf("%s=%s accepted", env, value)
+			}
+		})
+	}
+}
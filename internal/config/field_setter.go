@@ -4,9 +4,25 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"time"
 )
 
+var durationType = reflect.TypeOf(time.Duration(0))
+
 func setField(field reflect.Value, value string, envName string) error {
+	// time.Duration is an int64 under the hood, so it must be matched before the kind switch.
+	if field.Type() == durationType {
+		if value == "" {
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration value for %s: %v", envName, err)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
 	switch field.Kind() {
 	case reflect.String:
 		field.SetString(value)
@@ -21,6 +37,16 @@ func setField(field reflect.Value, value string, envName string) error {
 		}
 		field.SetInt(intVal)
 
+	case reflect.Bool:
+		if value == "" {
+			return nil
+		}
+		boolVal, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid bool value for %s: %v", envName, err)
+		}
+		field.SetBool(boolVal)
+
 	default:
 		return fmt.Errorf("unsupported type %s for field %s", field.Kind(), envName)
 	}
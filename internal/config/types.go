@@ -1,11 +1,15 @@
 package config
 
+import "time"
+
 type Config struct {
 	Telegram       TelegramConfig
 	FactorioServer FactorioServerConfig
 	Docker         DockerConfig
 	WebApp         WebAppConfig
 	ModPortal      ModPortalConfig
+	Monitor        MonitorConfig
+	Backup         BackupConfig
 }
 
 type TelegramConfig struct {
@@ -19,12 +23,36 @@ type FactorioServerConfig struct {
 	GameHost           string `env:"FACTORIO_GAME_HOST" envDefault:"factorio"`
 	GamePort           string `env:"FACTORIO_GAME_PORT" envDefault:"34197"`
 	SavesDir           string `env:"FACTORIO_SAVES_DIR" envDefault:"/factorio/saves"`
+	SnapshotsDir       string `env:"FACTORIO_SNAPSHOTS_DIR" envDefault:"/factorio/snapshots"`
 	RconPwFile         string `env:"FACTORIO_RCON_PW_FILE" envDefault:"/factorio/config/rconpw"`
 	ServerSettingsFile string `env:"FACTORIO_SERVER_SETTINGS_FILE" envDefault:"/factorio/config/server-settings.json"`
+
+	// SnapshotsKeep — сколько последних снапшотов хранить (локально и в хранилище); 0 — все.
+	SnapshotsKeep int `env:"FACTORIO_SNAPSHOTS_KEEP" envDefault:"30"`
+
+	// RconDialTimeout — таймаут подключения и авторизации RCON.
+	RconDialTimeout time.Duration `env:"RCON_DIAL_TIMEOUT" envDefault:"5s"`
+	// RconTimeout — таймаут выполнения одной RCON-команды по умолчанию.
+	RconTimeout time.Duration `env:"RCON_TIMEOUT" envDefault:"10s"`
+	// MaxSaveSizeMB — максимальный размер загружаемого сейва.
+	MaxSaveSizeMB int `env:"FACTORIO_MAX_SAVE_SIZE_MB" envDefault:"500"`
+	// ActiveSaveFile — какой сейв загружать при /startServer и /restart.
+	ActiveSaveFile string `env:"FACTORIO_ACTIVE_SAVE_FILE" envDefault:"/factorio/bot/active-save.json"`
+
+	// Настройки генерации карты для /newMap. Пути должны совпадать в контейнерах бота и сервера.
+	MapGenSettingsFile string `env:"FACTORIO_MAP_GEN_SETTINGS_FILE" envDefault:"/factorio/config/map-gen-settings.json"`
+	MapSettingsFile    string `env:"FACTORIO_MAP_SETTINGS_FILE" envDefault:"/factorio/config/map-settings.json"`
+	MapPresetsFile     string `env:"FACTORIO_MAP_PRESETS_FILE" envDefault:"/factorio/config/map-presets.json"`
 }
 
 type DockerConfig struct {
 	ContainerName string `env:"DOCKER_CONTAINER_NAME" envDefault:"factorio"`
+	// SocketPath — Unix-сокет Docker Engine API, проброшенный в контейнер бота.
+	SocketPath string `env:"DOCKER_SOCKET" envDefault:"/var/run/docker.sock"`
+	// StopTimeout — сколько Docker ждёт после SIGTERM, прежде чем убить контейнер.
+	StopTimeout time.Duration `env:"DOCKER_STOP_TIMEOUT" envDefault:"60s"`
+	// FactorioBinary — путь к factorio внутри образа сервера (для --create).
+	FactorioBinary string `env:"DOCKER_FACTORIO_BINARY" envDefault:"/opt/factorio/bin/x64/factorio"`
 }
 
 // WebAppConfig configures the built-in HTTP server for the save-upload Telegram WebApp.
@@ -35,6 +63,12 @@ type WebAppConfig struct {
 	// Должен быть HTTPS, например: https://example.com:8080
 	// Если не задан, /uploadSave шлёт текстовую инструкцию вместо кнопки.
 	URL string `env:"WEBAPP_URL" envDefault:""`
+	// UploadDir — куда складываются куски докачиваемых загрузок до их завершения.
+	UploadDir string `env:"WEBAPP_UPLOAD_DIR" envDefault:"/factorio/bot/uploads"`
+	// DownloadTTL — срок действия одноразовой ссылки на скачивание сейва.
+	DownloadTTL time.Duration `env:"WEBAPP_DOWNLOAD_TTL" envDefault:"15m"`
+	// DownloadUsedFile — использованные одноразовые ссылки, чтобы они не оживали после рестарта бота.
+	DownloadUsedFile string `env:"WEBAPP_DOWNLOAD_USED_FILE" envDefault:"/factorio/bot/download-links-used.json"`
 }
 
 type ModPortalConfig struct {
@@ -43,4 +77,59 @@ type ModPortalConfig struct {
 	FactorioVersion string `env:"FACTORIO_VERSION" envDefault:"2.0"`
 	ModsDir         string `env:"FACTORIO_MODS_DIR" envDefault:"/factorio/mods"`
 	ModListFile     string `env:"FACTORIO_MOD_LIST_FILE" envDefault:"/factorio/mods/mod-list.json"`
+	// LockFile — точные версии и SHA-1 установленных модов; папка модов приводится к нему.
+	LockFile string `env:"FACTORIO_MOD_LOCK_FILE" envDefault:"/factorio/mods/mod-lock.json"`
+	// UpdateOnlyWhenEmpty — /mods update ждёт в очереди, пока на сервере есть игроки.
+	UpdateOnlyWhenEmpty bool `env:"MODS_UPDATE_ONLY_WHEN_EMPTY" envDefault:"false"`
+	// DownloadWorkers — сколько модов скачивается одновременно.
+	DownloadWorkers int `env:"FACTORIO_MOD_DOWNLOAD_WORKERS" envDefault:"4"`
+}
+
+// MonitorConfig configures the background status monitor and outage alerts.
+type MonitorConfig struct {
+	// Interval — период фоновой проверки сервера.
+	Interval time.Duration `env:"STATUS_CHECK_INTERVAL" envDefault:"1m"`
+	// FailThreshold — сколько проверок подряд должны совпасть, чтобы сменить состояние (защита от флаппинга).
+	FailThreshold int `env:"STATUS_FAIL_THRESHOLD" envDefault:"2"`
+	// HistoryFile — JSON-файл с историей переходов online/offline.
+	HistoryFile string `env:"STATUS_HISTORY_FILE" envDefault:"/factorio/bot/status-history.json"`
+}
+
+// BackupConfig configures scheduled backups of the running game.
+type BackupConfig struct {
+	Dir string `env:"BACKUP_DIR" envDefault:"/factorio/backups"`
+	// Schedule — cron-выражение (минута час день месяц день_недели) или @hourly/@daily/@weekly.
+	// off отключает автоматические бэкапы, /backup продолжает работать. Пустое значение
+	// не подходит: загрузчик конфига подставляет вместо него envDefault.
+	Schedule   string `env:"BACKUP_SCHEDULE" envDefault:"0 * * * *"`
+	KeepHourly int    `env:"BACKUP_KEEP_HOURLY" envDefault:"24"`
+	KeepDaily  int    `env:"BACKUP_KEEP_DAILY" envDefault:"7"`
+	KeepWeekly int    `env:"BACKUP_KEEP_WEEKLY" envDefault:"4"`
+
+	// Store — где хранятся бэкапы: local (BACKUP_DIR), s3 или sftp.
+	// Для s3/sftp туда же выгружаются снапшоты сейвов.
+	Store string `env:"BACKUP_STORE" envDefault:"local"`
+	S3    S3Config
+	SFTP  SFTPConfig
+}
+
+// S3Config configures an S3-compatible backup store (AWS S3, MinIO, …).
+type S3Config struct {
+	Endpoint  string `env:"BACKUP_S3_ENDPOINT" envDefault:""`
+	Region    string `env:"BACKUP_S3_REGION" envDefault:"us-east-1"`
+	Bucket    string `env:"BACKUP_S3_BUCKET" envDefault:""`
+	Prefix    string `env:"BACKUP_S3_PREFIX" envDefault:""`
+	AccessKey string `env:"BACKUP_S3_ACCESS_KEY" envDefault:""`
+	SecretKey string `env:"BACKUP_S3_SECRET_KEY" envDefault:""`
+}
+
+// SFTPConfig configures an SFTP backup store.
+type SFTPConfig struct {
+	Addr     string `env:"BACKUP_SFTP_ADDR" envDefault:""`
+	User     string `env:"BACKUP_SFTP_USER" envDefault:""`
+	Password string `env:"BACKUP_SFTP_PASSWORD" envDefault:""`
+	KeyFile  string `env:"BACKUP_SFTP_KEY_FILE" envDefault:""`
+	// KnownHostsFile — обязателен: ключ сервера проверяется всегда.
+	KnownHostsFile string `env:"BACKUP_SFTP_KNOWN_HOSTS" envDefault:""`
+	Dir            string `env:"BACKUP_SFTP_DIR" envDefault:"."`
 }
@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// apiVersion is the Engine API version requested on every call.
+// 1.41 is served by Docker 20.10 and every newer daemon.
+const apiVersion = "v1.41"
+
+// client is a minimal Docker Engine API client speaking HTTP over the Unix socket.
+type client struct {
+	http *http.Client
+}
+
+func newClient(socketPath string) *client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	// Без общего таймаута: stop ждёт grace period, logs -f живёт долго — всё ограничивает ctx.
+	return &client{http: &http.Client{Transport: transport}}
+}
+
+// do sends a request to the Engine API. On a 2xx status (or one of okStatuses) the
+// response is returned with the body still open; otherwise it is converted to *APIError.
+func (c *client) do(ctx context.Context, op, method, path string, query url.Values, okStatuses ...int) (*http.Response, error) {
+	return c.doBody(ctx, op, method, path, query, nil, okStatuses...)
+}
+
+// doBody is do with a JSON-encoded request body (nil for none).
+func (c *client) doBody(ctx context.Context, op, method, path string, query url.Values, in any, okStatuses ...int) (*http.Response, error) {
+	u := "http://docker/" + apiVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, &TransportError{Op: op, Err: err}
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, &TransportError{Op: op, Err: err}
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, &TransportError{Op: op, Err: err}
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	for _, s := range okStatuses {
+		if resp.StatusCode == s {
+			return resp, nil
+		}
+	}
+
+	defer resp.Body.Close()
+	return nil, &APIError{Op: op, StatusCode: resp.StatusCode, Message: readErrorMessage(resp.Body)}
+}
+
+// doJSON performs a request and decodes the JSON response body into out.
+func (c *client) doJSON(ctx context.Context, op, method, path string, query url.Values, out any) error {
+	return c.doJSONBody(ctx, op, method, path, query, nil, out)
+}
+
+// doJSONBody sends in as the JSON request body and decodes the JSON response into out.
+func (c *client) doJSONBody(ctx context.Context, op, method, path string, query url.Values, in, out any) error {
+	resp, err := c.doBody(ctx, op, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return &TransportError{Op: op, Err: err}
+	}
+	return nil
+}
+
+// doDiscard performs a request and drains the response body.
+func (c *client) doDiscard(ctx context.Context, op, method, path string, query url.Values, okStatuses ...int) error {
+	resp, err := c.do(ctx, op, method, path, query, okStatuses...)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck
+	resp.Body.Close()
+	return nil
+}
+
+// readErrorMessage extracts {"message": "..."} from an Engine API error body.
+func readErrorMessage(r io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(r, 64<<10))
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Message != "" {
+		return body.Message
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func containerPath(name, action string) string {
+	p := "/containers/" + url.PathEscape(name)
+	if action != "" {
+		p += "/" + action
+	}
+	return p
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrContainerNotFound is returned when the Engine API reports 404 for the container.
+var ErrContainerNotFound = errors.New("container not found")
+
+// APIError is a non-2xx response from the Docker Engine API.
+type APIError struct {
+	Op         string
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker %s: %d %s", e.Op, e.StatusCode, e.Message)
+}
+
+// Is lets errors.Is(err, ErrContainerNotFound) match 404 responses.
+func (e *APIError) Is(target error) bool {
+	return target == ErrContainerNotFound && e.StatusCode == 404
+}
+
+// TransportError is returned when the Docker socket cannot be reached at all
+// (daemon down, socket not mounted, permission denied).
+type TransportError struct {
+	Op  string
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("docker %s: %v", e.Op, e.Err)
+}
+
+func (e *TransportError) Unwrap() error { return e.Err }
+
+// ExitError is returned by RunOnce when the command exits with a non-zero code.
+type ExitError struct {
+	Code   int
+	Output []string
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("command exited with code %d", e.Code)
+	if n := len(e.Output); n > 0 {
+		msg += ": " + e.Output[n-1]
+	}
+	return msg
+}
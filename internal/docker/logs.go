@@ -0,0 +1,141 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxLogLine bounds a single log line; Factorio occasionally prints long mod lists.
+const maxLogLine = 1 << 20
+
+// Logs returns the last tail lines of the container output (stdout and stderr).
+func (m *Manager) Logs(ctx context.Context, tail int) ([]string, error) {
+	var lines []string
+	err := m.streamLogs(ctx, url.Values{"tail": {strconv.Itoa(tail)}}, func(line string) {
+		lines = append(lines, line)
+	})
+	return lines, err
+}
+
+// FollowLogs streams the container output line by line, starting with the last tail lines,
+// until ctx is cancelled or the container stops. Cancellation is not reported as an error.
+func (m *Manager) FollowLogs(ctx context.Context, tail int, fn func(line string)) error {
+	err := m.streamLogs(ctx, url.Values{"tail": {strconv.Itoa(tail)}, "follow": {"1"}}, fn)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (m *Manager) streamLogs(ctx context.Context, query url.Values, fn func(line string)) error {
+	info, err := m.Inspect(ctx)
+	if err != nil {
+		return err
+	}
+	return m.containerLogs(ctx, m.containerName, info.Config.Tty, query, fn)
+}
+
+// containerLogs streams the output of any container, not only the managed one.
+func (m *Manager) containerLogs(ctx context.Context, container string, tty bool, query url.Values, fn func(line string)) error {
+	op := "logs " + container
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+	resp, err := m.api.do(ctx, op, http.MethodGet, containerPath(container, "logs"), query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Без TTY Docker мультиплексирует stdout/stderr с 8-байтовыми заголовками кадров.
+	var r io.Reader = resp.Body
+	if !tty {
+		r = &demuxReader{r: resp.Body}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLogLine)
+	for scanner.Scan() {
+		fn(strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return &TransportError{Op: op, Err: err}
+	}
+	return nil
+}
+
+// demuxReader strips the stdcopy frame headers ([stream, 0, 0, 0, size uint32 BE])
+// from a non-TTY log stream, merging stdout and stderr.
+type demuxReader struct {
+	r         io.Reader
+	remaining uint32
+}
+
+func (d *demuxReader) Read(p []byte) (int, error) {
+	for d.remaining == 0 {
+		var hdr [8]byte
+		if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		d.remaining = binary.BigEndian.Uint32(hdr[4:])
+	}
+
+	if uint32(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.r.Read(p)
+	d.remaining -= uint32(n)
+	return n, err
+}
+
+// LogLevel selects Factorio log lines by severity.
+type LogLevel string
+
+const (
+	LogLevelAll     LogLevel = ""
+	LogLevelWarning LogLevel = "warning" // Warning и Error
+	LogLevelError   LogLevel = "error"
+)
+
+// FilterLogLevel keeps lines at or above the given level. Factorio prefixes
+// messages with the uptime and severity, e.g. "  12.345 Error ServerMultiplayerManager.cpp:…".
+func FilterLogLevel(lines []string, level LogLevel) []string {
+	if level == LogLevelAll {
+		return lines
+	}
+	var out []string
+	for _, line := range lines {
+		switch logLineLevel(line) {
+		case LogLevelError:
+			out = append(out, line)
+		case LogLevelWarning:
+			if level == LogLevelWarning {
+				out = append(out, line)
+			}
+		}
+	}
+	return out
+}
+
+func logLineLevel(line string) LogLevel {
+	fields := strings.Fields(line)
+	// Первое поле — время с начала работы сервера, второе — уровень.
+	for i := 0; i < len(fields) && i < 3; i++ {
+		switch fields[i] {
+		case "Error":
+			return LogLevelError
+		case "Warning":
+			return LogLevelWarning
+		}
+	}
+	return LogLevelAll
+}
@@ -2,36 +2,120 @@ package docker
 
 import (
 	"context"
-	"fmt"
-	"os/exec"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/domain"
 )
 
-// Manager implements domain.ContainerManager using the Docker CLI
-// Requires that the bot process has access to the Docker socket.
+// Manager implements domain.ContainerManager by talking to the Docker Engine API
+// over the Unix socket. Requires that the socket is mounted into the bot container.
 type Manager struct {
 	containerName string
+	stopTimeout   time.Duration
+	api           *client
+}
+
+// NewManager creates a Manager for the given container. stopTimeout is the grace period
+// Docker waits after SIGTERM before killing the container.
+func NewManager(containerName, socketPath string, stopTimeout time.Duration) *Manager {
+	return &Manager{
+		containerName: containerName,
+		stopTimeout:   stopTimeout,
+		api:           newClient(socketPath),
+	}
+}
+
+// ContainerInfo is the subset of `docker inspect` the bot cares about.
+type ContainerInfo struct {
+	ID           string         `json:"Id"`
+	Name         string         `json:"Name"`
+	RestartCount int            `json:"RestartCount"`
+	State        ContainerState `json:"State"`
+	Config       struct {
+		Image string   `json:"Image"`
+		Env   []string `json:"Env"`
+		Tty   bool     `json:"Tty"`
+	} `json:"Config"`
 }
 
-func NewManager(containerName string) *Manager {
-	return &Manager{containerName: containerName}
+// ContainerState mirrors the State object of the inspect response.
+type ContainerState struct {
+	Status     string       `json:"Status"` // created, running, paused, restarting, removing, exited, dead
+	Running    bool         `json:"Running"`
+	Restarting bool         `json:"Restarting"`
+	OOMKilled  bool         `json:"OOMKilled"`
+	Dead       bool         `json:"Dead"`
+	ExitCode   int          `json:"ExitCode"`
+	Error      string       `json:"Error"`
+	StartedAt  time.Time    `json:"StartedAt"`
+	FinishedAt time.Time    `json:"FinishedAt"`
+	Health     *HealthState `json:"Health,omitempty"`
 }
 
-// Start starts the Docker container running the Factorio server
+// HealthState is present only when the container defines a HEALTHCHECK.
+type HealthState struct {
+	Status        string `json:"Status"` // starting, healthy, unhealthy
+	FailingStreak int    `json:"FailingStreak"`
+}
+
+// Start starts the Docker container running the Factorio server.
+// Starting an already running container is not an error.
 func (m *Manager) Start(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "docker", "start", m.containerName)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("docker start %s: %w\n%s", m.containerName, err, string(out))
-	}
-	return nil
+	return m.api.doDiscard(ctx, "start "+m.containerName, http.MethodPost,
+		containerPath(m.containerName, "start"), nil, http.StatusNotModified)
 }
 
-// Stop stops the Docker container running the Factorio server
+// Stop stops the Docker container, giving Factorio the grace period to write its save.
+// Stopping an already stopped container is not an error.
 func (m *Manager) Stop(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "docker", "stop", m.containerName)
-	out, err := cmd.CombinedOutput()
+	return m.api.doDiscard(ctx, "stop "+m.containerName, http.MethodPost,
+		containerPath(m.containerName, "stop"), m.timeoutQuery(), http.StatusNotModified)
+}
+
+// Restart stops (with the grace period) and starts the container in one API call.
+func (m *Manager) Restart(ctx context.Context) error {
+	return m.api.doDiscard(ctx, "restart "+m.containerName, http.MethodPost,
+		containerPath(m.containerName, "restart"), m.timeoutQuery())
+}
+
+// Inspect returns the current state of the container.
+func (m *Manager) Inspect(ctx context.Context) (*ContainerInfo, error) {
+	var info ContainerInfo
+	if err := m.api.doJSON(ctx, "inspect "+m.containerName, http.MethodGet,
+		containerPath(m.containerName, "json"), nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Status implements domain.ContainerManager on top of Inspect.
+func (m *Manager) Status(ctx context.Context) (domain.ContainerStatus, error) {
+	info, err := m.Inspect(ctx)
 	if err != nil {
-		return fmt.Errorf("docker stop %s: %w\n%s", m.containerName, err, string(out))
+		return domain.ContainerStatus{}, err
+	}
+
+	st := domain.ContainerStatus{
+		State:        domain.ContainerState(info.State.Status),
+		ExitCode:     info.State.ExitCode,
+		Error:        info.State.Error,
+		OOMKilled:    info.State.OOMKilled,
+		RestartCount: info.RestartCount,
+		StartedAt:    info.State.StartedAt,
+		FinishedAt:   info.State.FinishedAt,
+	}
+	if info.State.Health != nil {
+		st.Health = info.State.Health.Status
+	}
+	return st, nil
+}
+
+func (m *Manager) timeoutQuery() url.Values {
+	if m.stopTimeout <= 0 {
+		return nil
 	}
-	return nil
+	return url.Values{"t": {strconv.Itoa(int(m.stopTimeout / time.Second))}}
 }
@@ -0,0 +1,227 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+// fakeDaemon serves the Engine API endpoints the Manager uses on a Unix socket.
+type fakeDaemon struct {
+	mu       sync.Mutex
+	running  bool
+	tty      bool
+	logs     []byte
+	requests []string // "METHOD path?query"
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.requests = append(d.requests, r.Method+" "+r.URL.RequestURI())
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/"+apiVersion+"/containers/")
+	if !ok {
+		http.Error(w, `{"message":"page not found"}`, http.StatusNotFound)
+		return
+	}
+	name, action, _ := strings.Cut(path, "/")
+	switch name {
+	case "factorio":
+	case "broken":
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message":"daemon exploded"}`))
+		return
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"No such container: ` + name + `"}`))
+		return
+	}
+
+	switch action {
+	case "start":
+		if d.running {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		d.running = true
+		w.WriteHeader(http.StatusNoContent)
+	case "stop":
+		if !d.running {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		d.running = false
+		w.WriteHeader(http.StatusNoContent)
+	case "json":
+		status := "exited"
+		if d.running {
+			status = "running"
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"Id":           "abc123",
+			"Name":         "/factorio",
+			"RestartCount": 2,
+			"State": map[string]any{
+				"Status":    status,
+				"Running":   d.running,
+				"ExitCode":  0,
+				"StartedAt": "2026-10-16T12:00:00Z",
+				"Health":    map[string]any{"Status": "healthy"},
+			},
+			"Config": map[string]any{"Image": "factoriotools/factorio", "Tty": d.tty},
+		})
+	case "logs":
+		w.Write(d.logs)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (d *fakeDaemon) lastRequest() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.requests[len(d.requests)-1]
+}
+
+// startFakeDaemon listens on a Unix socket and returns its path.
+func startFakeDaemon(t *testing.T, d *fakeDaemon) string {
+	t.Helper()
+	// Путь к сокету ограничен ~100 байтами, t.TempDir() бывает длиннее.
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(d)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return socket
+}
+
+func TestManagerStartStopInspect(t *testing.T) {
+	d := &fakeDaemon{}
+	m := NewManager("factorio", startFakeDaemon(t, d), 30*time.Second)
+	ctx := context.Background()
+
+	if err := m.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(ctx); err != nil {
+		t.Errorf("starting a running container: %v", err)
+	}
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != domain.ContainerRunning || st.Health != "healthy" || st.RestartCount != 2 {
+		t.Errorf("status = %+v", st)
+	}
+	if want := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC); !st.StartedAt.Equal(want) {
+		t.Errorf("StartedAt = %s, want %s", st.StartedAt, want)
+	}
+
+	if err := m.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := d.lastRequest(), "POST /"+apiVersion+"/containers/factorio/stop?t=30"; got != want {
+		t.Errorf("stop request = %q, want %q", got, want)
+	}
+	if err := m.Stop(ctx); err != nil {
+		t.Errorf("stopping a stopped container: %v", err)
+	}
+}
+
+func TestManagerErrors(t *testing.T) {
+	socket := startFakeDaemon(t, &fakeDaemon{})
+	ctx := context.Background()
+
+	_, err := NewManager("missing", socket, 0).Inspect(ctx)
+	var apiErr *APIError
+	if !errors.Is(err, ErrContainerNotFound) || !errors.As(err, &apiErr) {
+		t.Fatalf("inspect of a missing container: %v, want ErrContainerNotFound", err)
+	}
+	if apiErr.Message != "No such container: missing" {
+		t.Errorf("message = %q", apiErr.Message)
+	}
+
+	err = NewManager("broken", socket, 0).Start(ctx)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || errors.Is(err, ErrContainerNotFound) {
+		t.Errorf("daemon error: %v, want a 500 *APIError", err)
+	}
+	if apiErr.Message != "daemon exploded" {
+		t.Errorf("message = %q", apiErr.Message)
+	}
+
+	err = NewManager("factorio", filepath.Join(t.TempDir(), "no.sock"), 0).Start(ctx)
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Errorf("unreachable socket: %v, want *TransportError", err)
+	}
+}
+
+// frame wraps p in a stdcopy header for stream 1 (stdout) or 2 (stderr).
+func frame(stream byte, p string) []byte {
+	hdr := make([]byte, 8, 8+len(p))
+	hdr[0] = stream
+	binary.BigEndian.PutUint32(hdr[4:], uint32(len(p)))
+	return append(hdr, p...)
+}
+
+func TestManagerLogsDemux(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(frame(1, "   0.001 Info starting\n   1.500 Err"))
+	stream.Write(frame(2, "or broken mod\r\n"))
+	stream.Write(frame(1, "   2.000 Warning slow tick\n"))
+	d := &fakeDaemon{logs: stream.Bytes()}
+	m := NewManager("factorio", startFakeDaemon(t, d), 0)
+
+	lines, err := m.Logs(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"   0.001 Info starting", "   1.500 Error broken mod", "   2.000 Warning slow tick"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+	if !strings.Contains(d.lastRequest(), "tail=10") {
+		t.Errorf("logs request %q has no tail", d.lastRequest())
+	}
+	if got := FilterLogLevel(lines, LogLevelError); len(got) != 1 || got[0] != want[1] {
+		t.Errorf("error lines = %q", got)
+	}
+}
+
+func TestManagerLogsTTY(t *testing.T) {
+	d := &fakeDaemon{tty: true, logs: []byte("raw line\nsecond\n")}
+	m := NewManager("factorio", startFakeDaemon(t, d), 0)
+
+	lines, err := m.Logs(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, "|") != "raw line|second" {
+		t.Errorf("lines = %q", lines)
+	}
+}
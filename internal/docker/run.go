@@ -0,0 +1,118 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultRunUser — uid:gid, под которым factoriotools/factorio запускает сервер
+// (переопределяется PUID/PGID). Файлы, созданные разовой командой, должны быть ему доступны.
+const defaultRunUser = "845:845"
+
+type createRequest struct {
+	Image      string     `json:"Image"`
+	Entrypoint []string   `json:"Entrypoint"`
+	Cmd        []string   `json:"Cmd"`
+	User       string     `json:"User,omitempty"`
+	Env        []string   `json:"Env,omitempty"`
+	HostConfig hostConfig `json:"HostConfig"`
+}
+
+type hostConfig struct {
+	VolumesFrom []string `json:"VolumesFrom"`
+}
+
+type waitResponse struct {
+	StatusCode int `json:"StatusCode"`
+	Error      *struct {
+		Message string `json:"Message"`
+	} `json:"Error"`
+}
+
+// RunOnce implements domain.CommandRunner: it creates a container from the managed
+// container's image with the same volumes (VolumesFrom) and environment, runs cmd in
+// it, waits for it to exit and removes it. The output is returned in any case; a
+// non-zero exit code is reported as *ExitError.
+func (m *Manager) RunOnce(ctx context.Context, cmd []string) ([]string, error) {
+	if len(cmd) == 0 {
+		return nil, errors.New("docker run: empty command")
+	}
+	info, err := m.Inspect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimPrefix(info.Name, "/") + "-oneshot"
+	// Остаток прошлого запуска (бот упал посреди команды) мешает создать контейнер с тем же именем.
+	m.removeContainer(ctx, name)
+
+	var created struct {
+		ID string `json:"Id"`
+	}
+	req := createRequest{
+		Image:      info.Config.Image,
+		Entrypoint: cmd[:1],
+		Cmd:        cmd[1:],
+		User:       runUser(info.Config.Env),
+		Env:        info.Config.Env,
+		HostConfig: hostConfig{VolumesFrom: []string{m.containerName}},
+	}
+	if err := m.api.doJSONBody(ctx, "create "+name, http.MethodPost, "/containers/create",
+		url.Values{"name": {name}}, req, &created); err != nil {
+		return nil, err
+	}
+	defer m.removeContainer(context.Background(), created.ID)
+
+	if err := m.api.doDiscard(ctx, "start "+name, http.MethodPost, containerPath(created.ID, "start"), nil); err != nil {
+		return nil, err
+	}
+
+	var wait waitResponse
+	if err := m.api.doJSON(ctx, "wait "+name, http.MethodPost, containerPath(created.ID, "wait"), nil, &wait); err != nil {
+		return nil, err
+	}
+
+	var output []string
+	if err := m.containerLogs(ctx, created.ID, false, url.Values{}, func(line string) {
+		output = append(output, line)
+	}); err != nil {
+		return nil, err
+	}
+
+	if wait.Error != nil && wait.Error.Message != "" {
+		return output, &APIError{Op: "wait " + name, StatusCode: http.StatusInternalServerError, Message: wait.Error.Message}
+	}
+	if wait.StatusCode != 0 {
+		return output, &ExitError{Code: wait.StatusCode, Output: output}
+	}
+	return output, nil
+}
+
+// removeContainer force-removes a container, ignoring errors (it may not exist).
+func (m *Manager) removeContainer(ctx context.Context, id string) {
+	m.api.doDiscard(ctx, "remove "+id, http.MethodDelete, containerPath(id, ""), //nolint:errcheck
+		url.Values{"force": {"1"}}, http.StatusNotFound)
+}
+
+// runUser derives uid:gid from the PUID/PGID variables of the factorio image.
+func runUser(env []string) string {
+	uid, gid := "", ""
+	for _, kv := range env {
+		if v, ok := strings.CutPrefix(kv, "PUID="); ok {
+			uid = v
+		}
+		if v, ok := strings.CutPrefix(kv, "PGID="); ok {
+			gid = v
+		}
+	}
+	if uid == "" {
+		return defaultRunUser
+	}
+	if gid == "" {
+		gid = uid
+	}
+	return uid + ":" + gid
+}
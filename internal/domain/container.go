@@ -1,9 +1,67 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // ContainerManager controls the lifecycle of the Factorio Docker container
 type ContainerManager interface {
 	Start(ctx context.Context) error
 	Stop(ctx context.Context) error
+	Status(ctx context.Context) (ContainerStatus, error)
+	// Logs returns the last tail lines of the container output.
+	Logs(ctx context.Context, tail int) ([]string, error)
+	// FollowLogs calls fn for the last tail lines and then for every new line until
+	// ctx is cancelled or the container stops.
+	FollowLogs(ctx context.Context, tail int, fn func(line string)) error
+}
+
+// CommandRunner runs one-off commands with the server's image and volumes while the
+// server itself is stopped, e.g. `factorio --create` to generate a new map.
+type CommandRunner interface {
+	// RunOnce runs cmd (binary first) to completion and returns its output.
+	RunOnce(ctx context.Context, cmd []string) ([]string, error)
+}
+
+// ContainerState is the lifecycle state reported by the container runtime.
+type ContainerState string
+
+const (
+	ContainerCreated    ContainerState = "created"
+	ContainerRunning    ContainerState = "running"
+	ContainerPaused     ContainerState = "paused"
+	ContainerRestarting ContainerState = "restarting"
+	ContainerExited     ContainerState = "exited"
+	ContainerDead       ContainerState = "dead"
+)
+
+// ContainerStatus is a runtime-independent snapshot of the container state.
+type ContainerStatus struct {
+	State        ContainerState
+	ExitCode     int
+	Error        string // сообщение рантайма, если контейнер не смог стартовать
+	OOMKilled    bool
+	RestartCount int
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	Health       string // starting/healthy/unhealthy, пусто если HEALTHCHECK не задан
+}
+
+// Uptime returns how long the container has been running, or 0 if it is not running.
+func (s ContainerStatus) Uptime(now time.Time) time.Duration {
+	if s.State != ContainerRunning || s.StartedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(s.StartedAt)
+}
+
+// CrashLooping reports whether the container keeps dying and being restarted by the
+// restart policy: it is restarting right now, or has restarted several times and
+// has not stayed up for long.
+func (s ContainerStatus) CrashLooping(now time.Time) bool {
+	if s.State == ContainerRestarting {
+		return true
+	}
+	return s.RestartCount >= 3 && s.State == ContainerRunning && s.Uptime(now) < 5*time.Minute
 }
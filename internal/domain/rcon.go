@@ -1,6 +1,26 @@
 package domain
 
+import "context"
+
 // RconExecutor executes commands on the Factorio RCON interface.
+// Implementations must honour ctx cancellation and deadlines; when ctx has no deadline
+// a default timeout is applied so a hung server cannot block the caller forever.
+// A command that may already have reached the server is not sent again unless ctx
+// is marked with Idempotent.
 type RconExecutor interface {
-	Execute(command string) (string, error)
+	ExecuteContext(ctx context.Context, command string) (string, error)
+}
+
+type idempotentKey struct{}
+
+// Idempotent marks ctx for a command that is safe to run twice, like /version or
+// /players: the executor may resend it on a fresh connection after a failure.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// IsIdempotent reports whether ctx was marked with Idempotent.
+func IsIdempotent(ctx context.Context) bool {
+	v, _ := ctx.Value(idempotentKey{}).(bool)
+	return v
 }
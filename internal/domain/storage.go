@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"context"
+	"io"
+)
+
+// BackupStore is a flat object store that backups and save snapshots are pushed to.
+// Keys are slash-separated paths relative to the store root. Get of a missing key
+// returns an error matching fs.ErrNotExist.
+type BackupStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns all keys starting with prefix, in no particular order.
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,57 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policy is a grandfather-father-son retention policy: keep the newest backup of each
+// of the last Hourly hours, Daily days and Weekly ISO weeks. A zero Policy keeps everything.
+type Policy struct {
+	Hourly int
+	Daily  int
+	Weekly int
+}
+
+func (p Policy) empty() bool {
+	return p.Hourly <= 0 && p.Daily <= 0 && p.Weekly <= 0
+}
+
+// toPrune returns the backups the policy does not keep. backups must be sorted newest first.
+// The newest backup is always kept.
+func (p Policy) toPrune(backups []Backup) []Backup {
+	if p.empty() || len(backups) == 0 {
+		return nil
+	}
+
+	keep := map[string]bool{backups[0].ID: true}
+	bucket := func(n int, key func(time.Time) string) {
+		seen := make(map[string]bool)
+		for _, b := range backups {
+			if len(seen) >= n {
+				return
+			}
+			k := key(b.CreatedAt.Local())
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			keep[b.ID] = true
+		}
+	}
+
+	bucket(p.Hourly, func(t time.Time) string { return t.Format("2006010215") })
+	bucket(p.Daily, func(t time.Time) string { return t.Format("20060102") })
+	bucket(p.Weekly, func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%02d", y, w)
+	})
+
+	var prune []Backup
+	for _, b := range backups {
+		if !keep[b.ID] {
+			prune = append(prune, b)
+		}
+	}
+	return prune
+}
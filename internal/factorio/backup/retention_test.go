@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// hourlyBackups returns a backup every 30 minutes for n hours before end, newest first.
+func hourlyBackups(end time.Time, n int) []Backup {
+	var backups []Backup
+	for i := 0; i < 2*n; i++ {
+		at := end.Add(-time.Duration(i) * 30 * time.Minute)
+		backups = append(backups, Backup{ID: at.Format("20060102-1504"), CreatedAt: at})
+	}
+	return backups
+}
+
+func ids(backups []Backup) []string {
+	var out []string
+	for _, b := range backups {
+		out = append(out, b.ID)
+	}
+	return out
+}
+
+func TestPolicyToPrune(t *testing.T) {
+	// Суббота, 17 октября 2026, 12:30; бэкапы каждые полчаса за 30 дней.
+	end := time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local)
+	backups := hourlyBackups(end, 30*24)
+
+	tests := []struct {
+		name   string
+		policy Policy
+		keep   []string
+	}{
+		{
+			name:   "hourly",
+			policy: Policy{Hourly: 3},
+			keep:   []string{"20261017-1230", "20261017-1130", "20261017-1030"},
+		},
+		{
+			name:   "daily",
+			policy: Policy{Daily: 3},
+			keep:   []string{"20261017-1230", "20261016-2330", "20261015-2330"},
+		},
+		{
+			// ISO-недели начинаются в понедельник: 12.10, 05.10, 28.09.
+			name:   "weekly",
+			policy: Policy{Weekly: 3},
+			keep:   []string{"20261017-1230", "20261011-2330", "20261004-2330"},
+		},
+		{
+			// Корзины пересекаются: новейший бэкап закрывает и час, и день, и неделю.
+			name:   "combined",
+			policy: Policy{Hourly: 2, Daily: 2, Weekly: 2},
+			keep:   []string{"20261017-1230", "20261017-1130", "20261016-2330", "20261011-2330"},
+		},
+		{
+			name:   "newest always kept",
+			policy: Policy{Weekly: 1},
+			keep:   []string{"20261017-1230"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pruned := ids(tt.policy.toPrune(backups))
+			if len(pruned)+len(tt.keep) != len(backups) {
+				t.Errorf("pruned %d of %d, want %d kept", len(pruned), len(backups), len(tt.keep))
+			}
+			for _, id := range tt.keep {
+				if slices.Contains(pruned, id) {
+					t.Errorf("%s pruned, want kept", id)
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyToPruneKeepsEverything(t *testing.T) {
+	backups := hourlyBackups(time.Date(2026, 10, 17, 12, 30, 0, 0, time.Local), 48)
+	if pruned := (Policy{}).toPrune(backups); pruned != nil {
+		t.Errorf("zero policy pruned %d backups", len(pruned))
+	}
+	if pruned := (Policy{Hourly: 1000}).toPrune(backups); len(pruned) != len(backups)/2 {
+		t.Errorf("hourly policy longer than history pruned %d, want the half-hour duplicates (%d)", len(pruned), len(backups)/2)
+	}
+	if pruned := (Policy{Daily: 7}).toPrune(nil); pruned != nil {
+		t.Errorf("pruned %v from no backups", pruned)
+	}
+}
@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	valid := []string{"0 * * * *", "*/15 2-4 * * 1-5", "0 0 1,15 * *", "@daily", "@weekly", "30 4 29 2 *", "0 0 * * 7"}
+	for _, expr := range valid {
+		if s, err := ParseSchedule(expr); err != nil || s == nil {
+			t.Errorf("ParseSchedule(%q) = %v, %v", expr, s, err)
+		}
+	}
+	for _, expr := range []string{"off", "OFF", "none", "disabled"} {
+		if s, err := ParseSchedule(expr); err != nil || s != nil {
+			t.Errorf("ParseSchedule(%q) = %v, %v; want nil schedule", expr, s, err)
+		}
+	}
+	invalid := []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *", "@yearly",
+		"0 0 31 2 *", "0 0 30 2 *", "0 0 31 4,6,9,11 *",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) accepted", expr)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		expr, from, want string
+	}{
+		{"0 * * * *", "2026-10-17 10:00", "2026-10-17 11:00"},
+		{"0 * * * *", "2026-10-17 10:59", "2026-10-17 11:00"},
+		{"*/15 * * * *", "2026-10-17 10:07", "2026-10-17 10:15"},
+		{"@daily", "2026-12-31 23:30", "2027-01-01 00:00"},
+		{"@weekly", "2026-10-17 12:00", "2026-10-18 00:00"}, // суббота → воскресенье
+		{"0 3 * * 1-5", "2026-10-17 12:00", "2026-10-19 03:00"},
+		{"0 0 31 * *", "2026-04-15 00:00", "2026-05-31 00:00"},
+		// Оба поля дня ограничены — достаточно любого.
+		{"0 0 13 * 5", "2026-10-10 00:00", "2026-10-13 00:00"},
+		{"0 0 13 * 5", "2026-10-13 00:00", "2026-10-16 00:00"},
+		// 29 февраля: следующий високосный год.
+		{"30 4 29 2 *", "2026-10-17 00:00", "2028-02-29 04:30"},
+		{"30 4 29 2 *", "2096-03-01 00:00", "2104-02-29 04:30"},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", tt.expr, err)
+		}
+		if got := s.Next(at(tt.from)); !got.Equal(at(tt.want)) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.from, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+}
+
+func TestScheduleNextHalfHourZone(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+30*60)
+	s, err := ParseSchedule("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.Next(time.Date(2026, 10, 17, 23, 30, 0, 0, ist))
+	if want := time.Date(2026, 10, 18, 3, 0, 0, 0, ist); !got.Equal(want) {
+		t.Errorf("Next = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+	"perezvonish/factorio-server-manager/internal/factorio/saves"
+)
+
+const (
+	// saveWaitTimeout — сколько ждём, пока Factorio допишет сейв после /server-save.
+	saveWaitTimeout  = 2 * time.Minute
+	savePollInterval = time.Second
+)
+
+// Scheduler takes backups of the running game: it asks the server to save via RCON,
+// copies the fresh save into the Store and prunes old backups according to the Policy.
+type Scheduler struct {
+	rcon     domain.RconExecutor
+	saves    *saves.Manager
+	store    *Store
+	schedule *Schedule // nil — только ручные бэкапы
+	policy   Policy
+
+	mu sync.Mutex // одновременно выполняется только один бэкап
+}
+
+// NewScheduler creates a Scheduler. schedule may be nil to disable automatic backups.
+func NewScheduler(rcon domain.RconExecutor, saves *saves.Manager, store *Store, schedule *Schedule, policy Policy) *Scheduler {
+	return &Scheduler{
+		rcon:     rcon,
+		saves:    saves,
+		store:    store,
+		schedule: schedule,
+		policy:   policy,
+	}
+}
+
+// Run takes a backup at every scheduled time until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	if s.schedule == nil {
+		return
+	}
+
+	for {
+		next := s.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Println("backup: расписание никогда не срабатывает, планировщик остановлен")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if b, err := s.BackupNow(ctx, "schedule"); err != nil {
+			log.Printf("backup: ошибка планового бэкапа: %v", err)
+		} else {
+			log.Printf("backup: создан %s (%s, %d байт)", b.ID, b.SaveName, b.Size)
+		}
+	}
+}
+
+// BackupNow saves the game via RCON, stores the resulting save and applies retention.
+func (s *Scheduler) BackupNow(ctx context.Context, trigger string) (*Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := time.Now()
+	if _, err := s.rcon.ExecuteContext(ctx, "/server-save"); err != nil {
+		return nil, fmt.Errorf("server-save: %w", err)
+	}
+
+	save, err := s.waitForSave(ctx, started)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := s.saves.Open(save.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := s.store.Put(ctx, save.Name, trigger, f)
+	if err != nil {
+		return nil, err
+	}
+
+	s.prune(ctx)
+	return b, nil
+}
+
+// List returns all backups, newest first.
+func (s *Scheduler) List(ctx context.Context) ([]Backup, error) {
+	return s.store.List(ctx)
+}
+
+// Restore replaces the current saves with backup id. The checksum is verified while the
+// backup streams into the saves dir; on a mismatch the upload is discarded and the
+// current saves stay untouched.
+func (s *Scheduler) Restore(ctx context.Context, id string, origin saves.Origin) (*Backup, error) {
+	b, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := s.store.Open(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if _, err := s.saves.Replace(b.SaveName, rc, origin); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// waitForSave polls the saves dir until a save modified after since appears.
+func (s *Scheduler) waitForSave(ctx context.Context, since time.Time) (saves.SaveFile, error) {
+	ctx, cancel := context.WithTimeout(ctx, saveWaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(savePollInterval)
+	defer ticker.Stop()
+
+	for {
+		if save, err := s.saves.Latest(); err == nil && !save.ModTime.Before(since) {
+			return save, nil
+		}
+		select {
+		case <-ctx.Done():
+			return saves.SaveFile{}, fmt.Errorf("waiting for save after /server-save: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) prune(ctx context.Context) {
+	all, err := s.store.List(ctx)
+	if err != nil {
+		log.Printf("backup: retention: %v", err)
+		return
+	}
+	for _, b := range s.policy.toPrune(all) {
+		if err := s.store.Delete(ctx, b.ID); err != nil {
+			log.Printf("backup: не удалось удалить %s: %v", b.ID, err)
+			continue
+		}
+		log.Printf("backup: удалён по политике хранения %s", b.ID)
+	}
+}
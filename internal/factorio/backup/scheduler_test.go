@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"perezvonish/factorio-server-manager/internal/factorio/saves"
+	"perezvonish/factorio-server-manager/internal/storage"
+)
+
+// saveZip builds the smallest archive saves.Validate accepts.
+func saveZip(t *testing.T, marker string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"world/control.lua", "world/level.dat0"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(marker))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newTestScheduler(t *testing.T) (*Scheduler, *storage.Local, string) {
+	t.Helper()
+	dir := t.TempDir()
+	savesDir := filepath.Join(dir, "saves")
+	if err := os.Mkdir(savesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	objects := storage.NewLocal(filepath.Join(dir, "store"))
+	savesMgr := saves.NewManager(savesDir, filepath.Join(dir, "snapshots"), nil)
+	return NewScheduler(nil, savesMgr, NewStore(objects), nil, Policy{}), objects, savesDir
+}
+
+func TestRestore(t *testing.T) {
+	s, _, savesDir := newTestScheduler(t)
+	ctx := context.Background()
+
+	b, err := s.store.Put(ctx, "world.zip", "test", bytes.NewReader(saveZip(t, "backup")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Restore(ctx, b.ID, saves.Origin{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(savesDir, "world.zip")); err != nil {
+		t.Errorf("backup not restored: %v", err)
+	}
+}
+
+func TestRestoreChecksumMismatchKeepsSaves(t *testing.T) {
+	s, objects, savesDir := newTestScheduler(t)
+	ctx := context.Background()
+
+	current := saveZip(t, "current")
+	if err := os.WriteFile(filepath.Join(savesDir, "current.zip"), current, 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.store.Put(ctx, "world.zip", "test", bytes.NewReader(saveZip(t, "backup")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Данные целы и проходят проверку сейва, не совпадает только сумма в метаданных.
+	b.SHA256 = "0000000000000000000000000000000000000000000000000000000000000000"
+	meta, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := objects.Put(ctx, metaKey(b.ID), bytes.NewReader(meta), int64(len(meta))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Restore(ctx, b.ID, saves.Origin{Actor: "test"}); err == nil {
+		t.Fatal("restore of a backup with a wrong checksum succeeded")
+	}
+	got, err := os.ReadFile(filepath.Join(savesDir, "current.zip"))
+	if err != nil || !bytes.Equal(got, current) {
+		t.Errorf("current save was touched: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(savesDir, "world.zip")); !os.IsNotExist(err) {
+		t.Errorf("corrupted backup was written to the saves dir")
+	}
+}
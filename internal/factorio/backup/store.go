@@ -0,0 +1,222 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+const (
+	idLayout  = "20060102-150405"
+	keyPrefix = "backups/"
+)
+
+// Backup describes one stored backup. The save zip is stored as-is: Factorio saves are
+// already compressed, so they are never recompressed.
+type Backup struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	SaveName  string    `json:"save_name"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Trigger   string    `json:"trigger"` // schedule или имя пользователя
+}
+
+// Store keeps backups in a domain.BackupStore as backups/<id>.zip plus backups/<id>.json metadata.
+type Store struct {
+	objects domain.BackupStore
+}
+
+func NewStore(objects domain.BackupStore) *Store {
+	return &Store{objects: objects}
+}
+
+// Put copies r into a new backup. The data is spooled to a temporary file first so the
+// size and checksum are known before anything is uploaded.
+func (s *Store) Put(ctx context.Context, saveName, trigger string, r io.Reader) (*Backup, error) {
+	tmp, err := os.CreateTemp("", "backup-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return nil, fmt.Errorf("spooling backup: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("spooling backup: %w", err)
+	}
+
+	id, err := s.newID(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	b := &Backup{
+		ID:        id,
+		CreatedAt: time.Now(),
+		SaveName:  saveName,
+		Size:      size,
+		SHA256:    hex.EncodeToString(h.Sum(nil)),
+		Trigger:   trigger,
+	}
+
+	if err := s.objects.Put(ctx, dataKey(id), tmp, size); err != nil {
+		return nil, fmt.Errorf("uploading backup: %w", err)
+	}
+	meta, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling backup meta: %w", err)
+	}
+	// Метаданные пишем последними: бэкап без .json в списке не появится.
+	if err := s.objects.Put(ctx, metaKey(id), bytes.NewReader(meta), int64(len(meta))); err != nil {
+		s.objects.Delete(ctx, dataKey(id)) //nolint:errcheck
+		return nil, fmt.Errorf("uploading backup meta: %w", err)
+	}
+	return b, nil
+}
+
+// List returns all backups, newest first.
+func (s *Store) List(ctx context.Context) ([]Backup, error) {
+	keys, err := s.objects.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing backups: %w", err)
+	}
+
+	var backups []Backup
+	for _, key := range keys {
+		id, ok := strings.CutSuffix(path.Base(key), ".json")
+		if !ok {
+			continue
+		}
+		b, err := s.Get(ctx, id)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, *b)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// Get returns the metadata of backup id.
+func (s *Store) Get(ctx context.Context, id string) (*Backup, error) {
+	if err := validateID(id); err != nil {
+		return nil, err
+	}
+	rc, err := s.objects.Get(ctx, metaKey(id))
+	if err != nil {
+		return nil, fmt.Errorf("backup %s: %w", id, err)
+	}
+	defer rc.Close()
+
+	var b Backup
+	if err := json.NewDecoder(rc).Decode(&b); err != nil {
+		return nil, fmt.Errorf("backup %s: %w", id, err)
+	}
+	return &b, nil
+}
+
+// Open returns the save zip of backup b. The data is hashed while it is read: at the end
+// of the stream a size or checksum that differs from the metadata is returned as the read
+// error, so a consumer that reads to EOF never accepts a corrupted backup.
+func (s *Store) Open(ctx context.Context, b *Backup) (io.ReadCloser, error) {
+	if err := validateID(b.ID); err != nil {
+		return nil, err
+	}
+	rc, err := s.objects.Get(ctx, dataKey(b.ID))
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{rc: rc, backup: b, hash: sha256.New()}, nil
+}
+
+// Delete removes backup id.
+func (s *Store) Delete(ctx context.Context, id string) error {
+	if err := validateID(id); err != nil {
+		return err
+	}
+	if err := s.objects.Delete(ctx, metaKey(id)); err != nil {
+		return err
+	}
+	return s.objects.Delete(ctx, dataKey(id))
+}
+
+// verifyingReader checks the size and checksum of a backup as it is streamed.
+type verifyingReader struct {
+	rc     io.ReadCloser
+	backup *Backup
+	hash   hash.Hash
+	n      int64
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+	r.hash.Write(p[:n])
+	r.n += int64(n)
+	if err != io.EOF {
+		return n, err
+	}
+	if r.n != r.backup.Size {
+		return n, fmt.Errorf("backup %s: size mismatch (%d != %d)", r.backup.ID, r.n, r.backup.Size)
+	}
+	if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.backup.SHA256 {
+		return n, fmt.Errorf("backup %s: checksum mismatch (%s != %s)", r.backup.ID, sum, r.backup.SHA256)
+	}
+	return n, io.EOF
+}
+
+func (r *verifyingReader) Close() error {
+	return r.rc.Close()
+}
+
+// newID returns a timestamp id not yet used in the store.
+func (s *Store) newID(ctx context.Context, now time.Time) (string, error) {
+	keys, err := s.objects.List(ctx, keyPrefix+now.Format(idLayout))
+	if err != nil {
+		return "", fmt.Errorf("listing backups: %w", err)
+	}
+	taken := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		taken[k] = true
+	}
+
+	base := now.Format(idLayout)
+	for i := 0; ; i++ {
+		id := base
+		if i > 0 {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+		if !taken[metaKey(id)] && !taken[dataKey(id)] {
+			return id, nil
+		}
+	}
+}
+
+func dataKey(id string) string { return keyPrefix + id + ".zip" }
+func metaKey(id string) string { return keyPrefix + id + ".json" }
+
+// validateID rejects ids that could escape the backups prefix.
+func validateID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid backup id %q", id)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package mapgen
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+// Kind identifies one of the two settings files.
+type Kind string
+
+const (
+	KindMapGen Kind = "map-gen-settings"
+	KindMap    Kind = "map-settings"
+)
+
+// ErrUnknownSetting is returned for a path that belongs to neither settings file.
+var ErrUnknownSetting = errors.New("unknown setting")
+
+// Document is a settings file loaded for editing. Edits go through a JSON tree that
+// keeps key order and "_comment…" keys; every Set is validated against the typed
+// settings before it is accepted.
+type Document struct {
+	Kind Kind
+	file string
+	root *object
+}
+
+// LoadDocument reads a settings file. The content is not validated here, so a file
+// that is already out of range can still be opened and fixed; see Validate.
+func LoadDocument(kind Kind, file string) (*Document, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(file), err)
+	}
+	root, err := parseTree(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(file), err)
+	}
+	return &Document{Kind: kind, file: file, root: root}, nil
+}
+
+// Validate reports every problem in the document as *ValidationError.
+func (d *Document) Validate() error {
+	return validate(d.Kind, d.root)
+}
+
+// Get returns the value at a dotted path as indented JSON.
+func (d *Document) Get(path string) (string, error) {
+	parts, err := splitPath(path)
+	if err != nil {
+		return "", err
+	}
+	v, ok := lookup(d.root, parts)
+	if !ok {
+		return "", fmt.Errorf("%s: no setting %q", d.Kind, path)
+	}
+	return strings.TrimSpace(string(encodeTree(v))), nil
+}
+
+// Set changes the value at a dotted path. raw is JSON (numbers, true/false, null,
+// quoted strings) or a bare string. Unknown settings are rejected unless they belong
+// to a map such as autoplace_controls, and the result must pass validation; on error
+// the document is left unchanged.
+func (d *Document) Set(path, raw string) error {
+	return d.SetAll(map[string]string{path: raw})
+}
+
+// SetAll applies several changes like Set, but to one copy of the document that is
+// validated once, so settings checked against each other (a minimum and its maximum)
+// can change together. On error the document is left unchanged.
+func (d *Document) SetAll(values map[string]string) error {
+	// Правим копию: при ошибке валидации исходный документ не меняется.
+	root, err := parseTree(encodeTree(d.root))
+	if err != nil {
+		return err
+	}
+	for path, raw := range values {
+		parts, err := d.checkPath(path)
+		if err != nil {
+			return err
+		}
+		if err := assign(root, parts, parseValue(raw)); err != nil {
+			return err
+		}
+	}
+	if err := validate(d.Kind, root); err != nil {
+		return err
+	}
+	d.root = root
+	return nil
+}
+
+// CheckPath reports whether path names a setting that can be set in this document.
+func (d *Document) CheckPath(path string) error {
+	_, err := d.checkPath(path)
+	return err
+}
+
+func (d *Document) checkPath(path string) ([]string, error) {
+	parts, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(parts[len(parts)-1], "_") {
+		return nil, fmt.Errorf("%s is a comment", path)
+	}
+	if _, exists := lookup(d.root, parts); !exists && !schemaAllows(d.Kind, parts) {
+		return nil, fmt.Errorf("%s: unknown setting %q", d.Kind, path)
+	}
+	return parts, nil
+}
+
+// Save writes the document back atomically, keeping the previous version as .bak.
+func (d *Document) Save() error {
+	return safefile.WriteFile(d.file, encodeTree(d.root), 0644)
+}
+
+// KindOf returns which settings file a dotted path belongs to, judging by its first
+// segment; the two files have no top-level keys in common. Sections the typed settings
+// do not describe (path_finder, unit_group, steering…) are looked up in docs.
+func KindOf(path string, docs ...*Document) (Kind, bool) {
+	top, _, _ := strings.Cut(path, ".")
+	for _, kind := range []Kind{KindMapGen, KindMap} {
+		if _, ok := structField(typeOf(kind), top); ok {
+			return kind, true
+		}
+	}
+	for _, d := range docs {
+		if _, ok := d.root.get(top); ok && !strings.HasPrefix(top, "_") {
+			return d.Kind, true
+		}
+	}
+	return "", false
+}
+
+func typeOf(kind Kind) reflect.Type {
+	if kind == KindMapGen {
+		return reflect.TypeOf(MapGenSettings{})
+	}
+	return reflect.TypeOf(MapSettings{})
+}
+
+// schemaAllows reports whether a path that is not in the file yet names a setting of
+// the typed struct. Keys of maps (autoplace_controls.<name>) are free-form.
+func schemaAllows(kind Kind, parts []string) bool {
+	t := typeOf(kind)
+	for _, p := range parts {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+			if t.Kind() == reflect.Slice {
+				return false // элементы массивов добавлять нельзя, только менять
+			}
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := structField(t, p)
+			if !ok {
+				return false
+			}
+			t = f.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// structField finds the field of t whose json name is name.
+func structField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
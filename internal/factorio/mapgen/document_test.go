@@ -0,0 +1,89 @@
+package mapgen
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testMapSettings = `{
+  "enemy_expansion": {
+    "enabled": true,
+    "settler_group_min_size": 5,
+    "settler_group_max_size": 10
+  },
+  "path_finder": {
+    "fwd2bwd_ratio": 5
+  }
+}`
+
+func loadTestDocument(t *testing.T) *Document {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "map-settings.json")
+	if err := os.WriteFile(file, []byte(testMapSettings), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := LoadDocument(KindMap, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestSetAllValidatesOnce(t *testing.T) {
+	doc := loadTestDocument(t)
+
+	// По одному значения не поменять: новый минимум больше старого максимума.
+	var invalid *ValidationError
+	if err := doc.Set("enemy_expansion.settler_group_min_size", "15"); !errors.As(err, &invalid) {
+		t.Fatalf("Set min alone: %v, want *ValidationError", err)
+	}
+
+	err := doc.SetAll(map[string]string{
+		"enemy_expansion.settler_group_min_size": "15",
+		"enemy_expansion.settler_group_max_size": "20",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := doc.Get("enemy_expansion.settler_group_min_size"); v != "15" {
+		t.Errorf("min = %s, want 15", v)
+	}
+}
+
+func TestSetAllLeavesDocumentOnError(t *testing.T) {
+	doc := loadTestDocument(t)
+	err := doc.SetAll(map[string]string{
+		"enemy_expansion.enabled":                "false",
+		"enemy_expansion.settler_group_min_size": "50",
+	})
+	if err == nil {
+		t.Fatal("invalid SetAll succeeded")
+	}
+	if v, _ := doc.Get("enemy_expansion.enabled"); v != "true" {
+		t.Errorf("enabled = %s after a failed SetAll, want true", v)
+	}
+}
+
+func TestKindOfFallsBackToFileContents(t *testing.T) {
+	doc := loadTestDocument(t)
+
+	if _, ok := KindOf("path_finder.fwd2bwd_ratio"); ok {
+		t.Error("path_finder resolved without documents")
+	}
+	kind, ok := KindOf("path_finder.fwd2bwd_ratio", doc)
+	if !ok || kind != KindMap {
+		t.Errorf("KindOf = %q, %v; want %q", kind, ok, KindMap)
+	}
+	if kind, ok := KindOf("autoplace_controls.coal.size"); !ok || kind != KindMapGen {
+		t.Errorf("KindOf(autoplace_controls) = %q, %v", kind, ok)
+	}
+	if _, ok := KindOf("no_such_section.x", doc); ok {
+		t.Error("unknown section resolved")
+	}
+
+	if err := doc.Set("path_finder.fwd2bwd_ratio", "3"); err != nil {
+		t.Fatal(err)
+	}
+}
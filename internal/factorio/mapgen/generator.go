@@ -0,0 +1,184 @@
+package mapgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+// Options locate the settings files and the factorio binary. The paths are passed
+// to a one-off container with the server's volumes, so they must be the same in the
+// bot and the factorio containers (both mount the data dir as /factorio).
+type Options struct {
+	Binary             string // factorio внутри образа сервера
+	MapGenSettingsFile string
+	MapSettingsFile    string
+	PresetsFile        string
+	SavesDir           string
+	ModsDir            string
+}
+
+// Generator creates new maps with `factorio --create` in a one-off container.
+type Generator struct {
+	runner domain.CommandRunner
+	opts   Options
+}
+
+func NewGenerator(runner domain.CommandRunner, opts Options) *Generator {
+	return &Generator{runner: runner, opts: opts}
+}
+
+// Presets returns the configured presets.
+func (g *Generator) Presets() (map[string]Preset, error) {
+	return LoadPresets(g.opts.PresetsFile)
+}
+
+// Create generates a new map into the saves dir under name. The base settings files
+// are combined with preset (if not empty) and seed (if not nil). The server must be
+// stopped: the map is created by a second factorio process.
+func (g *Generator) Create(ctx context.Context, name, preset string, seed *uint32) error {
+	mapGen, err := readJSON(g.opts.MapGenSettingsFile)
+	if err != nil {
+		return err
+	}
+	mapSettings, err := readJSON(g.opts.MapSettingsFile)
+	if err != nil {
+		return err
+	}
+
+	if preset != "" {
+		presets, err := g.Presets()
+		if err != nil {
+			return err
+		}
+		p, ok := presets[preset]
+		if !ok {
+			return fmt.Errorf("unknown preset %q", preset)
+		}
+		merge(mapGen, p.MapGenSettings)
+		merge(mapSettings, p.MapSettings)
+	}
+	if seed != nil {
+		mapGen["seed"] = *seed
+	}
+	if err := validateMerged(KindMapGen, mapGen); err != nil {
+		return err
+	}
+	if err := validateMerged(KindMap, mapSettings); err != nil {
+		return err
+	}
+
+	// Итоговые настройки кладём рядом с исходными: этот путь виден и в контейнере сервера.
+	mapGenFile, err := writeTempJSON(g.opts.MapGenSettingsFile, mapGen)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(mapGenFile)
+	mapSettingsFile, err := writeTempJSON(g.opts.MapSettingsFile, mapSettings)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(mapSettingsFile)
+
+	cmd := []string{
+		g.opts.Binary,
+		"--create", filepath.Join(g.opts.SavesDir, name),
+		"--map-gen-settings", mapGenFile,
+		"--map-settings", mapSettingsFile,
+	}
+	if g.opts.ModsDir != "" {
+		cmd = append(cmd, "--mod-directory", g.opts.ModsDir)
+	}
+	if _, err := g.runner.RunOnce(ctx, cmd); err != nil {
+		return fmt.Errorf("creating map: %w", err)
+	}
+	return nil
+}
+
+// Document loads one of the base settings files for editing.
+func (g *Generator) Document(kind Kind) (*Document, error) {
+	switch kind {
+	case KindMapGen:
+		return LoadDocument(kind, g.opts.MapGenSettingsFile)
+	case KindMap:
+		return LoadDocument(kind, g.opts.MapSettingsFile)
+	}
+	return nil, fmt.Errorf("unknown settings kind %q", kind)
+}
+
+// DocumentFor loads the settings file a dotted path belongs to, see KindOf.
+// A path found in neither file is ErrUnknownSetting.
+func (g *Generator) DocumentFor(path string) (*Document, error) {
+	docs := make(map[Kind]*Document)
+	for _, kind := range []Kind{KindMapGen, KindMap} {
+		doc, err := g.Document(kind)
+		if err != nil {
+			return nil, err
+		}
+		docs[kind] = doc
+	}
+	kind, ok := KindOf(path, docs[KindMapGen], docs[KindMap])
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownSetting, path)
+	}
+	return docs[kind], nil
+}
+
+// validateMerged checks settings combined with a preset before they reach factorio.
+func validateMerged(kind Kind, m map[string]any) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	root, err := parseTree(data)
+	if err != nil {
+		return err
+	}
+	if err := validate(kind, root); err != nil {
+		return fmt.Errorf("%s: %w", kind, err)
+	}
+	return nil
+}
+
+func readJSON(file string) (map[string]any, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(file), err)
+	}
+	m := make(map[string]any)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Base(file), err)
+	}
+	return m, nil
+}
+
+// writeTempJSON writes v next to base as a hidden temporary file and returns its path.
+func writeTempJSON(base string, v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(filepath.Dir(base), ".newmap-*-"+filepath.Base(base))
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	// Разовый контейнер работает под пользователем сервера, а не под root бота.
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
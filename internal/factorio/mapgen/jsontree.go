@@ -0,0 +1,237 @@
+package mapgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// object is a JSON object that remembers key order, so a settings file written back
+// keeps its key order and its "_comment…" keys (whitespace is normalized). Values are *object, []any, json.Number,
+// string, bool or nil.
+type object struct {
+	keys []string
+	vals map[string]any
+}
+
+func newObject() *object {
+	return &object{vals: make(map[string]any)}
+}
+
+func (o *object) get(key string) (any, bool) {
+	v, ok := o.vals[key]
+	return v, ok
+}
+
+func (o *object) set(key string, v any) {
+	if _, ok := o.vals[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.vals[key] = v
+}
+
+// parseTree decodes a JSON document whose root is an object.
+func parseTree(data []byte) (*object, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the top-level object")
+	}
+	root, ok := v.(*object)
+	if !ok {
+		return nil, errors.New("top-level value is not an object")
+	}
+	return root, nil
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := newObject()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(keyTok.(string), v)
+			}
+			_, err := dec.Token() // '}'
+			return obj, err
+		case '[':
+			arr := []any{}
+			for dec.More() {
+				v, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			_, err := dec.Token() // ']'
+			return arr, err
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	default:
+		return t, nil
+	}
+}
+
+// encodeTree renders v as indented JSON, keeping object key order.
+func encodeTree(v any) []byte {
+	var buf bytes.Buffer
+	writeValue(&buf, v, "")
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func writeValue(buf *bytes.Buffer, v any, indent string) {
+	switch t := v.(type) {
+	case *object:
+		if len(t.keys) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{\n")
+		for i, k := range t.keys {
+			buf.WriteString(indent + "  ")
+			writeString(buf, k)
+			buf.WriteString(": ")
+			writeValue(buf, t.vals[k], indent+"  ")
+			if i < len(t.keys)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case []any:
+		if len(t) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for i, e := range t {
+			buf.WriteString(indent + "  ")
+			writeValue(buf, e, indent+"  ")
+			if i < len(t)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+	case string:
+		writeString(buf, t)
+	default:
+		data, _ := json.Marshal(t)
+		buf.Write(data)
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	buf.Write(data)
+}
+
+// splitPath splits a dotted path such as "autoplace_controls.iron-ore.size".
+func splitPath(path string) ([]string, error) {
+	if path == "" {
+		return nil, errors.New("empty path")
+	}
+	parts := strings.Split(path, ".")
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return parts, nil
+}
+
+// lookup returns the value at path; array elements are addressed by index.
+func lookup(root *object, parts []string) (any, bool) {
+	var cur any = root
+	for _, p := range parts {
+		switch c := cur.(type) {
+		case *object:
+			v, ok := c.get(p)
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// assign sets the value at path, creating missing objects along the way.
+func assign(root *object, parts []string, v any) error {
+	var cur any = root
+	for i, p := range parts {
+		last := i == len(parts)-1
+		switch c := cur.(type) {
+		case *object:
+			if last {
+				c.set(p, v)
+				return nil
+			}
+			next, ok := c.get(p)
+			if !ok || next == nil {
+				next = newObject()
+				c.set(p, next)
+			}
+			cur = next
+		case []any:
+			idx, err := strconv.Atoi(p)
+			if err != nil || idx < 0 || idx >= len(c) {
+				return fmt.Errorf("no element %q in %s", p, strings.Join(parts[:i], "."))
+			}
+			if last {
+				c[idx] = v
+				return nil
+			}
+			cur = c[idx]
+		default:
+			return fmt.Errorf("%s is not an object", strings.Join(parts[:i], "."))
+		}
+	}
+	return nil
+}
+
+// parseValue interprets a user-typed value: valid JSON (numbers, true/false, null,
+// quoted strings, objects) is used as is, anything else is taken as a plain string.
+func parseValue(raw string) any {
+	raw = strings.TrimSpace(raw)
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	v, err := decodeValue(dec)
+	if err != nil {
+		return raw
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return raw
+	}
+	return v
+}
@@ -0,0 +1,72 @@
+// Package mapgen creates new Factorio maps from map-gen-settings.json and
+// map-settings.json, optionally overridden by a named preset.
+package mapgen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Preset is a named set of overrides deep-merged into the base settings files.
+type Preset struct {
+	Description    string         `json:"description"`
+	MapGenSettings map[string]any `json:"map_gen_settings"`
+	MapSettings    map[string]any `json:"map_settings"`
+}
+
+// LoadPresets reads the presets file. Keys starting with "_" are comments.
+// A missing file means there are no presets.
+func LoadPresets(file string) (map[string]Preset, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]Preset{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading presets: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	presets := make(map[string]Preset, len(raw))
+	for name, msg := range raw {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		var p Preset
+		if err := json.Unmarshal(msg, &p); err != nil {
+			return nil, fmt.Errorf("preset %q: %w", name, err)
+		}
+		presets[name] = p
+	}
+	return presets, nil
+}
+
+// PresetNames returns the preset names sorted alphabetically.
+func PresetNames(presets map[string]Preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// merge deep-merges src into dst: nested objects are merged key by key, any other
+// value in src replaces the one in dst.
+func merge(dst, src map[string]any) {
+	for k, v := range src {
+		if sub, ok := v.(map[string]any); ok {
+			if existing, ok := dst[k].(map[string]any); ok {
+				merge(existing, sub)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,269 @@
+package mapgen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MapGenSettings is the typed view of map-gen-settings.json. Keys not listed here
+// (comments, newer Factorio options) are kept in the file untouched.
+type MapGenSettings struct {
+	Width                   uint32                      `json:"width"`
+	Height                  uint32                      `json:"height"`
+	StartingArea            float64                     `json:"starting_area"`
+	PeacefulMode            bool                        `json:"peaceful_mode"`
+	NoEnemiesMode           bool                        `json:"no_enemies_mode"`
+	AutoplaceControls       map[string]AutoplaceControl `json:"autoplace_controls"`
+	CliffSettings           CliffSettings               `json:"cliff_settings"`
+	PropertyExpressionNames map[string]any              `json:"property_expression_names"`
+	StartingPoints          []Position                  `json:"starting_points"`
+	Seed                    *uint32                     `json:"seed"` // nil — случайный
+}
+
+// AutoplaceControl sets how often, how large and how rich a resource or feature spawns.
+type AutoplaceControl struct {
+	Frequency *float64 `json:"frequency"`
+	Size      *float64 `json:"size"`
+	Richness  *float64 `json:"richness"`
+}
+
+type CliffSettings struct {
+	Name                   string  `json:"name"`
+	CliffElevation0        float64 `json:"cliff_elevation_0"`
+	CliffElevationInterval float64 `json:"cliff_elevation_interval"`
+	Richness               float64 `json:"richness"`
+}
+
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// MapSettings is the typed view of the parts of map-settings.json that are commonly
+// tuned; unit groups, steering and path finder settings are kept as is.
+type MapSettings struct {
+	DifficultySettings struct {
+		TechnologyPriceMultiplier float64 `json:"technology_price_multiplier"`
+		SpoilTimeModifier         float64 `json:"spoil_time_modifier"`
+	} `json:"difficulty_settings"`
+	Pollution struct {
+		Enabled                                 bool    `json:"enabled"`
+		DiffusionRatio                          float64 `json:"diffusion_ratio"`
+		Ageing                                  float64 `json:"ageing"`
+		MinPollutionToDamageTrees               float64 `json:"min_pollution_to_damage_trees"`
+		EnemyAttackPollutionConsumptionModifier float64 `json:"enemy_attack_pollution_consumption_modifier"`
+	} `json:"pollution"`
+	EnemyEvolution struct {
+		Enabled         bool    `json:"enabled"`
+		TimeFactor      float64 `json:"time_factor"`
+		DestroyFactor   float64 `json:"destroy_factor"`
+		PollutionFactor float64 `json:"pollution_factor"`
+	} `json:"enemy_evolution"`
+	EnemyExpansion struct {
+		Enabled              bool    `json:"enabled"`
+		MaxExpansionDistance float64 `json:"max_expansion_distance"`
+		SettlerGroupMinSize  float64 `json:"settler_group_min_size"`
+		SettlerGroupMaxSize  float64 `json:"settler_group_max_size"`
+		MinExpansionCooldown float64 `json:"min_expansion_cooldown"`
+		MaxExpansionCooldown float64 `json:"max_expansion_cooldown"`
+	} `json:"enemy_expansion"`
+}
+
+// Range is the interval Factorio accepts for a numeric setting (the map generator GUI
+// limits where there is one).
+type Range struct {
+	Min, Max float64
+}
+
+// limits maps a path pattern ("*" matches any key) to its allowed range.
+var limits = map[Kind]map[string]Range{
+	KindMapGen: {
+		"width":                                   {0, 2000000},
+		"height":                                  {0, 2000000},
+		"starting_area":                           {1.0 / 6, 6},
+		"autoplace_controls.*.frequency":          {1.0 / 6, 6},
+		"autoplace_controls.*.size":               {0, 6},
+		"autoplace_controls.*.richness":           {1.0 / 6, 6},
+		"cliff_settings.cliff_elevation_0":        {0, 1024},
+		"cliff_settings.cliff_elevation_interval": {1, 1000},
+		"cliff_settings.richness":                 {0, 10},
+		"seed":                                    {0, 4294967295},
+	},
+	KindMap: {
+		"difficulty_settings.technology_price_multiplier":       {0.001, 1000},
+		"difficulty_settings.spoil_time_modifier":               {0.01, 100},
+		"pollution.diffusion_ratio":                             {0, 0.25},
+		"pollution.ageing":                                      {0.1, 4},
+		"pollution.min_pollution_to_damage_trees":               {0, 9999},
+		"pollution.enemy_attack_pollution_consumption_modifier": {0.1, 4},
+		"enemy_evolution.time_factor":                           {0, 0.0001},
+		"enemy_evolution.destroy_factor":                        {0, 0.01},
+		"enemy_evolution.pollution_factor":                      {0, 0.00001},
+		"enemy_expansion.max_expansion_distance":                {2, 20},
+		"enemy_expansion.settler_group_min_size":                {1, 20},
+		"enemy_expansion.settler_group_max_size":                {1, 50},
+		"enemy_expansion.min_expansion_cooldown":                {3600, 216000},  // 1–60 мин
+		"enemy_expansion.max_expansion_cooldown":                {18000, 648000}, // 5–180 мин
+	},
+}
+
+// ValidationError lists every problem found in a settings file.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid settings: " + strings.Join(e.Problems, "; ")
+}
+
+// validate checks a settings tree: it must decode into the typed struct of its kind,
+// numbers must be within limits, and related values must be consistent.
+func validate(kind Kind, root *object) error {
+	data := encodeTree(root)
+	var problems []string
+
+	switch kind {
+	case KindMapGen:
+		var s MapGenSettings
+		if err := json.Unmarshal(data, &s); err != nil {
+			return &ValidationError{Problems: []string{typeProblem(err)}}
+		}
+	case KindMap:
+		var s MapSettings
+		if err := json.Unmarshal(data, &s); err != nil {
+			return &ValidationError{Problems: []string{typeProblem(err)}}
+		}
+		ex := s.EnemyExpansion
+		if ex.SettlerGroupMinSize > ex.SettlerGroupMaxSize {
+			problems = append(problems, "enemy_expansion.settler_group_min_size is greater than settler_group_max_size")
+		}
+		if ex.MinExpansionCooldown > ex.MaxExpansionCooldown {
+			problems = append(problems, "enemy_expansion.min_expansion_cooldown is greater than max_expansion_cooldown")
+		}
+	default:
+		return fmt.Errorf("unknown settings kind %q", kind)
+	}
+
+	walkNumbers(root, nil, func(path []string, n json.Number) {
+		r, ok := limitFor(kind, path)
+		if !ok {
+			return
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return
+		}
+		if f < r.Min || f > r.Max {
+			problems = append(problems, fmt.Sprintf("%s = %s is outside [%s, %s]",
+				strings.Join(path, "."), n, formatFloat(r.Min), formatFloat(r.Max)))
+		}
+	})
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// typeProblem rewrites a json decoding error in terms of the setting path.
+func typeProblem(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Sprintf("%s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return err.Error()
+}
+
+// walkNumbers calls fn for every number in the tree, skipping "_comment" keys.
+func walkNumbers(v any, path []string, fn func(path []string, n json.Number)) {
+	switch t := v.(type) {
+	case *object:
+		for _, k := range t.keys {
+			if strings.HasPrefix(k, "_") {
+				continue
+			}
+			walkNumbers(t.vals[k], append(path[:len(path):len(path)], k), fn)
+		}
+	case []any:
+		for i, e := range t {
+			walkNumbers(e, append(path[:len(path):len(path)], strconv.Itoa(i)), fn)
+		}
+	case json.Number:
+		fn(path, t)
+	}
+}
+
+// limitFor finds the range for a concrete path, trying "*" for every segment.
+func limitFor(kind Kind, path []string) (Range, bool) {
+	for pattern, r := range limits[kind] {
+		parts := strings.Split(pattern, ".")
+		if len(parts) != len(path) {
+			continue
+		}
+		match := true
+		for i, p := range parts {
+			if p != "*" && p != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return r, true
+		}
+	}
+	return Range{}, false
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+// Field describes one setting shown in the WebApp form.
+type Field struct {
+	Kind  Kind     `json:"kind"`
+	Path  string   `json:"path"`
+	Label string   `json:"label"`
+	Type  string   `json:"type"` // number или bool
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+// FormFields returns the settings exposed in the WebApp form, in display order,
+// with their allowed ranges.
+func FormFields() []Field {
+	fields := make([]Field, len(formFields))
+	for i, f := range formFields {
+		parts, _ := splitPath(f.Path)
+		if r, ok := limitFor(f.Kind, parts); ok {
+			f.Min, f.Max = &r.Min, &r.Max
+		}
+		fields[i] = f
+	}
+	return fields
+}
+
+var formFields = []Field{
+	{Kind: KindMapGen, Path: "seed", Label: "Seed (пусто — случайный)", Type: "number"},
+	{Kind: KindMapGen, Path: "width", Label: "Ширина (0 — бесконечная)", Type: "number"},
+	{Kind: KindMapGen, Path: "height", Label: "Высота (0 — бесконечная)", Type: "number"},
+	{Kind: KindMapGen, Path: "starting_area", Label: "Стартовая зона", Type: "number"},
+	{Kind: KindMapGen, Path: "peaceful_mode", Label: "Мирный режим", Type: "bool"},
+	{Kind: KindMapGen, Path: "autoplace_controls.iron-ore.richness", Label: "Железо: богатство", Type: "number"},
+	{Kind: KindMapGen, Path: "autoplace_controls.enemy-base.frequency", Label: "Базы врагов: частота", Type: "number"},
+	{Kind: KindMapGen, Path: "autoplace_controls.enemy-base.size", Label: "Базы врагов: размер", Type: "number"},
+	{Kind: KindMapGen, Path: "cliff_settings.richness", Label: "Скалы: непрерывность", Type: "number"},
+	{Kind: KindMap, Path: "difficulty_settings.technology_price_multiplier", Label: "Множитель цены исследований", Type: "number"},
+	{Kind: KindMap, Path: "pollution.enabled", Label: "Загрязнение", Type: "bool"},
+	{Kind: KindMap, Path: "enemy_evolution.enabled", Label: "Эволюция врагов", Type: "bool"},
+	{Kind: KindMap, Path: "enemy_evolution.time_factor", Label: "Эволюция: от времени", Type: "number"},
+	{Kind: KindMap, Path: "enemy_evolution.destroy_factor", Label: "Эволюция: от разрушения гнёзд", Type: "number"},
+	{Kind: KindMap, Path: "enemy_evolution.pollution_factor", Label: "Эволюция: от загрязнения", Type: "number"},
+	{Kind: KindMap, Path: "enemy_expansion.enabled", Label: "Расселение врагов", Type: "bool"},
+	{Kind: KindMap, Path: "enemy_expansion.min_expansion_cooldown", Label: "Расселение: мин. интервал (тики)", Type: "number"},
+	{Kind: KindMap, Path: "enemy_expansion.max_expansion_cooldown", Label: "Расселение: макс. интервал (тики)", Type: "number"},
+}
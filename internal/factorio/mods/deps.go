@@ -0,0 +1,93 @@
+package mods
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DepKind is the kind of a dependency declared in a mod's info.json.
+type DepKind int
+
+const (
+	DepRequired     DepKind = iota // "name" или "~ name" (не влияет на порядок загрузки)
+	DepOptional                    // "? name"
+	DepHidden                      // "(?) name" — optional, не показывается в игре
+	DepIncompatible                // "! name"
+)
+
+// Dependency is one parsed entry of info.json "dependencies", e.g. "? bobores >= 1.1.0".
+type Dependency struct {
+	Kind    DepKind
+	Name    string
+	Op      string // "", "<", "<=", "=", ">=", ">"
+	Version string
+}
+
+// versionOps are checked longest first, so "<=" is not taken for "<".
+var versionOps = []string{"<=", ">=", "<", ">", "="}
+
+// ParseDependency parses a Factorio dependency string.
+func ParseDependency(s string) (Dependency, error) {
+	var d Dependency
+	rest := strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(rest, "(?)"):
+		d.Kind, rest = DepHidden, rest[3:]
+	case strings.HasPrefix(rest, "?"):
+		d.Kind, rest = DepOptional, rest[1:]
+	case strings.HasPrefix(rest, "!"):
+		d.Kind, rest = DepIncompatible, rest[1:]
+	case strings.HasPrefix(rest, "~"):
+		d.Kind, rest = DepRequired, rest[1:]
+	}
+	rest = strings.TrimSpace(rest)
+
+	// Имена модов бывают с пробелами, поэтому ищем оператор, а не делим по пробелам.
+	for _, op := range versionOps {
+		if i := strings.Index(rest, op); i >= 0 {
+			d.Name = strings.TrimSpace(rest[:i])
+			d.Op = op
+			d.Version = strings.TrimSpace(rest[i+len(op):])
+			if d.Version == "" || strings.ContainsAny(d.Version, " <>=") {
+				return Dependency{}, fmt.Errorf("invalid dependency %q", s)
+			}
+			break
+		}
+	}
+	if d.Op == "" {
+		d.Name = rest
+	}
+	if d.Name == "" {
+		return Dependency{}, fmt.Errorf("invalid dependency %q", s)
+	}
+	return d, nil
+}
+
+// Allows reports whether version satisfies the dependency's version constraint.
+func (d Dependency) Allows(version string) bool {
+	if d.Op == "" {
+		return true
+	}
+	c := compareVersions(version, d.Version)
+	switch d.Op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case "=":
+		return c == 0
+	case ">=":
+		return c >= 0
+	case ">":
+		return c > 0
+	}
+	return false
+}
+
+// Constraint renders the version part, e.g. ">= 1.1.0"; empty if there is none.
+func (d Dependency) Constraint() string {
+	if d.Op == "" {
+		return ""
+	}
+	return d.Op + " " + d.Version
+}
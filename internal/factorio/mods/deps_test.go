@@ -0,0 +1,73 @@
+package mods
+
+import "testing"
+
+func TestParseDependency(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Dependency
+	}{
+		{"base", Dependency{Kind: DepRequired, Name: "base"}},
+		{"base >= 2.0.0", Dependency{Kind: DepRequired, Name: "base", Op: ">=", Version: "2.0.0"}},
+		{"~ flib >= 0.15.0", Dependency{Kind: DepRequired, Name: "flib", Op: ">=", Version: "0.15.0"}},
+		{"? bobores", Dependency{Kind: DepOptional, Name: "bobores"}},
+		{"?bobores > 1.1.0", Dependency{Kind: DepOptional, Name: "bobores", Op: ">", Version: "1.1.0"}},
+		{"(?) space-age", Dependency{Kind: DepHidden, Name: "space-age"}},
+		{"(?)quality <= 2.0.7", Dependency{Kind: DepHidden, Name: "quality", Op: "<=", Version: "2.0.7"}},
+		{"! angelsrefining", Dependency{Kind: DepIncompatible, Name: "angelsrefining"}},
+		{"! old-mod < 1.0", Dependency{Kind: DepIncompatible, Name: "old-mod", Op: "<", Version: "1.0"}},
+		{"exact = 1.2.3", Dependency{Kind: DepRequired, Name: "exact", Op: "=", Version: "1.2.3"}},
+		{"Krastorio 2 >= 1.3.0", Dependency{Kind: DepRequired, Name: "Krastorio 2", Op: ">=", Version: "1.3.0"}},
+		{"? Squeak Through", Dependency{Kind: DepOptional, Name: "Squeak Through"}},
+		{"  padded  >=  1.0.0  ", Dependency{Kind: DepRequired, Name: "padded", Op: ">=", Version: "1.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseDependency(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDependencyInvalid(t *testing.T) {
+	for _, in := range []string{"", "?", "(?)", "! ", ">= 1.0.0", "mod >=", "mod >= 1.0 2.0", "mod >= >= 1.0"} {
+		if d, err := ParseDependency(in); err == nil {
+			t.Errorf("ParseDependency(%q) = %+v, want error", in, d)
+		}
+	}
+}
+
+func TestDependencyAllows(t *testing.T) {
+	tests := []struct {
+		dep     string
+		version string
+		want    bool
+	}{
+		{"mod", "0.0.1", true},
+		{"mod < 1.2.0", "1.1.9", true},
+		{"mod < 1.2.0", "1.2.0", false},
+		{"mod <= 1.2.0", "1.2.0", true},
+		{"mod <= 1.2.0", "1.2.1", false},
+		{"mod = 1.2.0", "1.2.0", true},
+		{"mod = 1.2.0", "1.2.1", false},
+		{"mod >= 1.2.0", "1.2.0", true},
+		{"mod >= 1.2.0", "1.1.10", false},
+		{"mod > 1.2.0", "1.10.0", true}, // сравнение по числам, не по строкам
+		{"mod > 1.2.0", "1.2.0", false},
+		{"mod >= 1.2", "1.2.0", true}, // недостающий компонент — ноль
+	}
+	for _, tt := range tests {
+		d, err := ParseDependency(tt.dep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := d.Allows(tt.version); got != tt.want {
+			t.Errorf("%q allows %s = %v, want %v", tt.dep, tt.version, got, tt.want)
+		}
+	}
+}
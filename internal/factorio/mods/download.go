@@ -0,0 +1,179 @@
+package mods
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDownloadWorkers is how many mods are downloaded at once unless
+// SetDownloadWorkers says otherwise.
+const DefaultDownloadWorkers = 4
+
+// bytesProgressInterval limits how often progress is reported while a zip is being
+// written; finished mods are reported right away.
+const bytesProgressInterval = time.Second
+
+// Progress is reported while SyncMods or Update downloads mods.
+type Progress struct {
+	Done, Total int   // обработанные (скачанные или неудавшиеся) моды из тех, что нужно скачать
+	Bytes       int64 // скачано байт, включая незавершённые загрузки
+}
+
+// ProgressFunc receives download progress. Calls are serialized and made from their
+// own goroutine, so a slow callback does not hold up the downloads; updates that
+// arrive meanwhile are merged into the next call. The first call has Done == 0, the
+// last one Done == Total.
+type ProgressFunc func(Progress)
+
+// downloadJob is one mod version to fetch.
+type downloadJob struct {
+	name, version string
+	release       *modRelease // nil — релиз ещё не запрашивался у портала
+}
+
+// downloadResult is the outcome of a downloadJob.
+type downloadResult struct {
+	entry LockEntry
+	err   error
+}
+
+// downloadAll downloads jobs on at most m.workers goroutines and returns the results
+// by mod name. onProgress may be nil.
+func (m *Manager) downloadAll(ctx context.Context, jobs []downloadJob, onProgress ProgressFunc) map[string]downloadResult {
+	results := make(map[string]downloadResult, len(jobs))
+	if len(jobs) == 0 {
+		return results
+	}
+
+	progress := newProgressReporter(onProgress, len(jobs))
+	defer progress.close()
+
+	var (
+		mu sync.Mutex // results
+		wg sync.WaitGroup
+	)
+	queue := make(chan downloadJob)
+	workers := min(m.workers, len(jobs))
+	started := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				log.Printf("mods: скачиваю %s %s...", job.name, job.version)
+				entry, err := m.downloadMod(ctx, job, progress)
+				if err == nil {
+					log.Printf("mods: %s %s скачан", job.name, entry.Version)
+				}
+				mu.Lock()
+				results[job.name] = downloadResult{entry: entry, err: err}
+				mu.Unlock()
+				progress.finishOne()
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	log.Printf("mods: загрузка %d модов заняла %s (потоков: %d)", len(jobs), time.Since(started).Round(time.Second), workers)
+	return results
+}
+
+// progressReporter counts finished mods and downloaded bytes and passes snapshots to
+// a ProgressFunc from its own goroutine.
+type progressReporter struct {
+	fn        ProgressFunc
+	total     int
+	done      atomic.Int64
+	bytes     atomic.Int64
+	lastBytes atomic.Int64 // UnixNano последнего отчёта о байтах
+	wake      chan struct{}
+	finished  chan struct{}
+}
+
+// newProgressReporter reports the initial Done == 0 right away, before any download
+// starts, and the rest from a goroutine until close.
+func newProgressReporter(fn ProgressFunc, total int) *progressReporter {
+	p := &progressReporter{
+		fn:       fn,
+		total:    total,
+		wake:     make(chan struct{}, 1),
+		finished: make(chan struct{}),
+	}
+	first := p.snapshot()
+	if fn != nil {
+		fn(first)
+	}
+	go p.run(first)
+	return p
+}
+
+func (p *progressReporter) run(last Progress) {
+	defer close(p.finished)
+	report := func() {
+		if pr := p.snapshot(); p.fn != nil && pr != last {
+			p.fn(pr)
+			last = pr
+		}
+	}
+	for range p.wake {
+		report()
+	}
+	report()
+}
+
+func (p *progressReporter) snapshot() Progress {
+	return Progress{Done: int(p.done.Load()), Total: p.total, Bytes: p.bytes.Load()}
+}
+
+// notify wakes the reporting goroutine unless it already has an update pending.
+func (p *progressReporter) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *progressReporter) finishOne() {
+	p.done.Add(1)
+	p.notify()
+}
+
+// addBytes counts downloaded bytes, reporting them at most every bytesProgressInterval.
+func (p *progressReporter) addBytes(n int64) {
+	p.bytes.Add(n)
+	now, last := time.Now().UnixNano(), p.lastBytes.Load()
+	if now-last >= int64(bytesProgressInterval) && p.lastBytes.CompareAndSwap(last, now) {
+		p.notify()
+	}
+}
+
+// close delivers the final snapshot and waits for the callback to return.
+func (p *progressReporter) close() {
+	close(p.wake)
+	<-p.finished
+}
+
+// countingWriter reports the bytes written through it as download progress.
+type countingWriter struct {
+	progress *progressReporter
+	written  int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	w.progress.addBytes(int64(len(p)))
+	return len(p), nil
+}
+
+// undo takes the bytes of a failed attempt back out of the progress.
+func (w *countingWriter) undo() {
+	w.progress.bytes.Add(-w.written)
+	w.written = 0
+}
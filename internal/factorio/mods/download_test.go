@@ -0,0 +1,80 @@
+package mods
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSyncDownloadsResolvedReleaseWithoutRefetching(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha": {{version: "1.0.0"}, {version: "1.1.0"}},
+	})
+	m := newTestManager(t, portal, modListEntry{Name: "alpha", Enabled: true})
+
+	var (
+		mu    sync.Mutex
+		calls []Progress
+	)
+	n, failures, err := m.SyncMods(context.Background(), func(p Progress) {
+		mu.Lock()
+		calls = append(calls, p)
+		mu.Unlock()
+	})
+	if err != nil || len(failures) > 0 {
+		t.Fatalf("SyncMods: %v, failures %v", err, failures)
+	}
+	if n != 1 {
+		t.Errorf("downloaded %d mods, want 1", n)
+	}
+	if got := portal.count("/api/mods/alpha/full"); got != 1 {
+		t.Errorf("portal asked for alpha releases %d times, want 1", got)
+	}
+	lock, err := m.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := lock.Mods["alpha"].Version; v != "1.1.0" {
+		t.Errorf("locked alpha %s, want 1.1.0", v)
+	}
+
+	if len(calls) == 0 {
+		t.Fatal("no progress reported")
+	}
+	if first, last := calls[0], calls[len(calls)-1]; first.Done != 0 || last.Done != 1 || last.Total != 1 || last.Bytes == 0 {
+		t.Errorf("progress first %+v, last %+v", first, last)
+	}
+}
+
+func TestProgressReporterDoesNotBlockOnSlowCallback(t *testing.T) {
+	const slow = 200 * time.Millisecond
+	var (
+		mu    sync.Mutex
+		calls []Progress
+	)
+	p := newProgressReporter(func(pr Progress) {
+		mu.Lock()
+		calls = append(calls, pr)
+		mu.Unlock()
+		time.Sleep(slow)
+	}, 3)
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		p.addBytes(100)
+		p.finishOne()
+	}
+	if elapsed := time.Since(started); elapsed >= slow {
+		t.Errorf("workers waited %s for the callback", elapsed)
+	}
+	p.close()
+
+	// Пока первый вызов спит, остальные обновления сливаются в один.
+	if len(calls) > 3 {
+		t.Errorf("%d callback calls, want updates merged", len(calls))
+	}
+	if last := calls[len(calls)-1]; last != (Progress{Done: 3, Total: 3, Bytes: 300}) {
+		t.Errorf("last progress %+v, want all done", last)
+	}
+}
@@ -0,0 +1,111 @@
+package mods
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+// Lock records the exact mod set installed on the server, so the mods dir can be
+// reproduced: SyncMods installs exactly these files and removes everything else.
+type Lock struct {
+	FactorioVersion string               `json:"factorio_version"`
+	Mods            map[string]LockEntry `json:"mods"`
+}
+
+// LockEntry is one installed mod.
+type LockEntry struct {
+	Version  string `json:"version"`
+	FileName string `json:"file_name"`
+	SHA1     string `json:"sha1"`
+}
+
+// Lock returns the current lock file; an empty lock if it does not exist yet.
+func (m *Manager) Lock() (*Lock, error) {
+	lock := &Lock{Mods: make(map[string]LockEntry)}
+	data, err := os.ReadFile(m.lockFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("чтение %s: %w", filepath.Base(m.lockFile), err)
+	}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("разбор %s: %w", filepath.Base(m.lockFile), err)
+	}
+	if lock.Mods == nil {
+		lock.Mods = make(map[string]LockEntry)
+	}
+	return lock, nil
+}
+
+func (m *Manager) writeLock(lock *Lock) error {
+	lock.FactorioVersion = m.factorioVersion
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	return safefile.WriteFile(m.lockFile, append(data, '\n'), 0644)
+}
+
+// Names returns the locked mod names in alphabetical order.
+func (l *Lock) Names() []string {
+	names := make([]string, 0, len(l.Mods))
+	for name := range l.Mods {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return strings.ToLower(names[i]) < strings.ToLower(names[j]) })
+	return names
+}
+
+// installedZip is a "<name>_<version>.zip" file in the mods dir.
+type installedZip struct {
+	Name, Version, FileName string
+}
+
+// installedZips lists mod zips in the mods dir by lower-cased mod name. Mod names may
+// contain underscores, so the version is what follows the last one.
+func (m *Manager) installedZips() (map[string][]installedZip, error) {
+	entries, err := os.ReadDir(m.modsDir)
+	if err != nil {
+		return nil, err
+	}
+	zips := make(map[string][]installedZip)
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".zip") {
+			continue
+		}
+		base := strings.TrimSuffix(e.Name(), ".zip")
+		i := strings.LastIndex(base, "_")
+		if i <= 0 {
+			continue
+		}
+		z := installedZip{Name: base[:i], Version: base[i+1:], FileName: e.Name()}
+		key := strings.ToLower(z.Name)
+		zips[key] = append(zips[key], z)
+	}
+	return zips, nil
+}
+
+// fileSHA1 returns the hex SHA-1 of a file in the mods dir.
+func (m *Manager) fileSHA1(fileName string) (string, error) {
+	f, err := os.Open(filepath.Join(m.modsDir, fileName))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -2,7 +2,10 @@ package mods
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,8 +13,12 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
 )
 
 const modPortalBase = "https://mods.factorio.com"
@@ -28,67 +35,219 @@ var builtinMods = map[string]bool{
 type Manager struct {
 	modsDir         string
 	modListFile     string
+	lockFile        string
 	username        string
 	token           string
 	factorioVersion string
 	httpClient      *http.Client
+	workers         int
+
+	mu sync.Mutex // SyncMods, Update и Verify меняют папку модов и lock-файл
 }
 
-func NewManager(modsDir, modListFile, username, token, factorioVersion string) *Manager {
+func NewManager(modsDir, modListFile, lockFile, username, token, factorioVersion string) *Manager {
 	return &Manager{
 		modsDir:         modsDir,
 		modListFile:     modListFile,
+		lockFile:        lockFile,
 		username:        username,
 		token:           token,
 		factorioVersion: factorioVersion,
 		httpClient:      &http.Client{Timeout: 10 * time.Minute},
+		workers:         DefaultDownloadWorkers,
 	}
 }
 
-// SyncMods downloads any mods listed in mod-list.json that are not yet present in modsDir.
+// SetDownloadWorkers sets how many mods are downloaded at once; values below 1 mean 1.
+func (m *Manager) SetDownloadWorkers(n int) {
+	m.workers = max(n, 1)
+}
+
+// SyncMods converges the mods dir to the lock file. Enabled mods in mod-list.json
+// and their required dependencies are resolved first; dependencies missing from
+// mod-list.json are added to it as enabled, and conflicts (incompatible mods, a
+// required dependency switched off, no version satisfying every constraint) are
+// returned as *ConflictError before anything is downloaded.
+//
+// For every mod the version is, in order of preference: the "version" pinned in
+// mod-list.json, the locked or installed version if it satisfies the dependency
+// constraints, or the latest release for factorioVersion that does. Missing zips are
+// downloaded, other versions and mods that are no longer needed are removed, and the
+// lock is updated.
+// Downloads run in parallel and are reported to onProgress, which may be nil.
 // Returns (downloaded count, list of failed mod names, fatal error).
 // If credentials are not configured, returns (0, nil, nil) and logs a warning.
-func (m *Manager) SyncMods(ctx context.Context) (int, []string, error) {
+func (m *Manager) SyncMods(ctx context.Context, onProgress ProgressFunc) (int, []string, error) {
 	if m.username == "" || m.token == "" {
 		log.Println("mods: FACTORIO_MOD_PORTAL_USER / FACTORIO_MOD_PORTAL_TOKEN не заданы, синхронизация пропущена")
 		return 0, nil, nil
 	}
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	downloaded, failed, err := m.sync(ctx, nil, onProgress)
+	var failures []string
+	for name := range failed {
+		failures = append(failures, name)
+	}
+	sort.Strings(failures)
+	return downloaded, failures, err
+}
+
+// sync does the work of SyncMods; mods in upgrade (lower-cased names) get the latest
+// release that satisfies the constraints instead of the installed one. Returns the
+// number of downloads and the error for every mod that could not be installed.
+// The caller holds m.mu.
+func (m *Manager) sync(ctx context.Context, upgrade map[string]bool, onProgress ProgressFunc) (int, map[string]error, error) {
 	list, err := m.readModList()
 	if err != nil {
 		return 0, nil, fmt.Errorf("чтение mod-list.json: %w", err)
 	}
+	lock, err := m.Lock()
+	if err != nil {
+		return 0, nil, err
+	}
+	m.removeStaleTemps()
+	zips, err := m.installedZips()
+	if err != nil {
+		return 0, nil, fmt.Errorf("сканирование папки модов: %w", err)
+	}
 
-	downloaded := 0
-	var failures []string
+	res, err := m.resolve(ctx, list, lock, zips, upgrade)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(res.added) > 0 {
+		for _, name := range res.added {
+			list.Mods = append(list.Mods, modListEntry{Name: name, Enabled: true})
+		}
+		if err := m.writeModList(list); err != nil {
+			return 0, nil, fmt.Errorf("запись mod-list.json: %w", err)
+		}
+		log.Printf("mods: в mod-list.json добавлены зависимости: %s", strings.Join(res.added, ", "))
+	}
 
-	for _, entry := range list.Mods {
-		if !entry.Enabled {
+	failures := make(map[string]error)
+	wanted := make(map[string]bool) // имена модов в нижнем регистре
+	ready := make(map[string]LockEntry)
+	var jobs []downloadJob
+
+	for _, name := range res.order {
+		wanted[strings.ToLower(name)] = true
+
+		if err, failed := res.failures[name]; failed {
+			log.Printf("mods: не удалось выбрать версию %s: %v", name, err)
+			failures[name] = err
 			continue
 		}
-		if builtinMods[entry.Name] {
+		version := res.versions[name]
+		entry, ok, err := m.installedVersion(name, version, lock.Mods[name], zips[strings.ToLower(name)])
+		switch {
+		case err != nil:
+			log.Printf("mods: ошибка проверки %s: %v", name, err)
+			failures[name] = err
+		case ok:
+			ready[name] = entry
+		default:
+			// Релизы, уже полученные при разрешении зависимостей, второй раз не запрашиваем.
+			jobs = append(jobs, downloadJob{name: name, version: version, release: findRelease(res.releases[name], version)})
+		}
+	}
+
+	downloaded := 0
+	for name, r := range m.downloadAll(ctx, jobs, onProgress) {
+		if r.err != nil {
+			log.Printf("mods: ошибка загрузки %s: %v", name, r.err)
+			failures[name] = r.err
 			continue
 		}
+		downloaded++
+		ready[name] = r.entry
+	}
+
+	// Другие версии удаляем, только когда нужная уже на месте.
+	for name, entry := range ready {
+		lock.Mods[name] = entry
+		for _, z := range zips[strings.ToLower(name)] {
+			if z.FileName != entry.FileName {
+				m.removeZip(z.FileName, "заменён версией "+entry.Version)
+			}
+		}
+	}
 
-		present, err := m.modAlreadyPresent(entry.Name)
-		if err != nil {
-			return downloaded, failures, fmt.Errorf("сканирование папки модов: %w", err)
+	// Всё, что не включено и не нужно как зависимость, из папки и lock-файла убираем.
+	for name := range lock.Mods {
+		if !wanted[strings.ToLower(name)] {
+			delete(lock.Mods, name)
 		}
-		if present {
+	}
+	for key, files := range zips {
+		if wanted[key] {
 			continue
 		}
+		for _, z := range files {
+			m.removeZip(z.FileName, "мод не включён в mod-list.json")
+		}
+	}
+
+	if err := m.writeLock(lock); err != nil {
+		return downloaded, failures, fmt.Errorf("запись lock-файла: %w", err)
+	}
+	return downloaded, failures, nil
+}
 
-		log.Printf("mods: скачиваю %s...", entry.Name)
-		if err := m.downloadMod(ctx, entry.Name); err != nil {
-			log.Printf("mods: ошибка загрузки %s: %v", entry.Name, err)
-			failures = append(failures, entry.Name)
+// installedVersion returns the lock entry for version if its zip is already in the
+// mods dir and, when the lock has a checksum for it, matches it.
+func (m *Manager) installedVersion(name, version string, locked LockEntry, installed []installedZip) (LockEntry, bool, error) {
+	for _, z := range installed {
+		if z.Version != version {
 			continue
 		}
-		log.Printf("mods: %s скачан", entry.Name)
-		downloaded++
+		sum, err := m.fileSHA1(z.FileName)
+		if err != nil {
+			return LockEntry{}, false, err
+		}
+		if locked.Version == version && locked.SHA1 != "" && !strings.EqualFold(sum, locked.SHA1) {
+			log.Printf("mods: WARN %s не совпадает с mod-lock.json, скачаю заново", z.FileName)
+			continue
+		}
+		return LockEntry{Version: z.Version, FileName: z.FileName, SHA1: sum}, true, nil
 	}
+	return LockEntry{}, false, nil
+}
 
-	return downloaded, failures, nil
+func (m *Manager) removeZip(fileName, reason string) {
+	if err := os.Remove(filepath.Join(m.modsDir, fileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("mods: WARN не удалось удалить %s: %v", fileName, err)
+		return
+	}
+	log.Printf("mods: удалён %s (%s)", fileName, reason)
+}
+
+// EnabledModVersions returns every mod enabled in mod-list.json, builtin ones included,
+// with the version recorded in the lock file. Builtin mods and mods that are not
+// installed yet have an empty version.
+func (m *Manager) EnabledModVersions() (map[string]string, error) {
+	list, err := m.readModList()
+	if err != nil {
+		return nil, fmt.Errorf("чтение mod-list.json: %w", err)
+	}
+	lock, err := m.Lock()
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string)
+	for _, entry := range list.Mods {
+		if !entry.Enabled {
+			continue
+		}
+		versions[entry.Name] = ""
+		if !builtinMods[entry.Name] {
+			versions[entry.Name] = lock.Mods[entry.Name].Version
+		}
+	}
+	return versions, nil
 }
 
 // ── internal ──────────────────────────────────────────────────────────────────
@@ -100,6 +259,8 @@ type modList struct {
 type modListEntry struct {
 	Name    string `json:"name"`
 	Enabled bool   `json:"enabled"`
+	// Version pins the mod to an exact release; empty means the locked or latest one.
+	Version string `json:"version,omitempty"`
 }
 
 type modInfo struct {
@@ -110,11 +271,13 @@ type modRelease struct {
 	DownloadURL string      `json:"download_url"`
 	FileName    string      `json:"file_name"`
 	Version     string      `json:"version"`
+	SHA1        string      `json:"sha1"`
 	InfoJSON    modInfoJSON `json:"info_json"`
 }
 
 type modInfoJSON struct {
-	FactorioVersion string `json:"factorio_version"`
+	FactorioVersion string   `json:"factorio_version"`
+	Dependencies    []string `json:"dependencies"`
 }
 
 func (m *Manager) readModList() (*modList, error) {
@@ -129,52 +292,82 @@ func (m *Manager) readModList() (*modList, error) {
 	return &list, nil
 }
 
-// modAlreadyPresent checks if any file named "{modName}_*.zip" exists in modsDir.
-func (m *Manager) modAlreadyPresent(modName string) (bool, error) {
-	entries, err := os.ReadDir(m.modsDir)
+func (m *Manager) writeModList(list *modList) error {
+	data, err := json.MarshalIndent(list, "", "  ")
 	if err != nil {
-		return false, err
-	}
-	prefix := strings.ToLower(modName) + "_"
-	for _, e := range entries {
-		if e.IsDir() || !strings.HasSuffix(e.Name(), ".zip") {
-			continue
-		}
-		if strings.HasPrefix(strings.ToLower(e.Name()), prefix) {
-			return true, nil
-		}
+		return err
 	}
-	return false, nil
+	return safefile.WriteFile(m.modListFile, append(data, '\n'), 0644)
+}
+
+// fetchModInfo queries the mod portal for the releases of a mod, retrying transient
+// errors. Only the "full" endpoint includes info_json.dependencies.
+func (m *Manager) fetchModInfo(ctx context.Context, modName string) (*modInfo, error) {
+	var info *modInfo
+	err := retry(ctx, "запрос "+modName, func() (err error) {
+		info, err = m.fetchModInfoOnce(ctx, modName)
+		return err
+	})
+	return info, err
 }
 
-func (m *Manager) downloadMod(ctx context.Context, modName string) error {
-	// Fetch mod release list from the portal
-	apiURL := fmt.Sprintf("%s/api/mods/%s", modPortalBase, url.PathEscape(modName))
+func (m *Manager) fetchModInfoOnce(ctx context.Context, modName string) (*modInfo, error) {
+	apiURL := fmt.Sprintf("%s/api/mods/%s/full", modPortalBase, url.PathEscape(modName))
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	resp, err := m.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("запрос к mod portal: %w", err)
+		return nil, requestError(ctx, "запрос к mod portal: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("mod portal вернул %d для %q", resp.StatusCode, modName)
+		return nil, statusError(resp.StatusCode, "mod portal вернул %d для %q", resp.StatusCode, modName)
 	}
 
 	var info modInfo
 	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
-		return fmt.Errorf("декодирование ответа: %w", err)
+		return nil, requestError(ctx, "декодирование ответа: %w", err)
 	}
+	return &info, nil
+}
 
-	release := latestRelease(info.Releases, m.factorioVersion)
+// downloadMod downloads the release of job, looking it up on the portal if the job
+// does not carry it, and returns its lock entry. An empty version means the latest
+// release for factorioVersion. Transient errors are retried.
+func (m *Manager) downloadMod(ctx context.Context, job downloadJob, progress *progressReporter) (LockEntry, error) {
+	release := job.release
 	if release == nil {
-		return fmt.Errorf("нет релиза для Factorio %s", m.factorioVersion)
+		info, err := m.fetchModInfo(ctx, job.name)
+		if err != nil {
+			return LockEntry{}, err
+		}
+		if job.version == "" {
+			release = latestRelease(info.Releases, m.factorioVersion)
+			if release == nil {
+				return LockEntry{}, fmt.Errorf("нет релиза для Factorio %s", m.factorioVersion)
+			}
+		} else {
+			release = findRelease(info.Releases, job.version)
+			if release == nil {
+				return LockEntry{}, fmt.Errorf("нет версии %s", job.version)
+			}
+		}
 	}
 
+	var entry LockEntry
+	err := retry(ctx, "скачивание "+release.FileName, func() (err error) {
+		entry, err = m.downloadRelease(ctx, job.name, release, progress)
+		return err
+	})
+	return entry, err
+}
+
+// downloadRelease makes one attempt to download a release into the mods dir.
+func (m *Manager) downloadRelease(ctx context.Context, modName string, release *modRelease, progress *progressReporter) (LockEntry, error) {
 	// Build download URL with auth
 	downloadURL := fmt.Sprintf("%s%s?username=%s&token=%s",
 		modPortalBase,
@@ -183,33 +376,57 @@ func (m *Manager) downloadMod(ctx context.Context, modName string) error {
 		url.QueryEscape(m.token),
 	)
 
-	req2, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
 	if err != nil {
-		return err
+		return LockEntry{}, err
 	}
 
-	resp2, err := m.httpClient.Do(req2)
+	resp, err := m.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("скачивание архива: %w", err)
+		return LockEntry{}, requestError(ctx, "скачивание архива: %w", err)
 	}
-	defer resp2.Body.Close()
+	defer resp.Body.Close()
 
-	if resp2.StatusCode != http.StatusOK {
-		return fmt.Errorf("скачивание вернуло %d", resp2.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		return LockEntry{}, statusError(resp.StatusCode, "скачивание вернуло %d", resp.StatusCode)
 	}
 
-	dest := filepath.Join(m.modsDir, release.FileName)
-	f, err := os.Create(dest)
+	// Пишем во временный файл: обрыв или неверная сумма не оставят битый zip под
+	// настоящим именем.
+	f, err := safefile.Create(filepath.Join(m.modsDir, release.FileName), 0644)
 	if err != nil {
-		return fmt.Errorf("создание файла: %w", err)
+		return LockEntry{}, fmt.Errorf("создание файла: %w", err)
 	}
-	defer f.Close()
-
-	if _, err := io.Copy(f, resp2.Body); err != nil {
-		os.Remove(dest) // удаляем неполный файл
-		return fmt.Errorf("запись файла: %w", err)
+	defer f.Abort()
+	f.NoBackup = true
+
+	h := sha1.New()
+	counter := &countingWriter{progress: progress}
+	if _, err := io.Copy(io.MultiWriter(f, h, counter), resp.Body); err != nil {
+		counter.undo()
+		return LockEntry{}, requestError(ctx, "запись файла: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if release.SHA1 == "" {
+		log.Printf("mods: WARN портал не вернул sha1 для %s %s, проверка пропущена", modName, release.Version)
+	} else if !strings.EqualFold(sum, release.SHA1) {
+		counter.undo()
+		return LockEntry{}, transient(fmt.Errorf("SHA-1 не совпадает: ожидался %s, получен %s", release.SHA1, sum))
+	}
+	if err := f.Commit(); err != nil {
+		return LockEntry{}, fmt.Errorf("запись файла: %w", err)
 	}
 
+	return LockEntry{Version: release.Version, FileName: release.FileName, SHA1: sum}, nil
+}
+
+// findRelease returns the release with exactly the given version.
+func findRelease(releases []modRelease, version string) *modRelease {
+	for i := range releases {
+		if releases[i].Version == version {
+			return &releases[i]
+		}
+	}
 	return nil
 }
 
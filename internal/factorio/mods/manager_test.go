@@ -0,0 +1,200 @@
+package mods
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// modFiles lists the zips in the mods dir.
+func modFiles(t *testing.T, m *Manager) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(m.modsDir, "*.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range matches {
+		names = append(names, filepath.Base(p))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func setModList(t *testing.T, m *Manager, list ...modListEntry) {
+	t.Helper()
+	if err := m.writeModList(&modList{Mods: append([]modListEntry{{Name: "base", Enabled: true}}, list...)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncHonorsPinnedVersion(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha": {{version: "1.0.0"}, {version: "1.1.0"}},
+	})
+	m := syncedManager(t, portal, modListEntry{Name: "alpha", Enabled: true, Version: "1.0.0"})
+	if got := modFiles(t, m); strings.Join(got, ",") != "alpha_1.0.0.zip" {
+		t.Fatalf("mods dir = %q, want the pinned release", got)
+	}
+
+	// Закреплённую версию меняют в mod-list.json — старая заменяется новой.
+	setModList(t, m, modListEntry{Name: "alpha", Enabled: true, Version: "1.1.0"})
+	if _, failures, err := m.SyncMods(context.Background(), nil); err != nil || len(failures) > 0 {
+		t.Fatalf("SyncMods: %v, failures %v", err, failures)
+	}
+	if got := modFiles(t, m); strings.Join(got, ",") != "alpha_1.1.0.zip" {
+		t.Errorf("mods dir = %q, want only the newly pinned release", got)
+	}
+
+	// Без закрепления остаётся версия из lock-файла, даже если вышла новее.
+	setModList(t, m, modListEntry{Name: "alpha", Enabled: true, Version: "1.0.0"})
+	m.SyncMods(context.Background(), nil)
+	setModList(t, m, modListEntry{Name: "alpha", Enabled: true})
+	m.SyncMods(context.Background(), nil)
+	lock, err := m.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := lock.Mods["alpha"].Version; v != "1.0.0" {
+		t.Errorf("locked alpha %s after unpinning, want the locked 1.0.0", v)
+	}
+
+	// Закреплённой версии нет на портале — это ошибка мода, а не повод взять другую.
+	setModList(t, m, modListEntry{Name: "alpha", Enabled: true, Version: "9.9.9"})
+	if _, failures, err := m.SyncMods(context.Background(), nil); err == nil && len(failures) == 0 {
+		t.Error("SyncMods accepted a pinned version the portal does not have")
+	}
+	if got := modFiles(t, m); strings.Join(got, ",") != "alpha_1.0.0.zip" {
+		t.Errorf("mods dir = %q after a failed pin, want the installed release kept", got)
+	}
+}
+
+func TestSyncRemovesZipsNotInLock(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha":    {{version: "1.0.0"}},
+		"beta_mod": {{version: "2.0.0"}},
+	})
+	m := newTestManager(t, portal, modListEntry{Name: "alpha", Enabled: true}, modListEntry{Name: "beta_mod", Enabled: true})
+	for _, name := range []string{"omega_1.0.0.zip", "alpha_0.9.0.zip", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(m.modsDir, name), []byte("stray"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, failures, err := m.SyncMods(context.Background(), nil); err != nil || len(failures) > 0 {
+		t.Fatalf("SyncMods: %v, failures %v", err, failures)
+	}
+	if got := modFiles(t, m); strings.Join(got, ",") != "alpha_1.0.0.zip,beta_mod_2.0.0.zip" {
+		t.Errorf("mods dir = %q, want only the locked zips", got)
+	}
+	if _, err := os.Stat(filepath.Join(m.modsDir, "notes.txt")); err != nil {
+		t.Errorf("non-zip file removed: %v", err)
+	}
+
+	// Выключенный мод уходит и из папки, и из lock-файла.
+	setModList(t, m, modListEntry{Name: "alpha", Enabled: true}, modListEntry{Name: "beta_mod", Enabled: false})
+	if _, _, err := m.SyncMods(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := m.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := lock.Names(); strings.Join(names, ",") != "alpha" {
+		t.Errorf("locked mods = %q, want only alpha", names)
+	}
+	if got := modFiles(t, m); strings.Join(got, ",") != "alpha_1.0.0.zip" {
+		t.Errorf("mods dir = %q, want the disabled mod removed", got)
+	}
+}
+
+func TestSyncReinstallsOnChecksumMismatch(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{"alpha": {{version: "1.0.0"}}})
+	m := syncedManager(t, portal, modListEntry{Name: "alpha", Enabled: true})
+	zipPath := filepath.Join(m.modsDir, "alpha_1.0.0.zip")
+
+	// Совпадающий SHA-1 — ничего не качаем.
+	if n, _, err := m.SyncMods(context.Background(), nil); err != nil || n != 0 {
+		t.Fatalf("SyncMods of an intact mods dir: %d downloads, %v", n, err)
+	}
+
+	if err := os.WriteFile(zipPath, []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	n, failures, err := m.SyncMods(context.Background(), nil)
+	if err != nil || len(failures) > 0 || n != 1 {
+		t.Fatalf("SyncMods: %d downloads, %v, failures %v; want alpha reinstalled", n, err, failures)
+	}
+	got, err := os.ReadFile(zipPath)
+	if err != nil || !bytes.Equal(got, modZip("alpha", fakeRelease{version: "1.0.0"})) {
+		t.Errorf("alpha zip not restored (%v)", err)
+	}
+	if c := portal.count("/download/alpha/1.0.0"); c != 2 {
+		t.Errorf("alpha downloaded %d times, want 2", c)
+	}
+}
+
+func TestLock(t *testing.T) {
+	m := newTestManager(t, newFakePortal(nil))
+
+	lock, err := m.Lock()
+	if err != nil || lock.Mods == nil || len(lock.Mods) != 0 {
+		t.Fatalf("missing lock file: %+v, %v; want an empty lock", lock, err)
+	}
+
+	lock.Mods["zeta"] = LockEntry{Version: "1.0.0", FileName: "zeta_1.0.0.zip"}
+	lock.Mods["Alpha"] = LockEntry{Version: "0.1.0", FileName: "Alpha_0.1.0.zip"}
+	lock.Mods["beta"] = LockEntry{Version: "2.0.0", FileName: "beta_2.0.0.zip"}
+	if err := m.writeLock(lock); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := m.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reread.FactorioVersion != "2.0" || strings.Join(reread.Names(), ",") != "Alpha,beta,zeta" {
+		t.Errorf("lock = %+v, names %q", reread, reread.Names())
+	}
+
+	if err := os.WriteFile(m.lockFile, []byte(`{"mods":null}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if lock, err := m.Lock(); err != nil || lock.Mods == nil {
+		t.Errorf("lock with null mods: %+v, %v", lock, err)
+	}
+	if err := os.WriteFile(m.lockFile, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Lock(); err == nil {
+		t.Error("broken lock file parsed")
+	}
+}
+
+func TestInstalledZips(t *testing.T) {
+	m := newTestManager(t, newFakePortal(nil))
+	for _, name := range []string{"my_mod_1.2.3.zip", "My_Mod_1.3.0.zip", "noversion.zip", "_1.0.0.zip", "flib_0.16.0.zip.tmp"} {
+		if err := os.WriteFile(filepath.Join(m.modsDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(m.modsDir, "dir_1.0.0.zip"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	zips, err := m.installedZips()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zips) != 1 || len(zips["my_mod"]) != 2 {
+		t.Fatalf("zips = %+v, want both versions of my_mod only", zips)
+	}
+	for _, z := range zips["my_mod"] {
+		if z.FileName != z.Name+"_"+z.Version+".zip" || (z.Version != "1.2.3" && z.Version != "1.3.0") {
+			t.Errorf("zip = %+v", z)
+		}
+	}
+}
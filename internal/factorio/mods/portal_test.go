@@ -0,0 +1,128 @@
+package mods
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRelease is one release served by fakePortal.
+type fakeRelease struct {
+	version string
+	deps    []string
+	corrupt bool // download_url отдаёт архив, не совпадающий с опубликованным sha1
+}
+
+// fakePortal stands in for mods.factorio.com: it serves /api/mods/<name>/full and
+// the zips behind download_url, and counts the requests per path.
+type fakePortal struct {
+	mu       sync.Mutex
+	mods     map[string][]fakeRelease
+	requests map[string]int
+}
+
+func newFakePortal(mods map[string][]fakeRelease) *fakePortal {
+	return &fakePortal{mods: mods, requests: make(map[string]int)}
+}
+
+func (p *fakePortal) count(path string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[path]
+}
+
+// publish adds a release of name, as if its author uploaded a new version.
+func (p *fakePortal) publish(name string, r fakeRelease) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mods[name] = append(p.mods[name], r)
+}
+
+func (p *fakePortal) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	p.requests[req.URL.Path]++
+	mods := make(map[string][]fakeRelease, len(p.mods))
+	for name, releases := range p.mods {
+		mods[name] = append([]fakeRelease(nil), releases...)
+	}
+	p.mu.Unlock()
+
+	if name, ok := strings.CutPrefix(req.URL.Path, "/api/mods/"); ok {
+		name = strings.TrimSuffix(name, "/full")
+		releases, ok := mods[name]
+		if !ok {
+			return respond(http.StatusNotFound, nil), nil
+		}
+		var info modInfo
+		for _, r := range releases {
+			data := modZip(name, r)
+			sum := sha1.Sum(data)
+			info.Releases = append(info.Releases, modRelease{
+				DownloadURL: "/download/" + name + "/" + r.version,
+				FileName:    name + "_" + r.version + ".zip",
+				Version:     r.version,
+				SHA1:        hex.EncodeToString(sum[:]),
+				InfoJSON:    modInfoJSON{FactorioVersion: "2.0", Dependencies: r.deps},
+			})
+		}
+		data, _ := json.Marshal(info)
+		return respond(http.StatusOK, data), nil
+	}
+	if rest, ok := strings.CutPrefix(req.URL.Path, "/download/"); ok {
+		name, version, _ := strings.Cut(rest, "/")
+		for _, r := range mods[name] {
+			if r.version == version && r.corrupt {
+				return respond(http.StatusOK, append(modZip(name, r), "garbage"...)), nil
+			}
+			if r.version == version {
+				return respond(http.StatusOK, modZip(name, r)), nil
+			}
+		}
+	}
+	return respond(http.StatusNotFound, nil), nil
+}
+
+func respond(status int, body []byte) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader(body)), Header: make(http.Header)}
+}
+
+// modZip builds a mod archive with an info.json listing the release dependencies.
+func modZip(name string, r fakeRelease) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create(fmt.Sprintf("%s_%s/info.json", name, r.version))
+	deps := r.deps
+	if deps == nil {
+		deps = []string{"base"}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"name": name, "version": r.version, "dependencies": deps})
+	zw.Close()
+	return buf.Bytes()
+}
+
+// newTestManager creates a Manager backed by portal with the given mod-list.json entries.
+func newTestManager(t *testing.T, portal *fakePortal, list ...modListEntry) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	m := NewManager(dir, filepath.Join(dir, "mod-list.json"), filepath.Join(dir, "mod-lock.json"), "user", "token", "2.0")
+	m.httpClient = &http.Client{Transport: portal}
+
+	data, err := json.Marshal(modList{Mods: append([]modListEntry{{Name: "base", Enabled: true}}, list...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(m.modListFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,396 @@
+package mods
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ConflictError lists dependency problems that make the mod set unusable: the server
+// would refuse to start with it, so nothing is downloaded.
+type ConflictError struct {
+	Conflicts []string
+}
+
+func (e *ConflictError) Error() string {
+	return "конфликты зависимостей модов: " + strings.Join(e.Conflicts, "; ")
+}
+
+// resolution is the mod set to install: enabled mods plus their required
+// dependencies, each at a version that satisfies every constraint on it.
+type resolution struct {
+	order    []string                // в порядке обнаружения: сначала mod-list.json, затем зависимости
+	versions map[string]string       // имя → выбранная версия
+	added    []string                // обязательные зависимости, которых нет в mod-list.json
+	failures map[string]error        // моды, версию которых не удалось выбрать: портал недоступен и т.п.
+	releases map[string][]modRelease // ответы портала, полученные при разрешении
+}
+
+// choice is the version picked for a mod and the dependencies of that version.
+type choice struct {
+	version string
+	deps    []Dependency
+}
+
+// constraint is a version requirement together with who imposes it, for messages.
+type constraint struct {
+	Dependency
+	from string
+}
+
+// resolveRounds bounds re-resolution: every round can change a version, which can
+// change the constraints; real mod sets settle in two or three rounds.
+const resolveRounds = 20
+
+// resolver picks versions for a sync. Versions already installed are preferred and
+// their dependencies are read from the local zip, so an unchanged mod set resolves
+// without a single portal request.
+type resolver struct {
+	m       *Manager
+	ctx     context.Context
+	list    *modList
+	lock    *Lock
+	zips    map[string][]installedZip
+	upgrade map[string]bool // имена в нижнем регистре: брать последнюю подходящую версию
+
+	entries  map[string]modListEntry // по имени в нижнем регистре
+	releases map[string][]modRelease
+}
+
+func (m *Manager) resolve(ctx context.Context, list *modList, lock *Lock, zips map[string][]installedZip, upgrade map[string]bool) (*resolution, error) {
+	r := &resolver{
+		m: m, ctx: ctx, list: list, lock: lock, zips: zips, upgrade: upgrade,
+		entries:  make(map[string]modListEntry),
+		releases: make(map[string][]modRelease),
+	}
+	for _, e := range list.Mods {
+		r.entries[strings.ToLower(e.Name)] = e
+	}
+	return r.run()
+}
+
+func (r *resolver) run() (*resolution, error) {
+	choices := make(map[string]choice)
+	var (
+		set       []string
+		cons      map[string][]constraint
+		failures  map[string]error
+		conflicts []string
+		stable    bool
+	)
+	for round := 0; round < resolveRounds && !stable; round++ {
+		set, cons = r.collect(choices)
+		stable = true
+		conflicts = nil
+		failures = make(map[string]error)
+		next := make(map[string]choice)
+		for _, name := range set {
+			c, err := r.choose(name, cons[name])
+			var conflict *ConflictError
+			switch {
+			case errors.As(err, &conflict):
+				conflicts = append(conflicts, conflict.Conflicts...)
+				continue
+			case err != nil:
+				if r.ctx.Err() != nil {
+					return nil, r.ctx.Err()
+				}
+				failures[name] = err
+				continue
+			}
+			next[name] = c
+			if prev, ok := choices[name]; !ok || prev.version != c.version {
+				stable = false
+			}
+		}
+		choices = next
+	}
+	if !stable && len(conflicts) == 0 {
+		conflicts = append(conflicts, "версии модов не удалось согласовать")
+	}
+	conflicts = append(conflicts, r.check(set, choices)...)
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, &ConflictError{Conflicts: dedupe(conflicts)}
+	}
+
+	res := &resolution{order: set, versions: make(map[string]string), failures: failures, releases: r.releases}
+	for _, name := range set {
+		if c, ok := choices[name]; ok {
+			res.versions[name] = c.version
+		}
+		if _, listed := r.entries[strings.ToLower(name)]; !listed {
+			res.added = append(res.added, name)
+		}
+	}
+	return res, nil
+}
+
+// collect builds the mod set from the enabled mods and the required dependencies of
+// the current choices, and the version constraints on every mod in it.
+func (r *resolver) collect(choices map[string]choice) ([]string, map[string][]constraint) {
+	var set []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			set = append(set, name)
+		}
+	}
+	for _, e := range r.list.Mods {
+		if e.Enabled && !builtinMods[e.Name] {
+			add(e.Name)
+		}
+	}
+
+	cons := make(map[string][]constraint)
+	var optional []constraint
+	for i := 0; i < len(set); i++ {
+		name := set[i]
+		c, ok := choices[name]
+		if !ok {
+			continue
+		}
+		from := name + " " + c.version
+		for _, d := range c.deps {
+			if builtinMods[d.Name] || d.Kind == DepIncompatible {
+				continue
+			}
+			if d.Kind != DepRequired {
+				optional = append(optional, constraint{d, from})
+				continue
+			}
+			if e, listed := r.entries[strings.ToLower(d.Name)]; listed && !e.Enabled {
+				continue // выключен явно — это конфликт, его покажет check
+			}
+			add(d.Name)
+			cons[canonical(set, d.Name)] = append(cons[canonical(set, d.Name)], constraint{d, from})
+		}
+	}
+	// Optional-зависимость ограничивает версию, только если мод и так стоит.
+	for _, c := range optional {
+		if seen[strings.ToLower(c.Name)] {
+			name := canonical(set, c.Name)
+			cons[name] = append(cons[name], c)
+		}
+	}
+	return set, cons
+}
+
+// choose picks the version of one mod: the pin from mod-list.json, else the locked or
+// installed version if it satisfies the constraints, else the newest release for
+// factorioVersion that does.
+func (r *resolver) choose(name string, cons []constraint) (choice, error) {
+	key := strings.ToLower(name)
+	allows := func(version string) bool {
+		for _, c := range cons {
+			if !c.Allows(version) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if pin := r.entries[key].Version; pin != "" {
+		for _, c := range cons {
+			if !c.Allows(pin) {
+				return choice{}, conflictf("%s закреплён на %s в mod-list.json, а %s требует %s %s", name, pin, c.from, name, c.Constraint())
+			}
+		}
+		return r.choiceFor(name, pin)
+	}
+
+	if !r.upgrade[key] {
+		prefer := r.lock.Mods[name].Version
+		if prefer == "" {
+			for _, z := range r.zips[key] {
+				if prefer == "" || compareVersions(z.Version, prefer) > 0 {
+					prefer = z.Version
+				}
+			}
+		}
+		if prefer != "" && allows(prefer) {
+			if c, err := r.choiceFor(name, prefer); err == nil {
+				return c, nil
+			}
+		}
+	}
+
+	releases, err := r.releasesOf(name)
+	if err != nil {
+		return choice{}, err
+	}
+	var best *modRelease
+	for i := range releases {
+		rel := &releases[i]
+		if rel.InfoJSON.FactorioVersion != r.m.factorioVersion || !allows(rel.Version) {
+			continue
+		}
+		if best == nil || compareVersions(rel.Version, best.Version) > 0 {
+			best = rel
+		}
+	}
+	if best == nil {
+		if len(cons) == 0 {
+			return choice{}, fmt.Errorf("нет релиза для Factorio %s", r.m.factorioVersion)
+		}
+		var reqs []string
+		for _, c := range cons {
+			reqs = append(reqs, fmt.Sprintf("%s требует %s", c.from, c.Constraint()))
+		}
+		return choice{}, conflictf("нет версии %s для Factorio %s, подходящей всем: %s", name, r.m.factorioVersion, strings.Join(reqs, ", "))
+	}
+	deps, err := parseDeps(best.InfoJSON.Dependencies)
+	if err != nil {
+		return choice{}, fmt.Errorf("%s %s: %w", name, best.Version, err)
+	}
+	return choice{version: best.Version, deps: deps}, nil
+}
+
+// choiceFor returns an exact version, reading its dependencies from the installed zip
+// if there is one, from the portal otherwise.
+func (r *resolver) choiceFor(name, version string) (choice, error) {
+	for _, z := range r.zips[strings.ToLower(name)] {
+		if z.Version != version {
+			continue
+		}
+		if deps, err := r.m.zipDependencies(z.FileName); err == nil {
+			return choice{version: version, deps: deps}, nil
+		}
+	}
+	releases, err := r.releasesOf(name)
+	if err != nil {
+		return choice{}, err
+	}
+	rel := findRelease(releases, version)
+	if rel == nil {
+		return choice{}, fmt.Errorf("нет версии %s", version)
+	}
+	deps, err := parseDeps(rel.InfoJSON.Dependencies)
+	if err != nil {
+		return choice{}, fmt.Errorf("%s %s: %w", name, version, err)
+	}
+	return choice{version: version, deps: deps}, nil
+}
+
+func (r *resolver) releasesOf(name string) ([]modRelease, error) {
+	if rel, ok := r.releases[name]; ok {
+		return rel, nil
+	}
+	info, err := r.m.fetchModInfo(r.ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r.releases[name] = info.Releases
+	return info.Releases, nil
+}
+
+// check reports what Factorio itself would refuse: incompatible mods together and
+// required dependencies that are switched off.
+func (r *resolver) check(set []string, choices map[string]choice) []string {
+	enabled := make(map[string]bool)
+	for _, name := range set {
+		enabled[strings.ToLower(name)] = true
+	}
+	for name := range builtinMods {
+		if e, listed := r.entries[name]; !listed || e.Enabled {
+			enabled[name] = true
+		}
+	}
+
+	var conflicts []string
+	for _, name := range set {
+		c, ok := choices[name]
+		if !ok {
+			continue
+		}
+		for _, d := range c.deps {
+			key := strings.ToLower(d.Name)
+			switch {
+			case d.Kind == DepIncompatible && enabled[key]:
+				conflicts = append(conflicts, fmt.Sprintf("%s %s несовместим с %s", name, c.version, d.Name))
+			case d.Kind == DepRequired && !enabled[key]:
+				// Встроенный мод, которого нет в mod-list.json, Factorio считает включённым.
+				if e, listed := r.entries[key]; listed && !e.Enabled {
+					conflicts = append(conflicts, fmt.Sprintf("%s %s требует %s, но он выключен в mod-list.json", name, c.version, d.Name))
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// zipDependencies reads "dependencies" from info.json inside an installed mod zip.
+func (m *Manager) zipDependencies(fileName string) ([]Dependency, error) {
+	zr, err := zip.OpenReader(filepath.Join(m.modsDir, fileName))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		dir, base, _ := strings.Cut(f.Name, "/")
+		if dir == "" || base != "info.json" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		var info struct {
+			Dependencies *[]string `json:"dependencies"`
+		}
+		err = json.NewDecoder(rc).Decode(&info)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: info.json: %w", fileName, err)
+		}
+		if info.Dependencies == nil {
+			return []Dependency{{Name: "base"}}, nil // значение по умолчанию в Factorio
+		}
+		return parseDeps(*info.Dependencies)
+	}
+	return nil, fmt.Errorf("%s: нет info.json", fileName)
+}
+
+func parseDeps(raw []string) ([]Dependency, error) {
+	deps := make([]Dependency, 0, len(raw))
+	for _, s := range raw {
+		d, err := ParseDependency(s)
+		if err != nil {
+			return nil, err
+		}
+		deps = append(deps, d)
+	}
+	return deps, nil
+}
+
+// canonical returns the spelling of name used in set; mod names are matched
+// case-insensitively, like the zip names on disk.
+func canonical(set []string, name string) string {
+	for _, s := range set {
+		if strings.EqualFold(s, name) {
+			return s
+		}
+	}
+	return name
+}
+
+func conflictf(format string, args ...any) error {
+	return &ConflictError{Conflicts: []string{fmt.Sprintf(format, args...)}}
+}
+
+func dedupe(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,115 @@
+package mods
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncPullsInRequiredDependency(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha": {{version: "1.0.0", deps: []string{"base", "beta >= 1.0.0", "? gamma"}}},
+		"beta":  {{version: "0.9.0"}, {version: "1.2.0"}},
+		"gamma": {{version: "1.0.0"}},
+	})
+	m := newTestManager(t, portal, modListEntry{Name: "alpha", Enabled: true})
+
+	if _, failures, err := m.SyncMods(context.Background(), nil); err != nil || len(failures) > 0 {
+		t.Fatalf("SyncMods: %v, failures %v", err, failures)
+	}
+	lock, err := m.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := lock.Mods["beta"].Version; v != "1.2.0" {
+		t.Errorf("beta locked at %q, want 1.2.0", v)
+	}
+	if _, ok := lock.Mods["gamma"]; ok {
+		t.Error("optional dependency gamma was installed")
+	}
+	list, err := m.readModList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, e := range list.Mods {
+		if e.Name == "beta" && e.Enabled {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("beta was not added to mod-list.json")
+	}
+}
+
+func TestSyncConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		portal  map[string][]fakeRelease
+		list    []modListEntry
+		message string
+	}{
+		{
+			name: "disabled required dependency",
+			portal: map[string][]fakeRelease{
+				"alpha": {{version: "1.0.0", deps: []string{"beta"}}},
+				"beta":  {{version: "1.0.0"}},
+			},
+			list:    []modListEntry{{Name: "alpha", Enabled: true}, {Name: "beta", Enabled: false}},
+			message: "alpha 1.0.0 требует beta, но он выключен в mod-list.json",
+		},
+		{
+			name: "incompatible pair",
+			portal: map[string][]fakeRelease{
+				"alpha": {{version: "1.0.0", deps: []string{"! gamma"}}},
+				"gamma": {{version: "1.0.0"}},
+			},
+			list:    []modListEntry{{Name: "alpha", Enabled: true}, {Name: "gamma", Enabled: true}},
+			message: "alpha 1.0.0 несовместим с gamma",
+		},
+		{
+			name: "unsatisfiable constraints",
+			portal: map[string][]fakeRelease{
+				"alpha": {{version: "1.0.0", deps: []string{"beta >= 2.0.0"}}},
+				"gamma": {{version: "1.0.0", deps: []string{"beta < 1.5.0"}}},
+				"beta":  {{version: "1.0.0"}, {version: "2.1.0"}},
+			},
+			list:    []modListEntry{{Name: "alpha", Enabled: true}, {Name: "gamma", Enabled: true}},
+			message: "нет версии beta для Factorio 2.0, подходящей всем",
+		},
+		{
+			name: "pin against a constraint",
+			portal: map[string][]fakeRelease{
+				"alpha": {{version: "1.0.0", deps: []string{"beta >= 1.2.0"}}},
+				"beta":  {{version: "1.0.0"}, {version: "1.2.0"}},
+			},
+			list:    []modListEntry{{Name: "alpha", Enabled: true}, {Name: "beta", Enabled: true, Version: "1.0.0"}},
+			message: "beta закреплён на 1.0.0 в mod-list.json, а alpha 1.0.0 требует beta >= 1.2.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, newFakePortal(tt.portal), tt.list...)
+
+			_, _, err := m.SyncMods(context.Background(), nil)
+			var conflict *ConflictError
+			if !errors.As(err, &conflict) {
+				t.Fatalf("SyncMods: %v, want *ConflictError", err)
+			}
+			if !strings.Contains(strings.Join(conflict.Conflicts, "; "), tt.message) {
+				t.Errorf("conflicts %q, want one containing %q", conflict.Conflicts, tt.message)
+			}
+
+			zips, _ := filepath.Glob(filepath.Join(m.modsDir, "*.zip"))
+			if len(zips) > 0 {
+				t.Errorf("downloaded %v despite the conflict", zips)
+			}
+			if _, err := os.Stat(m.lockFile); !os.IsNotExist(err) {
+				t.Error("lock file written despite the conflict")
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package mods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Portal requests are retried on errors that are likely to pass by themselves:
+// network failures, 5xx and 429 responses, a download that does not match its
+// checksum (usually a truncated transfer). Delays double: 2s, 4s, 8s.
+const (
+	retryAttempts  = 4
+	retryBaseDelay = 2 * time.Second
+)
+
+// transientError marks an error worth retrying.
+type transientError struct{ err error }
+
+func (e transientError) Error() string { return e.err.Error() }
+func (e transientError) Unwrap() error { return e.err }
+
+func transient(err error) error { return transientError{err} }
+
+// requestError classifies a failed HTTP request: transient unless the context ended.
+func requestError(ctx context.Context, format string, err error) error {
+	err = fmt.Errorf(format, err)
+	if ctx.Err() != nil {
+		return err
+	}
+	return transient(err)
+}
+
+// statusError reports an unexpected HTTP status; 5xx and 429 are transient.
+func statusError(code int, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	if code >= 500 || code == http.StatusTooManyRequests {
+		return transient(err)
+	}
+	return err
+}
+
+// retry calls fn until it succeeds, fails with a non-transient error or runs out
+// of attempts.
+func retry(ctx context.Context, what string, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var t transientError
+		if err == nil || !errors.As(err, &t) || attempt == retryAttempts || ctx.Err() != nil {
+			return err
+		}
+		log.Printf("mods: %s: %v — попытка %d из %d через %s", what, err, attempt+1, retryAttempts, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,147 @@
+package mods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ModVersion compares the installed version of a mod with the latest release for
+// factorioVersion on the portal.
+type ModVersion struct {
+	Name      string
+	Installed string // из lock-файла; пусто, если мод ещё не установлен
+	Latest    string
+	Pinned    bool // версия закреплена в mod-list.json, /mods update её не трогает
+}
+
+// Outdated reports whether a newer release is available.
+func (v ModVersion) Outdated() bool {
+	return v.Latest != "" && (v.Installed == "" || compareVersions(v.Latest, v.Installed) > 0)
+}
+
+// UpdateResult is the outcome of updating one mod.
+type UpdateResult struct {
+	Name     string
+	From, To string
+	Skipped  string // причина, если мод не обновлялся
+	Err      error
+}
+
+// Versions checks every enabled mod against the portal. Mods the portal does not
+// know or has no release for factorioVersion are reported with an empty Latest.
+func (m *Manager) Versions(ctx context.Context) ([]ModVersion, error) {
+	list, err := m.readModList()
+	if err != nil {
+		return nil, fmt.Errorf("чтение mod-list.json: %w", err)
+	}
+	lock, err := m.Lock()
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []ModVersion
+	for _, entry := range list.Mods {
+		if !entry.Enabled || builtinMods[entry.Name] {
+			continue
+		}
+		v := ModVersion{Name: entry.Name, Installed: lock.Mods[entry.Name].Version, Pinned: entry.Version != ""}
+		info, err := m.fetchModInfo(ctx, entry.Name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			log.Printf("mods: не удалось проверить %s: %v", entry.Name, err)
+		} else if r := latestRelease(info.Releases, m.factorioVersion); r != nil {
+			v.Latest = r.Version
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// Update installs the latest release of the named mods, or of every outdated mod
+// when names is empty, as far as the dependency constraints of the other mods allow.
+// The rest of the mod set is converged as in SyncMods; on a *ConflictError nothing
+// is changed. The server picks the new versions up on its next start. Pinned mods
+// are skipped. Download progress goes to onProgress, which may be nil.
+func (m *Manager) Update(ctx context.Context, names []string, onProgress ProgressFunc) ([]UpdateResult, error) {
+	if m.username == "" || m.token == "" {
+		return nil, fmt.Errorf("FACTORIO_MOD_PORTAL_USER / FACTORIO_MOD_PORTAL_TOKEN не заданы")
+	}
+	versions, err := m.Versions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]ModVersion)
+	for _, v := range versions {
+		byName[strings.ToLower(v.Name)] = v
+	}
+	var targets []ModVersion
+	if len(names) == 0 {
+		for _, v := range versions {
+			if v.Outdated() && !v.Pinned {
+				targets = append(targets, v)
+			}
+		}
+	} else {
+		for _, name := range names {
+			v, ok := byName[strings.ToLower(name)]
+			if !ok {
+				return nil, fmt.Errorf("мод %q не включён в mod-list.json", name)
+			}
+			targets = append(targets, v)
+		}
+	}
+
+	var results []UpdateResult
+	upgrade := make(map[string]bool)
+	for _, v := range targets {
+		res := UpdateResult{Name: v.Name, From: v.Installed, To: v.Latest}
+		switch {
+		case v.Pinned:
+			res.Skipped = "версия закреплена в mod-list.json"
+		case v.Latest == "":
+			res.Skipped = "нет релиза для Factorio " + m.factorioVersion + " или портал недоступен"
+		case !v.Outdated():
+			res.Skipped = "уже последняя версия"
+		default:
+			upgrade[strings.ToLower(v.Name)] = true
+		}
+		results = append(results, res)
+	}
+	if len(upgrade) == 0 {
+		return results, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, failures, err := m.sync(ctx, upgrade, onProgress)
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return nil, err
+	}
+	lock, lockErr := m.Lock()
+	if lockErr != nil {
+		return results, lockErr
+	}
+	for i := range results {
+		res := &results[i]
+		if res.Skipped != "" {
+			continue
+		}
+		if ferr, failed := failures[res.Name]; failed {
+			res.Err = ferr
+			continue
+		}
+		res.To = lock.Mods[res.Name].Version
+		if res.To == res.From {
+			res.Skipped = "новая версия не подходит под зависимости других модов"
+		}
+	}
+	return results, err
+}
@@ -0,0 +1,122 @@
+package mods
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// syncedManager returns a Manager whose mods are installed and locked.
+func syncedManager(t *testing.T, portal *fakePortal, list ...modListEntry) *Manager {
+	t.Helper()
+	m := newTestManager(t, portal, list...)
+	if _, failures, err := m.SyncMods(context.Background(), nil); err != nil || len(failures) > 0 {
+		t.Fatalf("SyncMods: %v, failures %v", err, failures)
+	}
+	return m
+}
+
+func TestVersionsReportsOutdatedAndPinned(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha": {{version: "1.0.0"}},
+		"beta":  {{version: "1.0.0"}},
+		"gamma": {{version: "1.0.0"}},
+	})
+	m := syncedManager(t, portal,
+		modListEntry{Name: "alpha", Enabled: true},
+		modListEntry{Name: "beta", Enabled: true, Version: "1.0.0"},
+		modListEntry{Name: "gamma", Enabled: true},
+	)
+	portal.publish("alpha", fakeRelease{version: "1.1.0"})
+	portal.publish("beta", fakeRelease{version: "1.1.0"})
+
+	versions, err := m.Versions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]ModVersion{
+		"alpha": {Name: "alpha", Installed: "1.0.0", Latest: "1.1.0"},
+		"beta":  {Name: "beta", Installed: "1.0.0", Latest: "1.1.0", Pinned: true},
+		"gamma": {Name: "gamma", Installed: "1.0.0", Latest: "1.0.0"},
+	}
+	if len(versions) != len(want) {
+		t.Fatalf("versions = %+v", versions)
+	}
+	for _, v := range versions {
+		if v != want[v.Name] {
+			t.Errorf("%s = %+v, want %+v", v.Name, v, want[v.Name])
+		}
+	}
+	if !want["alpha"].Outdated() || want["gamma"].Outdated() {
+		t.Error("Outdated disagrees with Installed/Latest")
+	}
+}
+
+func TestUpdateAllSkipsPinned(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha": {{version: "1.0.0"}},
+		"beta":  {{version: "1.0.0"}},
+	})
+	m := syncedManager(t, portal,
+		modListEntry{Name: "alpha", Enabled: true},
+		modListEntry{Name: "beta", Enabled: true, Version: "1.0.0"},
+	)
+	portal.publish("alpha", fakeRelease{version: "1.1.0"})
+	portal.publish("beta", fakeRelease{version: "1.1.0"})
+
+	results, err := m.Update(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0] != (UpdateResult{Name: "alpha", From: "1.0.0", To: "1.1.0"}) {
+		t.Errorf("results = %+v, want only alpha updated", results)
+	}
+
+	// Явно названный закреплённый мод не обновляется, но попадает в отчёт.
+	results, err = m.Update(context.Background(), []string{"beta"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Skipped != "версия закреплена в mod-list.json" {
+		t.Errorf("results = %+v, want beta skipped as pinned", results)
+	}
+
+	lock, err := m.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, b := lock.Mods["alpha"].Version, lock.Mods["beta"].Version; a != "1.1.0" || b != "1.0.0" {
+		t.Errorf("locked alpha %s, beta %s; want 1.1.0 and the pinned 1.0.0", a, b)
+	}
+}
+
+func TestUpdateRejectedByDependencies(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha": {{version: "1.0.0", deps: []string{"base", "beta < 2.0.0"}}},
+		"beta":  {{version: "1.0.0"}},
+	})
+	m := syncedManager(t, portal, modListEntry{Name: "alpha", Enabled: true}, modListEntry{Name: "beta", Enabled: true})
+	portal.publish("beta", fakeRelease{version: "2.0.0"})
+
+	results, err := m.Update(context.Background(), []string{"beta"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := UpdateResult{Name: "beta", From: "1.0.0", To: "1.0.0", Skipped: "новая версия не подходит под зависимости других модов"}
+	if len(results) != 1 || results[0] != want {
+		t.Errorf("results = %+v, want %+v", results, want)
+	}
+	if portal.count("/download/beta/2.0.0") != 0 {
+		t.Error("beta 2.0.0 was downloaded")
+	}
+}
+
+func TestUpdateUnknownMod(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{"alpha": {{version: "1.0.0"}}})
+	m := syncedManager(t, portal, modListEntry{Name: "alpha", Enabled: true})
+
+	_, err := m.Update(context.Background(), []string{"omega"}, nil)
+	if err == nil || !strings.Contains(err.Error(), `"omega" не включён`) {
+		t.Errorf("err = %v, want omega reported as not enabled", err)
+	}
+}
@@ -0,0 +1,126 @@
+package mods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// BrokenSuffix is appended to a mod zip that failed verification. The file is kept
+// for inspection; the next SyncMods downloads the mod again.
+const BrokenSuffix = ".broken"
+
+// VerifyResult is the outcome of checking one installed mod.
+type VerifyResult struct {
+	Name     string
+	Version  string
+	FileName string
+	Problem  string // пусто, если zip цел
+	// PortalChecked is false when the portal could not be asked for the release
+	// checksum, so the file was only checked against mod-lock.json.
+	PortalChecked bool
+}
+
+// OK reports whether the zip passed verification.
+func (r VerifyResult) OK() bool { return r.Problem == "" }
+
+// Verify rechecks every locked mod: the zip must exist and match the SHA-1 recorded
+// in mod-lock.json and the one the portal publishes for that release. Zips that
+// fail are renamed to *.broken, so the next SyncMods downloads them again; zips in
+// the mods dir that are not in the lock are reported too.
+func (m *Manager) Verify(ctx context.Context) ([]VerifyResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, err := m.Lock()
+	if err != nil {
+		return nil, err
+	}
+	zips, err := m.installedZips()
+	if err != nil {
+		return nil, fmt.Errorf("сканирование папки модов: %w", err)
+	}
+
+	var results []VerifyResult
+	locked := make(map[string]bool) // имена файлов
+	for _, name := range lock.Names() {
+		entry := lock.Mods[name]
+		locked[entry.FileName] = true
+		res := VerifyResult{Name: name, Version: entry.Version, FileName: entry.FileName}
+		res.Problem, res.PortalChecked = m.verifyZip(ctx, name, entry)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if res.Problem != "" && res.Problem != problemMissing {
+			m.markBroken(entry.FileName, res.Problem)
+		}
+		results = append(results, res)
+	}
+
+	for _, files := range zips {
+		for _, z := range files {
+			if !locked[z.FileName] {
+				results = append(results, VerifyResult{Name: z.Name, Version: z.Version, FileName: z.FileName, Problem: "нет в mod-lock.json"})
+			}
+		}
+	}
+	return results, nil
+}
+
+const problemMissing = "файл отсутствует"
+
+// verifyZip checks one zip against the lock and the portal.
+func (m *Manager) verifyZip(ctx context.Context, name string, entry LockEntry) (problem string, portalChecked bool) {
+	sum, err := m.fileSHA1(entry.FileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return problemMissing, false
+	}
+	if err != nil {
+		return "не читается: " + err.Error(), false
+	}
+	if entry.SHA1 != "" && !strings.EqualFold(sum, entry.SHA1) {
+		return "SHA-1 не совпадает с mod-lock.json", false
+	}
+
+	info, err := m.fetchModInfo(ctx, name)
+	if err != nil {
+		log.Printf("mods: не удалось проверить %s по порталу: %v", name, err)
+		return "", false
+	}
+	release := findRelease(info.Releases, entry.Version)
+	if release == nil || release.SHA1 == "" {
+		return "", false
+	}
+	if !strings.EqualFold(sum, release.SHA1) {
+		return "SHA-1 не совпадает с mod portal", true
+	}
+	return "", true
+}
+
+// markBroken moves a zip that failed verification out of the way.
+func (m *Manager) markBroken(fileName, reason string) {
+	path := filepath.Join(m.modsDir, fileName)
+	if err := os.Rename(path, path+BrokenSuffix); err != nil {
+		log.Printf("mods: WARN не удалось переименовать %s: %v", fileName, err)
+		return
+	}
+	log.Printf("mods: %s → %s%s (%s)", fileName, fileName, BrokenSuffix, reason)
+}
+
+// removeStaleTemps deletes unfinished downloads left by a crash or restart. Only
+// called under m.mu, so no download is in progress.
+func (m *Manager) removeStaleTemps() {
+	temps, err := filepath.Glob(filepath.Join(m.modsDir, ".*.zip.tmp-*"))
+	if err != nil {
+		return
+	}
+	for _, t := range temps {
+		if err := os.Remove(t); err == nil {
+			log.Printf("mods: удалён недокачанный %s", filepath.Base(t))
+		}
+	}
+}
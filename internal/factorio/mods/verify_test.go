@@ -0,0 +1,142 @@
+package mods
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDownloadRejectsBadChecksum(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{"alpha": {{version: "1.0.0", corrupt: true}}})
+	m := newTestManager(t, portal)
+	info, err := m.fetchModInfo(context.Background(), "alpha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Старая копия под тем же именем не должна пострадать от битой загрузки.
+	final := filepath.Join(m.modsDir, "alpha_1.0.0.zip")
+	if err := os.WriteFile(final, []byte("previous"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := newProgressReporter(nil, 1)
+	defer progress.close()
+
+	_, err = m.downloadRelease(context.Background(), "alpha", &info.Releases[0], progress)
+	var tr transientError
+	if err == nil || !strings.Contains(err.Error(), "SHA-1 не совпадает") || !errors.As(err, &tr) {
+		t.Fatalf("err = %v, want a transient checksum mismatch", err)
+	}
+	if got, err := os.ReadFile(final); err != nil || string(got) != "previous" {
+		t.Errorf("final zip = %q, %v; want it untouched", got, err)
+	}
+	if temps, _ := filepath.Glob(filepath.Join(m.modsDir, ".*tmp*")); len(temps) > 0 {
+		t.Errorf("temp files left: %q", temps)
+	}
+
+	os.Remove(final)
+	if _, err := m.downloadRelease(context.Background(), "alpha", &info.Releases[0], progress); err == nil {
+		t.Fatal("corrupt download accepted")
+	}
+	if got := modFiles(t, m); len(got) != 0 {
+		t.Errorf("mods dir = %q, want no zip from a corrupt download", got)
+	}
+}
+
+func TestRemoveStaleTemps(t *testing.T) {
+	m := newTestManager(t, newFakePortal(nil))
+	for _, name := range []string{".alpha_1.0.0.zip.tmp-123", "alpha_1.0.0.zip"} {
+		if err := os.WriteFile(filepath.Join(m.modsDir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.removeStaleTemps()
+	if _, err := os.Stat(filepath.Join(m.modsDir, ".alpha_1.0.0.zip.tmp-123")); !os.IsNotExist(err) {
+		t.Errorf("unfinished download kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.modsDir, "alpha_1.0.0.zip")); err != nil {
+		t.Errorf("installed zip removed: %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	portal := newFakePortal(map[string][]fakeRelease{
+		"alpha": {{version: "1.0.0"}},
+		"beta":  {{version: "1.0.0"}},
+		"gamma": {{version: "1.0.0"}},
+		"delta": {{version: "1.0.0"}},
+	})
+	m := syncedManager(t, portal,
+		modListEntry{Name: "alpha", Enabled: true},
+		modListEntry{Name: "beta", Enabled: true},
+		modListEntry{Name: "gamma", Enabled: true},
+		modListEntry{Name: "delta", Enabled: true},
+	)
+
+	// beta испорчен на диске; gamma подменён вместе с lock-файлом, его выдаст только
+	// портал; delta пропал; omega лежит в папке без записи в lock-файле.
+	if err := os.WriteFile(filepath.Join(m.modsDir, "beta_1.0.0.zip"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(m.modsDir, "gamma_1.0.0.zip"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := m.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gamma := lock.Mods["gamma"]
+	gamma.SHA1, _ = m.fileSHA1("gamma_1.0.0.zip")
+	lock.Mods["gamma"] = gamma
+	if err := m.writeLock(lock); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(m.modsDir, "delta_1.0.0.zip"))
+	if err := os.WriteFile(filepath.Join(m.modsDir, "omega_2.0.0.zip"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := m.Verify(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]VerifyResult{
+		"alpha": {Name: "alpha", Version: "1.0.0", FileName: "alpha_1.0.0.zip", PortalChecked: true},
+		"beta":  {Name: "beta", Version: "1.0.0", FileName: "beta_1.0.0.zip", Problem: "SHA-1 не совпадает с mod-lock.json"},
+		"gamma": {Name: "gamma", Version: "1.0.0", FileName: "gamma_1.0.0.zip", Problem: "SHA-1 не совпадает с mod portal", PortalChecked: true},
+		"delta": {Name: "delta", Version: "1.0.0", FileName: "delta_1.0.0.zip", Problem: problemMissing},
+		"omega": {Name: "omega", Version: "2.0.0", FileName: "omega_2.0.0.zip", Problem: "нет в mod-lock.json"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v", results)
+	}
+	for _, r := range results {
+		if r != want[r.Name] {
+			t.Errorf("%s = %+v, want %+v", r.Name, r, want[r.Name])
+		}
+	}
+
+	if got := strings.Join(modFiles(t, m), ","); got != "alpha_1.0.0.zip,omega_2.0.0.zip" {
+		t.Errorf("mods dir = %q, want the broken zips moved away", got)
+	}
+	for _, name := range []string{"beta_1.0.0.zip", "gamma_1.0.0.zip"} {
+		got, err := os.ReadFile(filepath.Join(m.modsDir, name+BrokenSuffix))
+		if err != nil || string(got) != "tampered" {
+			t.Errorf("%s%s = %q, %v; want the corrupt file kept for inspection", name, BrokenSuffix, got, err)
+		}
+	}
+
+	// Следующая синхронизация скачивает переименованные и пропавшие моды заново.
+	n, failures, err := m.SyncMods(context.Background(), nil)
+	if err != nil || len(failures) > 0 || n != 3 {
+		t.Fatalf("SyncMods: %d downloads, %v, failures %v; want beta, gamma and delta again", n, err, failures)
+	}
+	got, err := os.ReadFile(filepath.Join(m.modsDir, "gamma_1.0.0.zip"))
+	if err != nil || !bytes.Equal(got, modZip("gamma", fakeRelease{version: "1.0.0"})) {
+		t.Errorf("gamma not restored from the portal (%v)", err)
+	}
+}
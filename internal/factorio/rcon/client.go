@@ -1,9 +1,29 @@
 package rcon
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"time"
 
 	gorcon "github.com/gorcon/rcon"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+const (
+	defaultDialTimeout = 5 * time.Second
+	defaultExecTimeout = 10 * time.Second
+
+	// healthCheckAfter — если соединение простаивало дольше, перед использованием
+	// проверяем его лёгкой командой: Factorio молча рвёт RCON при рестарте.
+	healthCheckAfter = 30 * time.Second
+	healthCommand    = "/version"
+
+	reconnectAttempts = 4
+	reconnectBaseWait = 250 * time.Millisecond
+	reconnectMaxWait  = 5 * time.Second
 )
 
 // PasswordProvider returns the current RCON password
@@ -11,35 +31,230 @@ type PasswordProvider interface {
 	Get() string
 }
 
-// Client implements domain.RconExecutor using the gorcon library
+// Timeouts configures the Client. Zero values fall back to the package defaults.
+type Timeouts struct {
+	// Dial bounds establishing the TCP connection and authenticating.
+	Dial time.Duration
+	// Exec is applied to calls whose context carries no deadline of its own.
+	Exec time.Duration
+}
+
+// Client implements domain.RconExecutor on top of a single long-lived gorcon connection.
+// The connection is opened lazily, health-checked after idle periods, re-established
+// with exponential backoff when it breaks, and re-authenticated when the password rotates.
 type Client struct {
-	host      string
-	port      string
-	passwords PasswordProvider
+	host        string
+	port        string
+	passwords   PasswordProvider
+	dialTimeout time.Duration
+	execTimeout time.Duration
+
+	// sem — мьютекс на канале, чтобы ожидание очереди тоже уважало ctx.
+	sem      chan struct{}
+	conn     *gorcon.Conn
+	netConn  *countingConn
+	authPw   string // пароль, с которым авторизовано текущее соединение
+	lastUsed time.Time
 }
 
-func NewClient(host, port string, passwords PasswordProvider) *Client {
+func NewClient(host, port string, passwords PasswordProvider, timeouts Timeouts) *Client {
+	if timeouts.Dial <= 0 {
+		timeouts.Dial = defaultDialTimeout
+	}
+	if timeouts.Exec <= 0 {
+		timeouts.Exec = defaultExecTimeout
+	}
 	return &Client{
-		host:      host,
-		port:      port,
-		passwords: passwords,
+		host:        host,
+		port:        port,
+		passwords:   passwords,
+		dialTimeout: timeouts.Dial,
+		execTimeout: timeouts.Exec,
+		sem:         make(chan struct{}, 1),
 	}
 }
 
-// Execute dials the Factorio RCON server, runs the command, and returns the response
+// Execute is ExecuteContext with a background context (and therefore the default timeout).
 func (c *Client) Execute(command string) (string, error) {
-	addr := fmt.Sprintf("%s:%s", c.host, c.port)
+	return c.ExecuteContext(context.Background(), command)
+}
+
+// ExecuteContext runs the command over the shared RCON connection and returns the response.
+// Connection failures are retried with exponential backoff. A command that fails on a
+// reused connection is retried once on a fresh one only if none of it was sent, or if
+// ctx is marked with domain.Idempotent: otherwise the server may have run it already.
+// Errors are *ConnectError, *AuthError or *ExecError.
+func (c *Client) ExecuteContext(ctx context.Context, command string) (string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.execTimeout)
+		defer cancel()
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		return "", &ExecError{Command: command, Err: ctx.Err()}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < reconnectAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff(attempt)):
+			case <-ctx.Done():
+				return "", lastErr
+			}
+		}
+
+		reused, err := c.ensureConnLocked(ctx)
+		if err != nil {
+			lastErr = err
+			var authErr *AuthError
+			if errors.As(err, &authErr) || ctx.Err() != nil {
+				// Неверный пароль повтором не исправить, а истёкший ctx — тем более.
+				break
+			}
+			continue
+		}
+
+		resp, sent, err := c.execLocked(ctx, command)
+		if err == nil {
+			return resp, nil
+		}
+
+		c.closeLocked()
+		lastErr = &ExecError{Command: command, Err: err}
+		if !reused || ctx.Err() != nil {
+			// Свежее соединение тоже не справилось — дальше повторять бессмысленно.
+			break
+		}
+		if sent && !domain.IsIdempotent(ctx) {
+			// Команда ушла на сервер и могла выполниться — повтор выполнил бы её дважды.
+			break
+		}
+	}
+
+	return "", lastErr
+}
+
+// Close drops the underlying connection. The next call reconnects.
+func (c *Client) Close() error {
+	c.sem <- struct{}{}
+	defer func() { <-c.sem }()
+	c.closeLocked()
+	return nil
+}
+
+// ensureConnLocked makes sure c.conn is open, authenticated with the current password
+// and alive. Reports whether an existing connection was reused.
+func (c *Client) ensureConnLocked(ctx context.Context) (bool, error) {
+	pw := c.passwords.Get()
 
-	conn, err := gorcon.Dial(addr, c.passwords.Get())
+	if c.conn != nil && c.authPw != pw {
+		log.Println("rcon: пароль изменился, переподключаюсь")
+		c.closeLocked()
+	}
+
+	if c.conn != nil && time.Since(c.lastUsed) > healthCheckAfter {
+		if _, _, err := c.execLocked(ctx, healthCommand); err != nil {
+			log.Printf("rcon: соединение неактивно (%v), переподключаюсь", err)
+			c.closeLocked()
+		}
+	}
+
+	if c.conn != nil {
+		return true, nil
+	}
+
+	addr := net.JoinHostPort(c.host, c.port)
+	dialCtx, cancel := context.WithTimeout(ctx, c.dialTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	raw, err := d.DialContext(dialCtx, "tcp", addr)
 	if err != nil {
-		return "", fmt.Errorf("RCON connect: %w", err)
+		return false, &ConnectError{Addr: addr, Err: err}
 	}
-	defer conn.Close()
+	netConn := &countingConn{Conn: raw}
 
-	resp, err := conn.Execute(command)
+	// Дедлайны выставляем сами на каждый вызов, поэтому встроенный в gorcon отключаем.
+	deadline, _ := dialCtx.Deadline()
+	if err := netConn.SetDeadline(deadline); err != nil {
+		netConn.Close()
+		return false, &ConnectError{Addr: addr, Err: err}
+	}
+	conn, err := gorcon.Open(netConn, pw, gorcon.SetDeadline(0))
 	if err != nil {
-		return "", fmt.Errorf("RCON exec: %w", err)
+		netConn.Close()
+		if errors.Is(err, gorcon.ErrAuthFailed) || errors.Is(err, gorcon.ErrInvalidAuthResponse) {
+			return false, &AuthError{Err: err}
+		}
+		return false, &ConnectError{Addr: addr, Err: err}
+	}
+
+	c.conn = conn
+	c.netConn = netConn
+	c.authPw = pw
+	c.lastUsed = time.Now()
+	return false, nil
+}
+
+// execLocked runs a single command bounded by ctx: its deadline becomes the socket
+// deadline, and cancellation interrupts blocked reads/writes. sent reports whether
+// any byte of the command was written, i.e. whether the server may have run it.
+func (c *Client) execLocked(ctx context.Context, command string) (resp string, sent bool, err error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.netConn.SetDeadline(deadline); err != nil {
+		return "", false, err
 	}
 
-	return resp, nil
+	netConn := c.netConn
+	stop := context.AfterFunc(ctx, func() {
+		_ = netConn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	netConn.written = 0
+	resp, err = c.conn.Execute(command)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", netConn.written > 0, ctxErr
+		}
+		return "", netConn.written > 0, err
+	}
+	c.lastUsed = time.Now()
+	return resp, true, nil
+}
+
+func (c *Client) closeLocked() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+	c.conn = nil
+	c.netConn = nil
+	c.authPw = ""
+}
+
+// backoff returns the wait before the given reconnect attempt (1-based): 250ms, 500ms, 1s… capped at 5s.
+func backoff(attempt int) time.Duration {
+	d := reconnectBaseWait << (attempt - 1)
+	if d > reconnectMaxWait {
+		d = reconnectMaxWait
+	}
+	return d
+}
+
+// countingConn counts the bytes written since the last reset, so a failed command
+// can tell whether it reached the wire.
+type countingConn struct {
+	net.Conn
+	written int
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.written += n
+	return n, err
 }
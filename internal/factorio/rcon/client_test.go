@@ -0,0 +1,314 @@
+package rcon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	gorcon "github.com/gorcon/rcon"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+// staticPassword is a PasswordProvider with a fixed password.
+type staticPassword string
+
+func (p staticPassword) Get() string { return string(p) }
+
+// fakeServer is a minimal RCON server: it authenticates against password and answers
+// every command through exec. If exec reports false, the command is left unanswered.
+type fakeServer struct {
+	l        net.Listener
+	password string
+	exec     func(command string) (string, bool)
+
+	mu       sync.Mutex
+	dials    int
+	commands []string
+	drops    int // столько следующих команд сервер выполнит и оборвёт соединение, не ответив
+}
+
+func newFakeServer(t *testing.T, password string, exec func(string) (string, bool)) *fakeServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeServer{l: l, password: password, exec: exec}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.dials++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var req gorcon.Packet
+		if _, err := req.ReadFrom(conn); err != nil {
+			return
+		}
+		switch req.Type {
+		case gorcon.SERVERDATA_AUTH:
+			s.mu.Lock()
+			password := s.password
+			s.mu.Unlock()
+			if req.Body() != password {
+				gorcon.NewPacket(gorcon.SERVERDATA_AUTH_RESPONSE, -1, "").WriteTo(conn)
+				return
+			}
+			gorcon.NewPacket(gorcon.SERVERDATA_RESPONSE_VALUE, req.ID, "").WriteTo(conn)
+			gorcon.NewPacket(gorcon.SERVERDATA_AUTH_RESPONSE, req.ID, "").WriteTo(conn)
+		case gorcon.SERVERDATA_EXECCOMMAND:
+			s.mu.Lock()
+			s.commands = append(s.commands, req.Body())
+			drop := s.drops > 0
+			if drop {
+				s.drops--
+			}
+			s.mu.Unlock()
+			if drop {
+				return
+			}
+			if resp, ok := s.exec(req.Body()); ok {
+				gorcon.NewPacket(gorcon.SERVERDATA_RESPONSE_VALUE, req.ID, resp).WriteTo(conn)
+			}
+		}
+	}
+}
+
+func (s *fakeServer) addr() (host, port string) {
+	host, port, _ = net.SplitHostPort(s.l.Addr().String())
+	return host, port
+}
+
+func (s *fakeServer) stats() (dials int, commands []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials, append([]string(nil), s.commands...)
+}
+
+// dropNext makes the server run the next n commands and hang up without answering,
+// as Factorio does when it crashes or restarts.
+func (s *fakeServer) dropNext(n int) {
+	s.mu.Lock()
+	s.drops = n
+	s.mu.Unlock()
+}
+
+func echo(command string) (string, bool) { return "ok: " + command, true }
+
+func TestClientReusesConnection(t *testing.T) {
+	s := newFakeServer(t, "secret", echo)
+	host, port := s.addr()
+	c := NewClient(host, port, staticPassword("secret"), Timeouts{})
+	defer c.Close()
+
+	for _, cmd := range []string{"/time", "/players online"} {
+		resp, err := c.ExecuteContext(context.Background(), cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != "ok: "+cmd {
+			t.Errorf("%s: response %q", cmd, resp)
+		}
+	}
+	if dials, _ := s.stats(); dials != 1 {
+		t.Errorf("%d connections, want 1", dials)
+	}
+}
+
+func TestClientConnectError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	l.Close() // порт свободен — соединение будет отклонено
+
+	c := NewClient(host, port, staticPassword("secret"), Timeouts{})
+	ctx, cancel := context.WithTimeout(context.Background(), 400*time.Millisecond)
+	defer cancel()
+
+	_, err = c.ExecuteContext(ctx, "/time")
+	var connErr *ConnectError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("err = %v, want *ConnectError", err)
+	}
+	if connErr.Addr != net.JoinHostPort(host, port) {
+		t.Errorf("addr = %s", connErr.Addr)
+	}
+}
+
+func TestClientAuthErrorIsNotRetried(t *testing.T) {
+	s := newFakeServer(t, "secret", echo)
+	host, port := s.addr()
+	c := NewClient(host, port, staticPassword("wrong"), Timeouts{})
+
+	_, err := c.ExecuteContext(context.Background(), "/time")
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || !errors.Is(err, gorcon.ErrAuthFailed) {
+		t.Fatalf("err = %v, want *AuthError", err)
+	}
+	if dials, _ := s.stats(); dials != 1 {
+		t.Errorf("%d connections, want a single attempt", dials)
+	}
+}
+
+func TestClientExecTimeout(t *testing.T) {
+	s := newFakeServer(t, "secret", func(string) (string, bool) { return "", false })
+	host, port := s.addr()
+	c := NewClient(host, port, staticPassword("secret"), Timeouts{Exec: 100 * time.Millisecond})
+	defer c.Close()
+
+	started := time.Now()
+	_, err := c.ExecuteContext(context.Background(), "/server-save")
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("returned after %s, want the 100ms exec timeout", elapsed)
+	}
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want *ExecError wrapping DeadlineExceeded", err)
+	}
+	if execErr.Command != "/server-save" {
+		t.Errorf("command = %q", execErr.Command)
+	}
+	// На свежем соединении команду не повторяем: сервер мог её уже выполнить.
+	if _, commands := s.stats(); len(commands) != 1 {
+		t.Errorf("commands sent = %q, want one", commands)
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	s := newFakeServer(t, "secret", func(string) (string, bool) { return "", false })
+	host, port := s.addr()
+	c := NewClient(host, port, staticPassword("secret"), Timeouts{Exec: time.Minute})
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := c.ExecuteContext(ctx, "/time")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestClientReconnectsOnPasswordChange(t *testing.T) {
+	s := newFakeServer(t, "old", echo)
+	host, port := s.addr()
+	pw := &rotatingPassword{pw: "old"}
+	c := NewClient(host, port, pw, Timeouts{})
+	defer c.Close()
+
+	if _, err := c.ExecuteContext(context.Background(), "/time"); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.password = "new"
+	s.mu.Unlock()
+	pw.set("new")
+
+	if _, err := c.ExecuteContext(context.Background(), "/time"); err != nil {
+		t.Fatal(err)
+	}
+	if dials, _ := s.stats(); dials != 2 {
+		t.Errorf("%d connections, want a reconnect after the rotation", dials)
+	}
+}
+
+type rotatingPassword struct {
+	mu sync.Mutex
+	pw string
+}
+
+func (p *rotatingPassword) Get() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.pw
+}
+
+func (p *rotatingPassword) set(pw string) {
+	p.mu.Lock()
+	p.pw = pw
+	p.mu.Unlock()
+}
+
+func TestClientDoesNotResendSentCommand(t *testing.T) {
+	s := newFakeServer(t, "secret", echo)
+	host, port := s.addr()
+	c := NewClient(host, port, staticPassword("secret"), Timeouts{})
+	defer c.Close()
+
+	if _, err := c.ExecuteContext(context.Background(), "/time"); err != nil {
+		t.Fatal(err)
+	}
+	s.dropNext(1)
+	_, err := c.ExecuteContext(context.Background(), "/c game.print(1)")
+	var execErr *ExecError
+	if !errors.As(err, &execErr) {
+		t.Fatalf("err = %v, want *ExecError", err)
+	}
+	if _, commands := s.stats(); len(commands) != 2 || commands[1] != "/c game.print(1)" {
+		t.Errorf("server ran %q, want the command once", commands)
+	}
+
+	// Следующий вызов поднимает новое соединение.
+	if resp, err := c.ExecuteContext(context.Background(), "/time"); err != nil || resp != "ok: /time" {
+		t.Errorf("after the failure: %q, %v", resp, err)
+	}
+}
+
+func TestClientResendsIdempotentCommand(t *testing.T) {
+	s := newFakeServer(t, "secret", echo)
+	host, port := s.addr()
+	c := NewClient(host, port, staticPassword("secret"), Timeouts{})
+	defer c.Close()
+
+	if _, err := c.ExecuteContext(context.Background(), "/time"); err != nil {
+		t.Fatal(err)
+	}
+	s.dropNext(1)
+	resp, err := c.ExecuteContext(domain.Idempotent(context.Background()), "/players online")
+	if err != nil || resp != "ok: /players online" {
+		t.Fatalf("idempotent command: %q, %v; want it resent on a fresh connection", resp, err)
+	}
+	if dials, commands := s.stats(); dials != 2 || len(commands) != 3 {
+		t.Errorf("%d connections, commands %q", dials, commands)
+	}
+}
+
+func TestClientRetriesUnsentCommand(t *testing.T) {
+	s := newFakeServer(t, "secret", echo)
+	host, port := s.addr()
+	c := NewClient(host, port, staticPassword("secret"), Timeouts{})
+	defer c.Close()
+
+	if _, err := c.ExecuteContext(context.Background(), "/time"); err != nil {
+		t.Fatal(err)
+	}
+	// Сокет закрыт до записи: ни байта команды не ушло, повтор безопасен.
+	c.netConn.Conn.Close()
+
+	if resp, err := c.ExecuteContext(context.Background(), "/server-save"); err != nil || resp != "ok: /server-save" {
+		t.Fatalf("unsent command: %q, %v; want it retried", resp, err)
+	}
+	if _, commands := s.stats(); len(commands) != 2 || commands[1] != "/server-save" {
+		t.Errorf("server ran %q, want /server-save once", commands)
+	}
+}
@@ -0,0 +1,39 @@
+package rcon
+
+import "fmt"
+
+// ConnectError is returned when the TCP connection to the RCON port cannot be established.
+type ConnectError struct {
+	Addr string
+	Err  error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("RCON connect %s: %v", e.Addr, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error { return e.Err }
+
+// AuthError is returned when the server rejects the RCON password.
+type AuthError struct {
+	Err error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("RCON auth: %v", e.Err)
+}
+
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// ExecError is returned when a command fails on an established connection
+// (write/read failure, timeout or cancellation).
+type ExecError struct {
+	Command string
+	Err     error
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("RCON exec %q: %v", e.Command, e.Err)
+}
+
+func (e *ExecError) Unwrap() error { return e.Err }
@@ -0,0 +1,46 @@
+package rcon
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeExecutor is an in-memory domain.RconExecutor for tests.
+// Responses are looked up by exact command; Handler, if set, takes precedence.
+type FakeExecutor struct {
+	mu        sync.Mutex
+	Responses map[string]string
+	Err       error
+	Handler   func(ctx context.Context, command string) (string, error)
+	calls     []string
+}
+
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{Responses: make(map[string]string)}
+}
+
+// ExecuteContext records the command and returns the configured response.
+func (f *FakeExecutor) ExecuteContext(ctx context.Context, command string) (string, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, command)
+	handler, resp, err := f.Handler, f.Responses[command], f.Err
+	f.mu.Unlock()
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", &ExecError{Command: command, Err: ctxErr}
+	}
+	if handler != nil {
+		return handler(ctx, command)
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp, nil
+}
+
+// Calls returns the commands executed so far, in order.
+func (f *FakeExecutor) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
@@ -0,0 +1,166 @@
+package saves
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+// activeSave is the persisted choice of which save the server should load.
+type activeSave struct {
+	Name       string    `json:"name"`
+	SelectedAt time.Time `json:"selected_at"`
+	Origin     Origin    `json:"origin"`
+	// StartedAt — когда сервер последний раз успешно запустился (MarkStarted).
+	StartedAt time.Time `json:"started_at,omitzero"`
+}
+
+// LoadActive sets the file the active save selection is persisted in and reads it.
+// A missing file means nothing has been selected yet.
+func (m *Manager) LoadActive(file string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.activeFile = file
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading active save: %w", err)
+	}
+	var a activeSave
+	if err := json.Unmarshal(data, &a); err != nil {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+	m.active = a
+	return nil
+}
+
+// Active returns the selected save, or "" if none is selected or it is no longer
+// in the saves dir.
+func (m *Manager) Active() string {
+	m.mu.Lock()
+	name := m.active.Name
+	m.mu.Unlock()
+
+	if name == "" {
+		return ""
+	}
+	if _, err := os.Stat(filepath.Join(m.savesDir, name)); err != nil {
+		return ""
+	}
+	return name
+}
+
+// SetActive selects the save the server loads on the next start.
+func (m *Manager) SetActive(name string, origin Origin) error {
+	f, err := m.Open(name)
+	if err != nil {
+		return err
+	}
+	f.Close()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setActiveLocked(name, origin)
+}
+
+func (m *Manager) setActiveLocked(name string, origin Origin) error {
+	m.active = activeSave{Name: name, SelectedAt: time.Now(), Origin: origin, StartedAt: m.active.StartedAt}
+	if err := m.writeActiveLocked(); err != nil {
+		return err
+	}
+	log.Printf("saves: активный сейв — %s (%s via %s)", name, origin.Actor, origin.Source)
+	return nil
+}
+
+func (m *Manager) writeActiveLocked() error {
+	if m.activeFile == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m.active, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.activeFile), 0755); err != nil {
+		return fmt.Errorf("creating active save dir: %w", err)
+	}
+	if err := safefile.WriteFile(m.activeFile, data, 0644); err != nil {
+		return fmt.Errorf("writing active save: %w", err)
+	}
+	return nil
+}
+
+// StartPlan says which save the server is going to load.
+type StartPlan struct {
+	Name string
+	// Skipped is the active save when it was not forced: an autosave written after it
+	// holds newer progress, e.g. after the server was stopped without saving.
+	Skipped string
+}
+
+// PrepareStart makes the active save the one the server loads. The factorio image
+// starts with --start-server-load-latest, so the active save is touched to become the
+// newest file; autosaves stay where they are.
+//
+// The active save is forced only if it is newer than every autosave or was selected
+// after the previous successful start (see MarkStarted). Otherwise the newer autosave is loaded and the active save
+// is reported in StartPlan.Skipped. With nothing selected the newest save is loaded.
+func (m *Manager) PrepareStart() (StartPlan, error) {
+	files, err := m.List()
+	if err != nil {
+		return StartPlan{}, err
+	}
+	if len(files) == 0 {
+		return StartPlan{}, fmt.Errorf("no save files found in %s", m.savesDir)
+	}
+
+	name := m.Active()
+	if name == "" {
+		return StartPlan{Name: files[0].Name}, nil
+	}
+	var active, autosave SaveFile
+	for _, f := range files {
+		switch {
+		case f.Name == name:
+			active = f
+		case isAutosave(f.Name) && autosave.Name == "":
+			autosave = f // files отсортированы от новых к старым
+		}
+	}
+
+	m.mu.Lock()
+	selectedSinceStart := m.active.SelectedAt.After(m.active.StartedAt)
+	m.mu.Unlock()
+
+	if autosave.Name != "" && autosave.ModTime.After(active.ModTime) && !selectedSinceStart {
+		log.Printf("saves: автосейв %s новее активного %s — загружаю его", autosave.Name, name)
+		return StartPlan{Name: files[0].Name, Skipped: name}, nil
+	}
+	now := time.Now()
+	if err := os.Chtimes(filepath.Join(m.savesDir, name), now, now); err != nil {
+		return StartPlan{}, fmt.Errorf("touching %s: %w", name, err)
+	}
+	return StartPlan{Name: name}, nil
+}
+
+// MarkStarted records that the server has started after PrepareStart. It must be
+// called only once the container is up: if the start fails, a save selected before
+// it is still forced on the next attempt.
+func (m *Manager) MarkStarted() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active.Name == "" {
+		return nil
+	}
+	m.active.StartedAt = time.Now()
+	return m.writeActiveLocked()
+}
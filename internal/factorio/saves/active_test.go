@@ -0,0 +1,144 @@
+package saves
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSave creates name in dir with the given modification time.
+func writeSave(t *testing.T, dir, name string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newActiveTestManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	dir := t.TempDir()
+	savesDir := filepath.Join(dir, "saves")
+	if err := os.Mkdir(savesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(savesDir, filepath.Join(dir, "snapshots"), nil)
+	if err := m.LoadActive(filepath.Join(dir, "active.json")); err != nil {
+		t.Fatal(err)
+	}
+	return m, savesDir
+}
+
+func TestPrepareStartForcesSaveSelectedSinceLastStart(t *testing.T) {
+	m, dir := newActiveTestManager(t)
+	now := time.Now()
+	writeSave(t, dir, "world.zip", now.Add(-time.Hour))
+	writeSave(t, dir, "_autosave1.zip", now.Add(-time.Minute))
+	if err := m.SetActive("world.zip", Origin{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := m.PrepareStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "world.zip" || plan.Skipped != "" {
+		t.Errorf("plan = %+v, want world.zip forced", plan)
+	}
+	if latest, _ := m.Latest(); latest.Name != "world.zip" {
+		t.Errorf("latest = %s, want the forced world.zip", latest.Name)
+	}
+}
+
+func TestPrepareStartKeepsNewerAutosaveAfterUncleanStop(t *testing.T) {
+	m, dir := newActiveTestManager(t)
+	now := time.Now()
+	writeSave(t, dir, "world.zip", now.Add(-time.Hour))
+	if err := m.SetActive("world.zip", Origin{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.PrepareStart(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MarkStarted(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Сервер поиграл, записал автосейв и упал, не сохранив world.zip.
+	writeSave(t, dir, "world.zip", now.Add(-30*time.Minute))
+	writeSave(t, dir, "_autosave1.zip", now.Add(time.Minute))
+
+	plan, err := m.PrepareStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "_autosave1.zip" || plan.Skipped != "world.zip" {
+		t.Errorf("plan = %+v, want _autosave1.zip with world.zip skipped", plan)
+	}
+	if latest, _ := m.Latest(); latest.Name != "_autosave1.zip" {
+		t.Errorf("latest = %s, want the autosave left newest", latest.Name)
+	}
+
+	// Повторный выбор сейва — явное желание начать с него.
+	if err := m.SetActive("world.zip", Origin{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err := m.PrepareStart(); err != nil || plan.Name != "world.zip" {
+		t.Errorf("after reselecting: plan = %+v, err = %v; want world.zip", plan, err)
+	}
+}
+
+func TestPrepareStartForcesSaveAgainAfterFailedStart(t *testing.T) {
+	m, dir := newActiveTestManager(t)
+	now := time.Now()
+	writeSave(t, dir, "world.zip", now.Add(-time.Hour))
+	if err := m.SetActive("world.zip", Origin{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	// Контейнер не запустился: MarkStarted не вызван.
+	if _, err := m.PrepareStart(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Автосейв от прошлой игры оказался новее — выбор сейва всё равно не использован.
+	writeSave(t, dir, "_autosave1.zip", now.Add(time.Minute))
+	plan, err := m.PrepareStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "world.zip" || plan.Skipped != "" {
+		t.Errorf("plan = %+v, want world.zip forced on the retry", plan)
+	}
+
+	// После успешного старта выбор считается использованным и сохраняется в файл.
+	if err := m.MarkStarted(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewManager(dir, t.TempDir(), nil)
+	if err := reloaded.LoadActive(m.activeFile); err != nil {
+		t.Fatal(err)
+	}
+	writeSave(t, dir, "_autosave1.zip", now.Add(time.Hour))
+	if plan, err := reloaded.PrepareStart(); err != nil || plan.Skipped != "world.zip" {
+		t.Errorf("after a successful start: plan = %+v, err = %v; want the newer autosave", plan, err)
+	}
+}
+
+func TestPrepareStartWithoutActiveLoadsLatest(t *testing.T) {
+	m, dir := newActiveTestManager(t)
+	now := time.Now()
+	writeSave(t, dir, "old.zip", now.Add(-time.Hour))
+	writeSave(t, dir, "new.zip", now.Add(-time.Minute))
+
+	plan, err := m.PrepareStart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "new.zip" {
+		t.Errorf("plan = %+v, want new.zip", plan)
+	}
+}
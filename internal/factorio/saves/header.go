@@ -0,0 +1,386 @@
+package saves
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxHeaderBytes bounds how much of level.dat is decompressed to read the header.
+const maxHeaderBytes = 4 << 20
+
+// ticksPerSecond — игровое время Factorio идёт 60 тиков в секунду.
+const ticksPerSecond = 60
+
+// Header is the metadata stored at the start of a Factorio save.
+type Header struct {
+	Version     Version
+	Campaign    string
+	LevelName   string
+	BaseMod     string
+	Mods        []ModVersion
+	SpaceAge    bool
+	PlayedTicks uint64 // 0, если не удалось надёжно прочитать
+}
+
+// Version is a Factorio application version.
+type Version struct {
+	Major, Minor, Patch, Build uint16
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// ModVersion is a mod recorded in the save together with the version it was saved with.
+type ModVersion struct {
+	Name    string
+	Version string
+	CRC     uint32
+}
+
+// PlayTime converts PlayedTicks to wall time at normal game speed.
+func (h *Header) PlayTime() time.Duration {
+	return time.Duration(h.PlayedTicks) * time.Second / ticksPerSecond
+}
+
+// ReadHeader parses the header of the save zip read from r.
+func ReadHeader(r io.ReaderAt, size int64) (*Header, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("opening save zip: %w", err)
+	}
+
+	// Заголовок лежит в level-init.dat (1.1+), в первом куске level.dat0 или в level.dat (старые сейвы).
+	candidates := []string{"level-init.dat", "level.dat0", "level.dat"}
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[path.Base(f.Name)] = f
+	}
+
+	for _, name := range candidates {
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+		data, err := readLevelData(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		h, err := parseHeader(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", name, err)
+		}
+		return h, nil
+	}
+	return nil, errors.New("save has no level.dat / level-init.dat")
+}
+
+// readLevelData reads up to maxHeaderBytes of a level file, inflating it if it is zlib-compressed.
+func readLevelData(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(rc, maxHeaderBytes))
+	if err != nil {
+		return nil, err
+	}
+	// Несжатый заголовок начинается с мажорной версии (u16 LE), zlib — с байта 0x78.
+	if len(raw) < 2 || raw[0] != 0x78 {
+		return raw, nil
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, maxHeaderBytes))
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		// Обрезанный по лимиту поток — нормально, заголовок в начале.
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseHeader decodes the fixed part of the header: application version, level
+// identification, game flags and the mod list. Play time follows a variable-length
+// settings block and is only reported when it can be located unambiguously.
+func parseHeader(data []byte) (*Header, error) {
+	r := &headerReader{b: data}
+	h := &Header{}
+
+	h.Version = Version{Major: r.u16(), Minor: r.u16(), Patch: r.u16(), Build: r.u16()}
+	r.u8() // служебный флаг (quality version)
+	h.Campaign = r.str()
+	h.LevelName = r.str()
+	h.BaseMod = r.str()
+	r.u8()  // difficulty
+	r.u8()  // finished
+	r.u8()  // player won
+	r.str() // next level
+	r.u8()  // can continue
+	r.u8()  // finished but continuing
+	r.u8()  // saving replay
+	r.u8()  // allow non-admin debug options
+	r.ver() // loaded from version
+	r.u16() // loaded from build
+	r.u8()  // allowed commands
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	count := r.optU32()
+	if count > 10000 {
+		return nil, fmt.Errorf("implausible mod count %d", count)
+	}
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		m := ModVersion{Name: r.str(), Version: r.ver(), CRC: r.u32()}
+		h.Mods = append(h.Mods, m)
+		if m.Name == "space-age" {
+			h.SpaceAge = true
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("reading mod list: %w", r.err)
+	}
+	if h.Version.Major == 0 && h.Version.Minor == 0 {
+		return nil, errors.New("not a Factorio save header")
+	}
+
+	h.PlayedTicks = r.playedTicks()
+	return h, nil
+}
+
+// headerReader is a sticky-error little-endian reader for Factorio's binary format.
+type headerReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (r *headerReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.b) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	out := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return out
+}
+
+func (r *headerReader) u8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *headerReader) u16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *headerReader) u32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// optU16 reads a space-optimized u16: one byte, or 0xFF followed by a full u16.
+func (r *headerReader) optU16() uint16 {
+	if v := r.u8(); v != 0xFF {
+		return uint16(v)
+	}
+	return r.u16()
+}
+
+// optU32 reads a space-optimized u32: one byte, or 0xFF followed by a full u32.
+func (r *headerReader) optU32() uint32 {
+	if v := r.u8(); v != 0xFF {
+		return uint32(v)
+	}
+	return r.u32()
+}
+
+func (r *headerReader) str() string {
+	n := r.optU32()
+	return string(r.take(int(n)))
+}
+
+// ver reads a mod-style version: three space-optimized u16.
+func (r *headerReader) ver() string {
+	return fmt.Sprintf("%d.%d.%d", r.optU16(), r.optU16(), r.optU16())
+}
+
+// playedTicks tries to read the startup settings CRC and property tree that follow
+// the mod list, then the map ticks (update tick, entity tick, ticks played). Any
+// inconsistency yields 0 rather than a bogus number.
+func (r *headerReader) playedTicks() uint64 {
+	save := *r
+	defer func() { *r = save }()
+
+	r.u32() // startup mod settings CRC
+	if !r.skipPropertyTree(0) || r.err != nil {
+		return 0
+	}
+	updateTick, entityTick, played := r.u32(), r.u32(), r.u32()
+	if r.err != nil || played == 0 || played > updateTick || entityTick > updateTick {
+		return 0
+	}
+	return uint64(played)
+}
+
+// skipPropertyTree skips a serialized PropertyTree, reporting whether it was well-formed.
+func (r *headerReader) skipPropertyTree(depth int) bool {
+	if depth > 32 {
+		return false
+	}
+	typ := r.u8()
+	r.u8() // any-type flag
+	switch typ {
+	case 0: // none
+	case 1: // bool
+		r.u8()
+	case 2: // double
+		r.take(8)
+	case 3: // string: empty flag + string
+		if r.u8() == 0 {
+			r.str()
+		}
+	case 4, 5: // list / dictionary
+		n := r.u32()
+		if n > 100000 {
+			return false
+		}
+		for i := uint32(0); i < n && r.err == nil; i++ {
+			if r.u8() == 0 {
+				r.str()
+			}
+			if !r.skipPropertyTree(depth + 1) {
+				return false
+			}
+		}
+	case 6, 7: // signed / unsigned int64
+		r.take(8)
+	default:
+		return false
+	}
+	return r.err == nil
+}
+
+// ── compatibility ─────────────────────────────────────────────────────────────
+
+// Requirements describe what the running server can load.
+type Requirements struct {
+	// FactorioVersion is the server version, "major.minor.patch"; newer saves are
+	// rejected. "major.minor" is accepted when the exact version is not known yet,
+	// but then saves of a newer patch release get through.
+	FactorioVersion string
+	// Mods are the mods enabled on the server (builtin ones included) with their
+	// installed versions; an empty version is not checked.
+	Mods map[string]string
+}
+
+// Exact reports whether FactorioVersion includes the patch release.
+func (r Requirements) Exact() bool {
+	return strings.Count(r.FactorioVersion, ".") >= 2
+}
+
+// IncompatibleError lists why a save cannot be loaded by the server.
+type IncompatibleError struct {
+	Reasons []string
+}
+
+func (e *IncompatibleError) Error() string {
+	return "save is incompatible with the server: " + strings.Join(e.Reasons, "; ")
+}
+
+// CheckCompatible returns *IncompatibleError if the save was written by a newer Factorio
+// than the server runs, uses mods that are not enabled on the server, or was saved
+// with a newer version of a mod than the installed one. An older mod version is fine:
+// Factorio migrates the save forward when it loads it.
+func (h *Header) CheckCompatible(req Requirements) error {
+	var reasons []string
+
+	if req.FactorioVersion != "" && newerThan(h.Version, req.FactorioVersion) {
+		reasons = append(reasons, fmt.Sprintf("save version %s is newer than server %s", h.Version, req.FactorioVersion))
+	}
+
+	var missing, newer []string
+	for _, m := range h.Mods {
+		installed, ok := req.Mods[m.Name]
+		switch {
+		case !ok:
+			missing = append(missing, m.Name+" "+m.Version)
+		case installed != "" && compareDotted(m.Version, installed) > 0:
+			newer = append(newer, fmt.Sprintf("%s %s (server has %s)", m.Name, m.Version, installed))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		reasons = append(reasons, "mods not enabled on server: "+strings.Join(missing, ", "))
+	}
+	if len(newer) > 0 {
+		sort.Strings(newer)
+		reasons = append(reasons, "mods newer than installed: "+strings.Join(newer, ", "))
+	}
+
+	if len(reasons) > 0 {
+		return &IncompatibleError{Reasons: reasons}
+	}
+	return nil
+}
+
+// compareDotted compares "a.b.c" versions numerically: positive if a > b.
+func compareDotted(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			fmt.Sscanf(pa[i], "%d", &x) //nolint:errcheck
+		}
+		if i < len(pb) {
+			fmt.Sscanf(pb[i], "%d", &y) //nolint:errcheck
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// newerThan reports whether v is newer than the dotted server version, comparing only
+// as many components as the server version specifies.
+func newerThan(v Version, server string) bool {
+	have := []uint16{v.Major, v.Minor, v.Patch}
+	for i, part := range strings.Split(server, ".") {
+		if i >= len(have) {
+			break
+		}
+		var want uint16
+		fmt.Sscanf(part, "%d", &want) //nolint:errcheck
+		if have[i] != want {
+			return have[i] > want
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package saves
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// headerWriter encodes the fields parseHeader reads, in Factorio's binary format.
+type headerWriter struct{ bytes.Buffer }
+
+func (w *headerWriter) u8(v uint8)   { w.WriteByte(v) }
+func (w *headerWriter) u16(v uint16) { binary.Write(w, binary.LittleEndian, v) }
+func (w *headerWriter) u32(v uint32) { binary.Write(w, binary.LittleEndian, v) }
+
+func (w *headerWriter) opt(v uint32) {
+	if v < 0xFF {
+		w.u8(uint8(v))
+		return
+	}
+	w.u8(0xFF)
+	w.u32(v)
+}
+
+func (w *headerWriter) str(s string) {
+	w.opt(uint32(len(s)))
+	w.WriteString(s)
+}
+
+func (w *headerWriter) opt16(v uint16) {
+	if v < 0xFF {
+		w.u8(uint8(v))
+		return
+	}
+	w.u8(0xFF)
+	w.u16(v)
+}
+
+func (w *headerWriter) ver(major, minor, patch uint16) {
+	w.opt16(major)
+	w.opt16(minor)
+	w.opt16(patch)
+}
+
+// fixtureHeader is a level-init.dat header of a 2.0.73 Space Age save played for
+// one hour, with a startup settings tree between the mod list and the map ticks.
+func fixtureHeader() []byte {
+	var w headerWriter
+	w.u16(2)
+	w.u16(0)
+	w.u16(73)
+	w.u16(65)
+	w.u8(0)
+	w.str("")
+	w.str("Завод")
+	w.str("base")
+	for range 3 { // difficulty, finished, player won
+		w.u8(0)
+	}
+	w.str("")     // next level
+	for range 4 { // can continue, …, allow debug options
+
+		w.u8(0)
+	}
+	w.ver(2, 0, 73)
+	w.u16(65)
+	w.u8(1)
+
+	mods := []struct {
+		name                string
+		major, minor, patch uint16
+	}{
+		{"base", 2, 0, 73},
+		{"elevated-rails", 2, 0, 73},
+		{"quality", 2, 0, 73},
+		{"space-age", 2, 0, 73},
+		{"flib", 0, 16, 300}, // 300 не влезает в один байт
+	}
+	w.opt(uint32(len(mods)))
+	for i, m := range mods {
+		w.str(m.name)
+		w.ver(m.major, m.minor, m.patch)
+		w.u32(uint32(i + 1))
+	}
+
+	w.u32(0xdeadbeef) // CRC настроек
+	w.u8(5)           // dictionary
+	w.u8(0)
+	w.u32(2)
+	w.u8(0)
+	w.str("startup")
+	w.u8(1) // bool
+	w.u8(0)
+	w.u8(1)
+	w.u8(0)
+	w.str("scale")
+	w.u8(2) // double
+	w.u8(0)
+	w.Write(make([]byte, 8))
+
+	w.u32(216500) // update tick
+	w.u32(216400) // entity tick
+	w.u32(216000) // ticks played
+	return w.Bytes()
+}
+
+func TestCheckCompatible(t *testing.T) {
+	header := &Header{
+		Version: Version{Major: 2, Minor: 0, Patch: 73},
+		Mods: []ModVersion{
+			{Name: "base", Version: "2.0.73"},
+			{Name: "flib", Version: "0.15.0"},
+		},
+	}
+	mods := map[string]string{"base": "", "flib": "0.15.0"}
+
+	tests := []struct {
+		name string
+		req  Requirements
+		ok   bool
+	}{
+		{"same version", Requirements{FactorioVersion: "2.0.73", Mods: mods}, true},
+		{"newer server", Requirements{FactorioVersion: "2.0.80", Mods: mods}, true},
+		{"older patch on server", Requirements{FactorioVersion: "2.0.60", Mods: mods}, false},
+		{"only major.minor known", Requirements{FactorioVersion: "2.0", Mods: mods}, true},
+		{"mod missing", Requirements{FactorioVersion: "2.0.73", Mods: map[string]string{"base": ""}}, false},
+		{"older mod on server", Requirements{FactorioVersion: "2.0.73", Mods: map[string]string{"base": "", "flib": "0.14.2"}}, false},
+		{"newer mod on server", Requirements{FactorioVersion: "2.0.73", Mods: map[string]string{"base": "", "flib": "0.16.0"}}, true},
+		{"mod not installed yet", Requirements{FactorioVersion: "2.0.73", Mods: map[string]string{"base": "", "flib": ""}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := header.CheckCompatible(tt.req)
+			var incompatible *IncompatibleError
+			if tt.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.As(err, &incompatible) {
+				t.Errorf("got %v, want *IncompatibleError", err)
+			}
+		})
+	}
+}
+
+func TestRequirementsExact(t *testing.T) {
+	if (Requirements{FactorioVersion: "2.0"}).Exact() {
+		t.Error(`"2.0" reported as exact`)
+	}
+	if !(Requirements{FactorioVersion: "2.0.60"}).Exact() {
+		t.Error(`"2.0.60" not reported as exact`)
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	h, err := parseHeader(fixtureHeader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != (Version{Major: 2, Minor: 0, Patch: 73, Build: 65}) || h.Version.String() != "2.0.73" {
+		t.Errorf("version = %+v", h.Version)
+	}
+	if h.LevelName != "Завод" || h.BaseMod != "base" || h.Campaign != "" {
+		t.Errorf("level = %q, base mod %q, campaign %q", h.LevelName, h.BaseMod, h.Campaign)
+	}
+	if len(h.Mods) != 5 || h.Mods[4] != (ModVersion{Name: "flib", Version: "0.16.300", CRC: 5}) {
+		t.Errorf("mods = %+v", h.Mods)
+	}
+	if !h.SpaceAge {
+		t.Error("Space Age not detected")
+	}
+	if h.PlayedTicks != 216000 || h.PlayTime() != time.Hour {
+		t.Errorf("played %d ticks, %s", h.PlayedTicks, h.PlayTime())
+	}
+
+	vanilla := bytes.Replace(fixtureHeader(), []byte("space-age"), []byte("space-ago"), 1)
+	if h, err := parseHeader(vanilla); err != nil || h.SpaceAge {
+		t.Errorf("save without the space-age mod: SpaceAge %v, %v", h != nil && h.SpaceAge, err)
+	}
+}
+
+func TestParseHeaderTruncated(t *testing.T) {
+	data := fixtureHeader()
+	// Список модов кончается перед CRC настроек: дальше всё необязательно.
+	modsEnd := bytes.Index(data, []byte{0xef, 0xbe, 0xad, 0xde})
+
+	for n := range data {
+		h, err := parseHeader(data[:n])
+		switch {
+		case n < modsEnd:
+			if err == nil {
+				t.Fatalf("header cut at %d of %d bytes parsed: %+v", n, len(data), h)
+			}
+		case err != nil:
+			t.Fatalf("header cut at %d after the mod list: %v", n, err)
+		case n < len(data) && h.PlayedTicks != 0:
+			t.Fatalf("header cut at %d reported %d played ticks", n, h.PlayedTicks)
+		}
+	}
+}
+
+func TestParseHeaderCorrupt(t *testing.T) {
+	// Счётчик модов стоит перед первым из них: 5, затем "base" с длиной 4.
+	modCount := bytes.Index(fixtureHeader(), []byte("\x05\x04base"))
+
+	tests := []struct {
+		name   string
+		modify func(b []byte) []byte
+		err    string // пусто — заголовок читается, но без тиков
+	}{
+		{name: "empty", modify: func([]byte) []byte { return nil }, err: io.ErrUnexpectedEOF.Error()},
+		{name: "zero version", modify: func(b []byte) []byte { b[0] = 0; return b }, err: "not a Factorio save header"},
+		{
+			name:   "implausible mod count",
+			modify: func(b []byte) []byte { return append(b[:modCount:modCount], 0xFF, 0xFF, 0xFF, 0xFF, 0x7F) },
+			err:    "implausible mod count",
+		},
+		{
+			name:   "mod list runs past the end",
+			modify: func(b []byte) []byte { b[modCount] = 200; return b },
+			err:    "reading mod list",
+		},
+		{
+			name:   "huge string length",
+			modify: func(b []byte) []byte { return append(b[:11:11], 0xFF, 0xFF, 0xFF, 0xFF, 0xFF) },
+			err:    io.ErrUnexpectedEOF.Error(),
+		},
+		{
+			name:   "unknown property type",
+			modify: func(b []byte) []byte { b[bytes.Index(b, []byte{0xef, 0xbe, 0xad, 0xde})+4] = 9; return b },
+		},
+		{
+			name:   "played more than updated",
+			modify: func(b []byte) []byte { binary.LittleEndian.PutUint32(b[len(b)-4:], 300000); return b },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := parseHeader(tt.modify(fixtureHeader()))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.PlayedTicks != 0 || len(h.Mods) != 5 {
+				t.Errorf("played %d ticks, %d mods; want the mods without a bogus play time", h.PlayedTicks, len(h.Mods))
+			}
+		})
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(fixtureHeader())
+	zw.Close()
+
+	tests := []struct {
+		name    string
+		entries []zipEntry
+		err     string
+	}{
+		{name: "level-init.dat", entries: []zipEntry{saveControl, {name: "world/level-init.dat", body: string(fixtureHeader())}}},
+		{name: "compressed level.dat0", entries: []zipEntry{saveControl, {name: "world/level.dat0", body: compressed.String()}}},
+		{name: "no level files", entries: []zipEntry{saveControl}, err: "save has no level.dat"},
+		{
+			name:    "corrupt level-init.dat",
+			entries: []zipEntry{{name: "world/level-init.dat", body: "\x00\x00"}},
+			err:     "parsing level-init.dat",
+		},
+		{
+			name:    "broken zlib stream",
+			entries: []zipEntry{{name: "world/level.dat0", body: "\x78\x9c garbage"}},
+			err:     "reading level.dat0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildZip(t, tt.entries...)
+			h, err := ReadHeader(bytes.NewReader(data), int64(len(data)))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.LevelName != "Завод" || !h.SpaceAge || h.PlayedTicks != 216000 {
+				t.Errorf("header = %+v", h)
+			}
+		})
+	}
+	if _, err := ReadHeader(strings.NewReader("not a zip"), 9); err == nil {
+		t.Error("not a zip parsed")
+	}
+}
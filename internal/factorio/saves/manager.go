@@ -1,36 +1,108 @@
 package saves
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/domain"
 )
 
-// Manager handles reading and writing Factorio save files
+// Manager handles reading and writing Factorio save files.
+// Saves are never deleted directly: destructive operations first move them into
+// snapshotsDir, and, if a remote store is configured, snapshots are also pushed
+// off-site. Only snapshots beyond the retention limit are removed, oldest first.
 type Manager struct {
-	savesDir string
+	savesDir     string
+	snapshotsDir string
+	remote       domain.BackupStore // nil — снапшоты только локально
+	requirements func() (Requirements, error)
+	maxSaveSize  int64 // 0 — без ограничения
+	// keepSnapshots — сколько последних снапшотов хранить; 0 — все.
+	keepSnapshots int
+
+	mu         sync.Mutex
+	activeFile string     // где хранится выбор активного сейва; "" — только в памяти
+	active     activeSave // см. active.go
+
+	// Фоновые выгрузки снапшотов: pushCtx отменяется в Shutdown, pushes — чтобы их дождаться.
+	pushCtx    context.Context
+	stopPushes context.CancelFunc
+	pushes     sync.WaitGroup
 }
 
-func NewManager(savesDir string) *Manager {
-	return &Manager{savesDir: savesDir}
+func NewManager(savesDir, snapshotsDir string, remote domain.BackupStore) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		savesDir:     savesDir,
+		snapshotsDir: snapshotsDir,
+		remote:       remote,
+		pushCtx:      ctx,
+		stopPushes:   cancel,
+	}
 }
 
-// LatestSave returns the filename and raw bytes of the most recently modified .zip save
-func (m *Manager) LatestSave() (string, []byte, error) {
-	entries, err := os.ReadDir(m.savesDir)
-	if err != nil {
-		return "", nil, fmt.Errorf("reading saves dir: %w", err)
+// Shutdown waits for snapshot uploads still in progress. If ctx is done first, they
+// are cancelled: such snapshots stay local only.
+func (m *Manager) Shutdown(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		m.pushes.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Println("saves: WARN выгрузка снапшотов в хранилище прервана остановкой")
 	}
+	m.stopPushes()
+	<-done
+}
+
+// SetRequirements installs a callback describing what the running server can load.
+// When set, Replace rejects saves that fail Header.CheckCompatible.
+func (m *Manager) SetRequirements(fn func() (Requirements, error)) {
+	m.requirements = fn
+}
 
-	type saveFile struct {
-		name    string
-		modTime int64
+// SetMaxSaveSize sets the largest save Replace accepts, in bytes. 0 disables the limit.
+func (m *Manager) SetMaxSaveSize(n int64) {
+	m.maxSaveSize = n
+}
+
+// SetSnapshotRetention keeps only the newest n snapshots, locally and in the remote
+// store. 0 keeps all of them.
+func (m *Manager) SetSnapshotRetention(n int) {
+	m.keepSnapshots = n
+}
+
+// MaxSaveSize returns the upload size limit in bytes, 0 if there is none.
+func (m *Manager) MaxSaveSize() int64 {
+	return m.maxSaveSize
+}
+
+// SaveFile describes a save currently present in the saves dir.
+type SaveFile struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// List returns the .zip saves in the saves dir, newest first.
+func (m *Manager) List() ([]SaveFile, error) {
+	entries, err := os.ReadDir(m.savesDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading saves dir: %w", err)
 	}
 
-	var files []saveFile
+	var files []SaveFile
 	for _, entry := range entries {
 		if entry.IsDir() || filepath.Ext(entry.Name()) != ".zip" {
 			continue
@@ -39,66 +111,159 @@ func (m *Manager) LatestSave() (string, []byte, error) {
 		if err != nil {
 			continue
 		}
-		files = append(files, saveFile{name: entry.Name(), modTime: info.ModTime().UnixNano()})
+		files = append(files, SaveFile{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
 	}
 
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+	return files, nil
+}
+
+// Latest returns the most recently modified .zip save.
+func (m *Manager) Latest() (SaveFile, error) {
+	files, err := m.List()
+	if err != nil {
+		return SaveFile{}, err
+	}
 	if len(files) == 0 {
-		return "", nil, fmt.Errorf("no save files found in %s", m.savesDir)
+		return SaveFile{}, fmt.Errorf("no save files found in %s", m.savesDir)
 	}
+	return files[0], nil
+}
 
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].modTime > files[j].modTime
-	})
+// Open opens the named save in the saves dir for reading.
+func (m *Manager) Open(name string) (*os.File, error) {
+	if name != filepath.Base(name) || filepath.Ext(name) != ".zip" {
+		return nil, fmt.Errorf("invalid save name %q", name)
+	}
+	f, err := os.Open(filepath.Join(m.savesDir, name))
+	if err != nil {
+		return nil, fmt.Errorf("opening save file: %w", err)
+	}
+	return f, nil
+}
 
-	path := filepath.Join(m.savesDir, files[0].name)
-	data, err := os.ReadFile(path)
+// Inspect parses the header of the named save.
+func (m *Manager) Inspect(name string) (*Header, error) {
+	f, err := m.Open(name)
 	if err != nil {
-		return "", nil, fmt.Errorf("reading save file: %w", err)
+		return nil, err
 	}
+	defer f.Close()
 
-	return files[0].name, data, nil
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat save file: %w", err)
+	}
+	return ReadHeader(f, info.Size())
 }
 
-// CleanAutosaves removes Factorio autosave files (_autosave*.zip).
-// Call this before starting the server so the user's uploaded save is picked as the latest.
-func (m *Manager) CleanAutosaves() error {
-	entries, err := os.ReadDir(m.savesDir)
+// OpenLatest opens the most recently modified .zip save for reading.
+func (m *Manager) OpenLatest() (SaveFile, *os.File, error) {
+	save, err := m.Latest()
 	if err != nil {
-		return fmt.Errorf("reading saves dir: %w", err)
+		return SaveFile{}, nil, err
 	}
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		name := entry.Name()
-		if strings.HasPrefix(name, "_autosave") && strings.HasSuffix(name, ".zip") {
-			path := filepath.Join(m.savesDir, name)
-			if err := os.Remove(path); err != nil {
-				return fmt.Errorf("removing %s: %w", name, err)
-			}
-			log.Printf("saves: removed autosave %s", name)
-		}
+	f, err := m.Open(save.Name)
+	if err != nil {
+		return SaveFile{}, nil, err
 	}
-	return nil
+	return save, f, nil
 }
 
-// Replace removes all existing .zip saves and writes the new one
-func (m *Manager) Replace(filename string, data []byte) error {
-	entries, err := os.ReadDir(m.savesDir)
+// ArchiveExcept moves every .zip save except keep into a snapshot, e.g. after a new
+// map has been created next to them.
+func (m *Manager) ArchiveExcept(keep string, origin Origin, reason string) (*Snapshot, error) {
+	return m.snapshot(func(name string) bool { return name != keep }, origin, reason)
+}
+
+// isAutosave reports whether name is one of Factorio's rotating _autosaveN.zip files.
+func isAutosave(name string) bool {
+	return strings.HasPrefix(name, "_autosave")
+}
+
+// Replace streams an uploaded save from r into the saves dir and makes it active. The data is written to a
+// temporary file next to the destination and validated there; only then are the existing
+// .zip saves archived into a snapshot and the file renamed into place, so neither a
+// rejected upload nor a crash midway leaves a partial save behind.
+//
+// filename comes from the client and is sanitized; the name the save was actually
+// written under is returned. Rejections are *ValidationError or *IncompatibleError.
+func (m *Manager) Replace(filename string, r io.Reader, origin Origin) (Replaced, error) {
+	name, err := SanitizeFilename(filename)
 	if err != nil {
-		return fmt.Errorf("reading saves dir: %w", err)
+		return Replaced{}, err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".zip" {
-			_ = os.Remove(filepath.Join(m.savesDir, entry.Name()))
-		}
+	tmp, err := createTemp(filepath.Join(m.savesDir, name))
+	if err != nil {
+		return Replaced{}, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer tmp.Abort()
+
+	if m.maxSaveSize > 0 {
+		// Байт сверх лимита достаточно, чтобы Validate отклонил файл, — дальше не читаем.
+		r = io.LimitReader(r, m.maxSaveSize+1)
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return Replaced{}, fmt.Errorf("receiving save file: %w", err)
+	}
+	if err := Validate(tmp, size, m.maxSaveSize); err != nil {
+		return Replaced{}, err
+	}
+	unchecked, err := m.checkCompatible(tmp, size)
+	if err != nil {
+		return Replaced{}, err
 	}
 
-	destPath := filepath.Join(m.savesDir, filename)
-	if err := os.WriteFile(destPath, data, 0644); err != nil {
-		return fmt.Errorf("writing save file: %w", err)
+	if _, err := m.snapshot(func(string) bool { return true }, origin, "replaced by "+name); err != nil {
+		return Replaced{}, err
 	}
+	if err := tmp.Commit(); err != nil {
+		return Replaced{}, fmt.Errorf("writing save file: %w", err)
+	}
+
+	// Сейв загружают, чтобы на нём играть, — он и становится активным.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.setActiveLocked(name, origin); err != nil {
+		log.Printf("saves: %v", err)
+	}
+	return Replaced{Name: name, Unchecked: unchecked}, nil
+}
+
+// Replaced describes a save written by Replace.
+type Replaced struct {
+	Name string
+	// Unchecked says what part of the compatibility check could not be done, e.g. the
+	// header format is not understood; empty if the save was fully checked.
+	Unchecked string
+}
 
-	return nil
+// checkCompatible rejects a save the server cannot load. A header that cannot be parsed
+// is not a reason to reject, since the format changes between Factorio releases, but
+// the caller is told the check was skipped.
+func (m *Manager) checkCompatible(r io.ReaderAt, size int64) (string, error) {
+	if m.requirements == nil {
+		return "", nil
+	}
+
+	h, err := ReadHeader(r, size)
+	if err != nil {
+		log.Printf("saves: заголовок сейва не прочитан, проверка совместимости пропущена: %v", err)
+		return "заголовок сейва не прочитан, совместимость с сервером не проверена", nil
+	}
+	req, err := m.requirements()
+	if err != nil {
+		return "", fmt.Errorf("reading server requirements: %w", err)
+	}
+	if err := h.CheckCompatible(req); err != nil {
+		return "", err
+	}
+	if !req.Exact() {
+		return fmt.Sprintf("точная версия сервера неизвестна (сервер ещё не запускался), версия сейва %s сверена только с %s", h.Version, req.FactorioVersion), nil
+	}
+	return "", nil
 }
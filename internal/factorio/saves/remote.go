@@ -0,0 +1,165 @@
+package saves
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+const remoteSnapshotPrefix = "snapshots/"
+
+// pushSnapshot uploads the snapshot files and metadata to the remote store and marks
+// the local copy as offsite. Metadata goes last so a half-pushed snapshot is invisible.
+func (m *Manager) pushSnapshot(ctx context.Context, snap *Snapshot) error {
+	dir := filepath.Join(m.snapshotsDir, snap.ID)
+	for _, name := range snap.Files {
+		if err := m.pushFile(ctx, filepath.Join(dir, name), remoteSnapshotPrefix+snap.ID+"/"+name); err != nil {
+			return fmt.Errorf("pushing %s: %w", name, err)
+		}
+	}
+
+	snap.Offsite = true
+	meta, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling snapshot meta: %w", err)
+	}
+	if err := m.remote.Put(ctx, remoteSnapshotPrefix+snap.ID+"/"+snapshotMetaFile, bytes.NewReader(meta), int64(len(meta))); err != nil {
+		return fmt.Errorf("pushing snapshot meta: %w", err)
+	}
+	return safefile.WriteFile(filepath.Join(dir, snapshotMetaFile), meta, 0644)
+}
+
+// pruneRemoteSnapshots deletes the oldest snapshots in the remote store beyond the
+// retention limit. Metadata goes first, so a half-deleted snapshot is invisible.
+func (m *Manager) pruneRemoteSnapshots(ctx context.Context) error {
+	if m.keepSnapshots <= 0 {
+		return nil
+	}
+	snaps, err := m.remoteSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+	for _, snap := range oldestBeyond(snaps, m.keepSnapshots) {
+		prefix := remoteSnapshotPrefix + snap.ID + "/"
+		if err := m.remote.Delete(ctx, prefix+snapshotMetaFile); err != nil {
+			return fmt.Errorf("deleting snapshot %s: %w", snap.ID, err)
+		}
+		for _, name := range snap.Files {
+			if err := m.remote.Delete(ctx, prefix+name); err != nil {
+				log.Printf("saves: WARN не удалось удалить %s%s: %v", prefix, name, err)
+			}
+		}
+		log.Printf("saves: снапшот %s удалён из хранилища (храним последние %d)", snap.ID, m.keepSnapshots)
+	}
+	return nil
+}
+
+func (m *Manager) pushFile(ctx context.Context, src, key string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return m.remote.Put(ctx, key, f, info.Size())
+}
+
+// remoteSnapshots returns the metadata of every snapshot in the remote store.
+func (m *Manager) remoteSnapshots(ctx context.Context) ([]Snapshot, error) {
+	keys, err := m.remote.List(ctx, remoteSnapshotPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("listing remote snapshots: %w", err)
+	}
+
+	var snaps []Snapshot
+	for _, key := range keys {
+		if path.Base(key) != snapshotMetaFile {
+			continue
+		}
+		snap, err := m.readRemoteMeta(ctx, key)
+		if err != nil {
+			log.Printf("saves: пропускаю удалённый снапшот %s: %v", key, err)
+			continue
+		}
+		snaps = append(snaps, *snap)
+	}
+	return snaps, nil
+}
+
+func (m *Manager) readRemoteMeta(ctx context.Context, key string) (*Snapshot, error) {
+	rc, err := m.remote.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(rc).Decode(&snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// pullSnapshot downloads snapshot id from the remote store into the local snapshots dir.
+func (m *Manager) pullSnapshot(ctx context.Context, id string) error {
+	snap, err := m.readRemoteMeta(ctx, remoteSnapshotPrefix+id+"/"+snapshotMetaFile)
+	if err != nil {
+		return fmt.Errorf("remote snapshot %s: %w", id, err)
+	}
+
+	// Качаем во временную папку: оборванная загрузка не должна выглядеть как снапшот.
+	tmpDir := filepath.Join(m.snapshotsDir, ".pull-"+id)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return fmt.Errorf("creating snapshot dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	for _, name := range snap.Files {
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return fmt.Errorf("remote snapshot %s: invalid file name %q", id, name)
+		}
+		if err := m.pullFile(ctx, remoteSnapshotPrefix+id+"/"+name, filepath.Join(tmpDir, name)); err != nil {
+			return fmt.Errorf("pulling %s: %w", name, err)
+		}
+	}
+
+	meta, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling snapshot meta: %w", err)
+	}
+	if err := safefile.WriteFile(filepath.Join(tmpDir, snapshotMetaFile), meta, 0644); err != nil {
+		return fmt.Errorf("writing snapshot meta: %w", err)
+	}
+	return os.Rename(tmpDir, filepath.Join(m.snapshotsDir, id))
+}
+
+func (m *Manager) pullFile(ctx context.Context, key, dst string) error {
+	rc, err := m.remote.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := createTemp(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err := io.Copy(f, rc); err != nil {
+		return err
+	}
+	return f.Commit()
+}
@@ -0,0 +1,334 @@
+package saves
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+const (
+	snapshotMetaFile = "meta.json"
+	snapshotIDLayout = "20060102-150405"
+)
+
+// Origin describes who triggered a change to the saves directory and through which channel.
+type Origin struct {
+	Actor  string `json:"actor"`  // например "tg:123456789" или "bot"
+	Source string `json:"source"` // telegram, webapp, startServer, restart, restore…
+}
+
+// Snapshot is a set of save files moved out of the saves dir before a destructive operation.
+type Snapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Origin    Origin    `json:"origin"`
+	Reason    string    `json:"reason"`
+	Files     []string  `json:"files"`
+	Offsite   bool      `json:"offsite,omitempty"` // копия лежит в удалённом хранилище
+	// RemoteOnly — локальной копии нет, /restore скачает снапшот из хранилища.
+	RemoteOnly bool `json:"-"`
+}
+
+// Snapshots returns all archived snapshots, local and remote-only, newest first.
+func (m *Manager) Snapshots(ctx context.Context) ([]Snapshot, error) {
+	entries, err := os.ReadDir(m.snapshotsDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading snapshots dir: %w", err)
+	}
+
+	var snaps []Snapshot
+	local := make(map[string]bool)
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		snap, err := m.readSnapshot(e.Name())
+		if err != nil {
+			log.Printf("saves: пропускаю снапшот %s: %v", e.Name(), err)
+			continue
+		}
+		snaps = append(snaps, *snap)
+		local[snap.ID] = true
+	}
+
+	if m.remote != nil {
+		remote, err := m.remoteSnapshots(ctx)
+		if err != nil {
+			// Недоступное хранилище не должно прятать локальные снапшоты.
+			log.Printf("saves: %v", err)
+		}
+		for _, snap := range remote {
+			if !local[snap.ID] {
+				snap.RemoteOnly = true
+				snaps = append(snaps, snap)
+			}
+		}
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].CreatedAt.After(snaps[j].CreatedAt)
+	})
+	return snaps, nil
+}
+
+// Restore puts the files of snapshot id back into the saves dir. The saves currently
+// there are archived into a new snapshot first, so a restore can itself be rolled back.
+func (m *Manager) Restore(ctx context.Context, id string, origin Origin) error {
+	snap, err := m.readSnapshot(id)
+	if errors.Is(err, os.ErrNotExist) && m.remote != nil {
+		log.Printf("saves: снапшота %s нет локально, скачиваю из хранилища", id)
+		if err := m.pullSnapshot(ctx, id); err != nil {
+			return err
+		}
+		snap, err = m.readSnapshot(id)
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot %s: %w", id, err)
+	}
+
+	present := 0
+	for _, name := range snap.Files {
+		if _, err := os.Stat(filepath.Join(m.snapshotsDir, id, name)); err == nil {
+			present++
+		}
+	}
+	if present == 0 {
+		return fmt.Errorf("snapshot %s: no save files left in it", id)
+	}
+
+	if _, err := m.snapshot(func(string) bool { return true }, origin, "before restore of "+id); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	active := ""
+	for _, name := range snap.Files {
+		src := filepath.Join(m.snapshotsDir, id, name)
+		dst := filepath.Join(m.savesDir, name)
+		if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+			// Снапшот оборвался на середине переноса: этот файл остался в папке сейвов.
+			log.Printf("saves: в снапшоте %s нет %s, пропускаю", id, name)
+			continue
+		}
+		if err := copyFile(src, dst); err != nil {
+			return fmt.Errorf("restoring %s: %w", name, err)
+		}
+		if err := os.Chtimes(dst, now, now); err != nil {
+			return fmt.Errorf("touching %s: %w", name, err)
+		}
+		if active == "" || isAutosave(active) {
+			active = name
+		}
+	}
+	// Активным становится восстановленный сейв, автосейв — только если других нет.
+	if active != "" {
+		if err := m.SetActive(active, origin); err != nil {
+			log.Printf("saves: %v", err)
+		}
+	}
+
+	log.Printf("saves: восстановлен снапшот %s (%s via %s)", id, origin.Actor, origin.Source)
+	return nil
+}
+
+// snapshot moves every .zip in the saves dir accepted by match into a new snapshot
+// directory and writes its metadata. Returns nil if nothing matched.
+func (m *Manager) snapshot(match func(name string) bool, origin Origin, reason string) (*Snapshot, error) {
+	entries, err := os.ReadDir(m.savesDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading saves dir: %w", err)
+	}
+
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".zip" || !match(e.Name()) {
+			continue
+		}
+		files = append(files, e.Name())
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	id, dir, err := m.newSnapshotDir(now)
+	if err != nil {
+		return nil, err
+	}
+
+	// Метаданные пишем до переноса: если процесс упадёт посередине, перенесённые
+	// сейвы останутся видны в /restore, а не потеряются в папке без meta.json.
+	snap := &Snapshot{ID: id, CreatedAt: now, Origin: origin, Reason: reason, Files: files}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		os.Remove(dir)
+		return nil, fmt.Errorf("marshaling snapshot meta: %w", err)
+	}
+	if err := safefile.WriteFile(filepath.Join(dir, snapshotMetaFile), data, 0644); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("writing snapshot meta: %w", err)
+	}
+
+	for i, name := range files {
+		if err := moveFile(filepath.Join(m.savesDir, name), filepath.Join(dir, name)); err != nil {
+			m.rollbackSnapshot(dir, files[:i])
+			return nil, fmt.Errorf("archiving %s: %w", name, err)
+		}
+	}
+
+	log.Printf("saves: снапшот %s: %s (%s)", id, strings.Join(files, ", "), reason)
+	m.pruneSnapshots()
+
+	if m.remote != nil {
+		// Выгрузка большого сейва может занять минуты — не задерживаем ответ пользователю.
+		// Остановка бота ждёт её через Shutdown.
+		pushed := *snap
+		m.pushes.Add(1)
+		go func() {
+			defer m.pushes.Done()
+			if err := m.pushSnapshot(m.pushCtx, &pushed); err != nil {
+				log.Printf("saves: не удалось выгрузить снапшот %s: %v", pushed.ID, err)
+				return
+			}
+			log.Printf("saves: снапшот %s выгружен в хранилище", pushed.ID)
+			if err := m.pruneRemoteSnapshots(m.pushCtx); err != nil {
+				log.Printf("saves: WARN очистка старых снапшотов в хранилище: %v", err)
+			}
+		}()
+	}
+	return snap, nil
+}
+
+// rollbackSnapshot moves already archived files back into the saves dir and removes
+// the snapshot dir, unless a file cannot be moved back: then the snapshot stays.
+func (m *Manager) rollbackSnapshot(dir string, moved []string) {
+	for _, name := range moved {
+		if err := moveFile(filepath.Join(dir, name), filepath.Join(m.savesDir, name)); err != nil {
+			log.Printf("saves: WARN не удалось вернуть %s из %s: %v", name, filepath.Base(dir), err)
+			return
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("saves: WARN не удалось удалить %s: %v", dir, err)
+	}
+}
+
+// pruneSnapshots deletes the oldest local snapshots beyond the retention limit.
+func (m *Manager) pruneSnapshots() {
+	if m.keepSnapshots <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(m.snapshotsDir)
+	if err != nil {
+		log.Printf("saves: WARN чтение папки снапшотов: %v", err)
+		return
+	}
+	var snaps []Snapshot
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if snap, err := m.readSnapshot(e.Name()); err == nil {
+			snaps = append(snaps, *snap)
+		}
+	}
+	for _, snap := range oldestBeyond(snaps, m.keepSnapshots) {
+		if err := os.RemoveAll(filepath.Join(m.snapshotsDir, snap.ID)); err != nil {
+			log.Printf("saves: WARN не удалось удалить снапшот %s: %v", snap.ID, err)
+			continue
+		}
+		log.Printf("saves: снапшот %s удалён (храним последние %d)", snap.ID, m.keepSnapshots)
+	}
+}
+
+// oldestBeyond returns the snapshots that do not fit into the newest keep ones.
+func oldestBeyond(snaps []Snapshot, keep int) []Snapshot {
+	if len(snaps) <= keep {
+		return nil
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].CreatedAt.After(snaps[j].CreatedAt)
+	})
+	return snaps[keep:]
+}
+
+// newSnapshotDir creates a uniquely named snapshot directory for the given time.
+func (m *Manager) newSnapshotDir(now time.Time) (string, string, error) {
+	if err := os.MkdirAll(m.snapshotsDir, 0755); err != nil {
+		return "", "", fmt.Errorf("creating snapshots dir: %w", err)
+	}
+
+	base := now.Format(snapshotIDLayout)
+	for i := 0; ; i++ {
+		id := base
+		if i > 0 {
+			id = fmt.Sprintf("%s-%d", base, i)
+		}
+		dir := filepath.Join(m.snapshotsDir, id)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return id, dir, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return "", "", fmt.Errorf("creating snapshot dir: %w", err)
+		}
+	}
+}
+
+func (m *Manager) readSnapshot(id string) (*Snapshot, error) {
+	// id приходит от пользователя — не даём выйти за пределы папки снапшотов.
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("invalid snapshot id %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.snapshotsDir, id, snapshotMetaFile))
+	if err != nil {
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// moveFile renames src to dst, falling back to copy+remove across filesystems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src over dst atomically: dst is either untouched or the full copy.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := createTemp(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Abort()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Commit()
+}
@@ -0,0 +1,125 @@
+package saves
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRetention(t *testing.T) {
+	dir := t.TempDir()
+	savesDir, snapshotsDir := filepath.Join(dir, "saves"), filepath.Join(dir, "snapshots")
+	if err := os.Mkdir(savesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(savesDir, snapshotsDir, nil)
+	m.SetSnapshotRetention(2)
+
+	var ids []string
+	for _, name := range []string{"a.zip", "b.zip", "c.zip"} {
+		if err := os.WriteFile(filepath.Join(savesDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		snap, err := m.snapshot(func(string) bool { return true }, Origin{Actor: "test"}, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, snap.ID)
+	}
+
+	snaps, err := m.Snapshots(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(snaps))
+	}
+	if snaps[0].ID != ids[2] || snaps[1].ID != ids[1] {
+		t.Errorf("kept %s, %s; want the newest %s, %s", snaps[0].ID, snaps[1].ID, ids[2], ids[1])
+	}
+	if _, err := os.Stat(filepath.Join(snapshotsDir, ids[0])); !os.IsNotExist(err) {
+		t.Errorf("oldest snapshot %s was not removed", ids[0])
+	}
+}
+
+func TestRestoreSkipsFilesMissingFromInterruptedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	savesDir, snapshotsDir := filepath.Join(dir, "saves"), filepath.Join(dir, "snapshots")
+	if err := os.Mkdir(savesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(savesDir, snapshotsDir, nil)
+	for _, name := range []string{"a.zip", "b.zip"} {
+		if err := os.WriteFile(filepath.Join(savesDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap, err := m.snapshot(func(string) bool { return true }, Origin{Actor: "test"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Как после падения посреди переноса: meta.json перечисляет оба файла, а b.zip
+	// до снапшота не доехал.
+	if err := os.Remove(filepath.Join(snapshotsDir, snap.ID, "b.zip")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Restore(context.Background(), snap.ID, Origin{Actor: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(savesDir, "a.zip")); err != nil {
+		t.Errorf("a.zip not restored: %v", err)
+	}
+}
+
+// blockingStore holds every Put until its context is cancelled.
+type blockingStore struct {
+	started chan struct{}
+}
+
+func (s *blockingStore) Put(ctx context.Context, _ string, _ io.Reader, _ int64) error {
+	close(s.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *blockingStore) Get(context.Context, string) (io.ReadCloser, error) {
+	return nil, os.ErrNotExist
+}
+
+func (s *blockingStore) List(context.Context, string) ([]string, error) { return nil, nil }
+
+func (s *blockingStore) Delete(context.Context, string) error { return nil }
+
+func TestShutdownCancelsSnapshotPush(t *testing.T) {
+	dir := t.TempDir()
+	savesDir := filepath.Join(dir, "saves")
+	if err := os.Mkdir(savesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	store := &blockingStore{started: make(chan struct{})}
+	m := NewManager(savesDir, filepath.Join(dir, "snapshots"), store)
+	if err := os.WriteFile(filepath.Join(savesDir, "a.zip"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := m.snapshot(func(string) bool { return true }, Origin{Actor: "test"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-store.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	m.Shutdown(ctx) // вернётся, только когда выгрузка завершится
+
+	local, err := m.readSnapshot(snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local.Offsite {
+		t.Error("cancelled push marked the snapshot offsite")
+	}
+}
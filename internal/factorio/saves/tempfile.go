@@ -0,0 +1,17 @@
+package saves
+
+import (
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+// createTemp starts writing dst through safefile, so readers never observe a partially
+// written save. No .bak is kept: every replaced save is already in a snapshot, and the
+// temporary name does not end in .zip, so List and snapshot ignore it.
+func createTemp(dst string) (*safefile.File, error) {
+	f, err := safefile.Create(dst, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f.NoBackup = true
+	return f, nil
+}
@@ -0,0 +1,150 @@
+package saves
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxFilenameLen = 100
+	// maxInflateRatio bounds the uncompressed size relative to the upload limit (zip bombs).
+	maxInflateRatio = 10
+)
+
+// ValidationError explains why an uploaded save was rejected.
+type ValidationError struct {
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid save: " + e.Reason
+}
+
+func invalid(format string, args ...any) error {
+	return &ValidationError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// SanitizeFilename turns a client-supplied name into a safe base name ending in ".zip".
+// Directory components are dropped and characters outside letters, digits and " ._-"
+// are replaced with "_".
+func SanitizeFilename(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = path.Base(name)
+
+	base, ok := cutSuffixFold(name, ".zip")
+	if !ok {
+		return "", invalid("file name %q must end with .zip", name)
+	}
+
+	var sb strings.Builder
+	for _, r := range base {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' || r == '.' || r == '_' || r == '-':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	base = strings.Trim(sb.String(), " .")
+
+	if base == "" {
+		return "", invalid("file name %q is empty after sanitizing", name)
+	}
+	if utf8.RuneCountInString(base) > maxFilenameLen {
+		return "", invalid("file name is longer than %d characters", maxFilenameLen)
+	}
+	return base + ".zip", nil
+}
+
+// Validate checks that r holds a well-formed Factorio save of at most maxSize bytes:
+// a zip with a single top-level directory containing control.lua and level.dat*,
+// without absolute paths, ".." components, symlinks or an implausible inflated size.
+func Validate(r io.ReaderAt, size, maxSize int64) error {
+	if maxSize > 0 && size > maxSize {
+		return invalid("file is larger than %d MB", maxSize>>20)
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return invalid("not a valid zip archive: %v", err)
+	}
+	if len(zr.File) == 0 {
+		return invalid("zip archive is empty")
+	}
+
+	var (
+		top          string
+		hasControl   bool
+		hasLevel     bool
+		uncompressed uint64
+	)
+	for _, f := range zr.File {
+		name := f.Name
+		if err := checkEntryName(name); err != nil {
+			return err
+		}
+		if f.Mode()&fs.ModeSymlink != 0 {
+			return invalid("entry %q is a symlink", name)
+		}
+
+		dir, rest, nested := strings.Cut(name, "/")
+		if !nested {
+			return invalid("file %q is outside the save directory", name)
+		}
+		if top == "" {
+			top = dir
+		} else if dir != top {
+			return invalid("more than one top-level directory (%q and %q)", top, dir)
+		}
+
+		switch {
+		case rest == "control.lua":
+			hasControl = true
+		case strings.HasPrefix(rest, "level.dat") || rest == "level-init.dat":
+			hasLevel = true
+		}
+		uncompressed += f.UncompressedSize64
+	}
+
+	if !hasLevel {
+		return invalid("no level.dat in %q", top)
+	}
+	if !hasControl {
+		return invalid("no control.lua in %q", top)
+	}
+	if maxSize > 0 && uncompressed > uint64(maxSize)*maxInflateRatio {
+		return invalid("uncompressed size %d MB is implausibly large", uncompressed>>20)
+	}
+	return nil
+}
+
+// checkEntryName rejects zip entry names that could escape the extraction directory.
+func checkEntryName(name string) error {
+	switch {
+	case name == "":
+		return invalid("entry with empty name")
+	case strings.Contains(name, `\`):
+		return invalid("entry %q contains a backslash", name)
+	case strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':'):
+		return invalid("entry %q has an absolute path", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return invalid("entry %q contains path traversal", name)
+		}
+	}
+	return nil
+}
+
+func cutSuffixFold(s, suffix string) (string, bool) {
+	if len(s) < len(suffix) || !strings.EqualFold(s[len(s)-len(suffix):], suffix) {
+		return s, false
+	}
+	return s[:len(s)-len(suffix)], true
+}
@@ -0,0 +1,145 @@
+package saves
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// zipEntry is one file of an in-memory test archive.
+type zipEntry struct {
+	name string
+	body string
+	mode fs.FileMode // 0 — обычный файл
+}
+
+func buildZip(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			hdr.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var (
+	saveControl = zipEntry{name: "world/control.lua", body: "-- control"}
+	saveLevel   = zipEntry{name: "world/level.dat0", body: "level"}
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int64
+		reason  string // пусто — сейв допустим
+	}{
+		{name: "valid", data: buildZip(t, saveControl, saveLevel, zipEntry{name: "world/level-init.dat"})},
+		{name: "level-init only", data: buildZip(t, saveControl, zipEntry{name: "world/level-init.dat"})},
+		{name: "not a zip", data: []byte("PK but not really"), reason: "not a valid zip archive"},
+		{name: "empty zip", data: buildZip(t), reason: "zip archive is empty"},
+		{
+			name:   "parent traversal",
+			data:   buildZip(t, saveControl, saveLevel, zipEntry{name: "world/../../etc/cron.d/x"}),
+			reason: "contains path traversal",
+		},
+		{
+			name:   "leading traversal",
+			data:   buildZip(t, zipEntry{name: "../world/control.lua"}, saveLevel),
+			reason: "contains path traversal",
+		},
+		{name: "absolute path", data: buildZip(t, zipEntry{name: "/etc/passwd"}), reason: "has an absolute path"},
+		{name: "drive letter", data: buildZip(t, zipEntry{name: "C:/Windows/x"}), reason: "has an absolute path"},
+		{name: "backslash", data: buildZip(t, zipEntry{name: `world\..\x`}), reason: "contains a backslash"},
+		{
+			name:   "symlink",
+			data:   buildZip(t, saveControl, saveLevel, zipEntry{name: "world/link", body: "/etc/passwd", mode: fs.ModeSymlink | 0777}),
+			reason: "is a symlink",
+		},
+		{name: "file at top level", data: buildZip(t, zipEntry{name: "control.lua"}), reason: "outside the save directory"},
+		{
+			name:   "two top-level dirs",
+			data:   buildZip(t, saveControl, saveLevel, zipEntry{name: "other/level.dat0"}),
+			reason: "more than one top-level directory",
+		},
+		{name: "no level.dat", data: buildZip(t, saveControl), reason: "no level.dat"},
+		{name: "no control.lua", data: buildZip(t, saveLevel), reason: "no control.lua"},
+		{
+			name:    "archive over the limit",
+			data:    buildZip(t, saveControl, saveLevel, zipEntry{name: "world/blob", body: strings.Repeat("x", 4096)}),
+			maxSize: 100,
+			reason:  "file is larger than",
+		},
+		{
+			name:    "zip bomb",
+			data:    buildZip(t, saveControl, saveLevel, zipEntry{name: "world/blob", body: strings.Repeat("\x00", 1<<20)}),
+			maxSize: 64 << 10,
+			reason:  "implausibly large",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(bytes.NewReader(tt.data), int64(len(tt.data)), tt.maxSize)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || !strings.Contains(verr.Reason, tt.reason) {
+				t.Errorf("err = %v, want a ValidationError containing %q", err, tt.reason)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"world.zip", "world.zip"},
+		{"My World.ZIP", "My World.zip"},
+		{"../../etc/passwd.zip", "passwd.zip"},
+		{`C:\Users\x\..\evil.zip`, "evil.zip"},
+		{"a/b/..zip", ""},
+		{"name;rm -rf $HOME.zip", "name_rm -rf _HOME.zip"},
+		{"мир\x00\n.zip", "мир__.zip"},
+		{" .hidden. .zip", "hidden.zip"},
+		{"Завод 2.0.zip", "Завод 2.0.zip"},
+		{"world.zip.exe", ""},
+		{".zip", ""},
+		{strings.Repeat("a", 101) + ".zip", ""},
+		{strings.Repeat("я", 100) + ".zip", strings.Repeat("я", 100) + ".zip"},
+	}
+	for _, tt := range tests {
+		got, err := SanitizeFilename(tt.in)
+		if tt.want == "" {
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Errorf("SanitizeFilename(%q) = %q, %v; want a ValidationError", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("SanitizeFilename(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package settings
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Keys of ServerSettings are addressed by their json names; nested objects use dots,
+// e.g. "visibility.public".
+
+// readOnly keys are managed by the bot: the passwords are regenerated on every start.
+var readOnly = map[string]bool{
+	"game_password": true,
+	"rcon_password": true,
+}
+
+// secret keys are masked when shown.
+var secret = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"game_password": true,
+	"rcon_password": true,
+}
+
+// Keys returns all settable keys in the order of ServerSettings.
+func Keys() []string {
+	var keys []string
+	walkFields(reflect.ValueOf(&ServerSettings{}).Elem(), "", func(key string, _ reflect.Value) {
+		if !readOnly[key] {
+			keys = append(keys, key)
+		}
+	})
+	return keys
+}
+
+// IsSecret reports whether the value of key must not be shown in chat.
+func IsSecret(key string) bool {
+	return secret[key]
+}
+
+// Get returns the value of key formatted for display.
+func (s *ServerSettings) Get(key string) (string, error) {
+	v, err := s.field(key)
+	if err != nil {
+		return "", err
+	}
+	return format(v), nil
+}
+
+// Set parses raw according to the type of key and stores it. Lists are comma-separated.
+func (s *ServerSettings) Set(key, raw string) error {
+	if readOnly[key] {
+		return fmt.Errorf("%s is managed by the bot", key)
+	}
+	v, err := s.field(key)
+	if err != nil {
+		return err
+	}
+	raw = strings.TrimSpace(raw)
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", key, raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := parseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("%s cannot be set directly", key)
+	}
+	return nil
+}
+
+func (s *ServerSettings) field(key string) (reflect.Value, error) {
+	var found reflect.Value
+	walkFields(reflect.ValueOf(s).Elem(), "", func(k string, v reflect.Value) {
+		if k == key {
+			found = v
+		}
+	})
+	if !found.IsValid() {
+		return reflect.Value{}, fmt.Errorf("unknown setting %q", key)
+	}
+	return found, nil
+}
+
+// flatten returns every leaf setting by key.
+func flatten(s *ServerSettings) map[string]any {
+	values := make(map[string]any)
+	walkFields(reflect.ValueOf(s).Elem(), "", func(key string, v reflect.Value) {
+		values[key] = v.Interface()
+	})
+	return values
+}
+
+// walkFields calls fn for every leaf field, descending into nested structs.
+func walkFields(v reflect.Value, prefix string, fn func(key string, v reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		if f := v.Field(i); f.Kind() == reflect.Struct {
+			walkFields(f, key+".", fn)
+		} else {
+			fn(key, f)
+		}
+	}
+}
+
+func format(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func parseBool(raw string) (bool, error) {
+	switch strings.ToLower(raw) {
+	case "true", "on", "yes", "1", "да", "вкл":
+		return true, nil
+	case "false", "off", "no", "0", "нет", "выкл":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not true/false", raw)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,223 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+// Manager reads and edits server-settings.json. Factorio reads the file only when the
+// server starts, so the Manager remembers which keys were changed and when, to tell
+// which changes the running server has not picked up yet.
+type Manager struct {
+	file string
+
+	mu      sync.Mutex
+	changed map[string]time.Time // ключ → время последнего изменения через бота
+}
+
+func NewManager(file string) *Manager {
+	return &Manager{file: file, changed: make(map[string]time.Time)}
+}
+
+// Change is one edited setting. Old and New are formatted for display.
+type Change struct {
+	Key      string
+	Old, New string
+}
+
+// Load reads the current settings.
+func (m *Manager) Load() (*ServerSettings, error) {
+	s, _, err := load(m.file)
+	return s, err
+}
+
+// Preview returns the change setting key to raw would make, without writing it.
+func (m *Manager) Preview(key, raw string) (Change, error) {
+	_, change, err := m.edit(key, raw)
+	return change, err
+}
+
+// Set writes key = raw to the file. It takes effect on the next server start.
+func (m *Manager) Set(key, raw string) (Change, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, change, err := m.edit(key, raw)
+	if err != nil {
+		return Change{}, err
+	}
+	// Ключа нет в файле — значит, у сервера значение по умолчанию, а не нулевое из
+	// структуры; явно заданное значение записываем, даже если оно «не изменилось».
+	if change.Old == change.New && inFile(m.file, key) {
+		return change, nil
+	}
+	if err := write(m.file, s, key); err != nil {
+		return Change{}, err
+	}
+	m.changed[key] = time.Now()
+	return change, nil
+}
+
+// edit applies key = raw to the current settings. Only problems the change introduces
+// are reported, so a file that is already off somewhere can still be edited.
+func (m *Manager) edit(key, raw string) (*ServerSettings, Change, error) {
+	s, err := m.Load()
+	if err != nil {
+		return nil, Change{}, err
+	}
+	before := make(map[string]bool)
+	for _, p := range problems(s) {
+		before[p] = true
+	}
+	old, err := s.Get(key)
+	if err != nil {
+		return nil, Change{}, err
+	}
+	if err := s.Set(key, raw); err != nil {
+		return nil, Change{}, err
+	}
+
+	var introduced []string
+	for _, p := range problems(s) {
+		if !before[p] {
+			introduced = append(introduced, p)
+		}
+	}
+	if len(introduced) > 0 {
+		return nil, Change{}, &ValidationError{Problems: introduced}
+	}
+
+	updated, _ := s.Get(key)
+	return s, Change{Key: key, Old: old, New: updated}, nil
+}
+
+// Pending returns the keys changed after the server started at startedAt, in the
+// order of Keys. A zero startedAt (server not running) makes every change pending.
+func (m *Manager) Pending(startedAt time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var keys []string
+	for _, key := range Keys() {
+		at, ok := m.changed[key]
+		if !ok {
+			continue
+		}
+		if !startedAt.IsZero() && at.Before(startedAt) {
+			delete(m.changed, key) // сервер уже перечитал файл
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func problems(s *ServerSettings) []string {
+	var invalid *ValidationError
+	if errors.As(s.Validate(), &invalid) {
+		return invalid.Problems
+	}
+	return nil
+}
+
+// load reads the file both typed and as raw keys; the raw form keeps comments and
+// settings the struct does not know about.
+func load(file string) (*ServerSettings, map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading server settings: %w", err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("parsing server settings: %w", err)
+	}
+	var s ServerSettings
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, nil, fmt.Errorf("parsing server settings: %w", err)
+	}
+	return &s, raw, nil
+}
+
+// write stores the given keys of s in the file. Every other key is left exactly as it
+// is: a key missing from the file means the Factorio default, and writing the Go zero
+// value over it would change the server's behaviour (max_upload_slots 0 is unlimited).
+func write(file string, s *ServerSettings, keys ...string) error {
+	_, raw, err := load(file)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		v, err := s.field(key)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Errorf("marshaling %s: %w", key, err)
+		}
+		if err := setPath(raw, strings.Split(key, "."), value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling server settings: %w", err)
+	}
+	if err := safefile.WriteFile(file, out, 0644); err != nil {
+		return fmt.Errorf("writing server settings: %w", err)
+	}
+	return nil
+}
+
+// inFile reports whether the dotted key is present in the file.
+func inFile(file, key string) bool {
+	_, raw, err := load(file)
+	if err != nil {
+		return false
+	}
+	path := strings.Split(key, ".")
+	for _, name := range path[:len(path)-1] {
+		value, ok := raw[name]
+		if !ok {
+			return false
+		}
+		raw = nil
+		if err := json.Unmarshal(value, &raw); err != nil {
+			return false
+		}
+	}
+	_, ok := raw[path[len(path)-1]]
+	return ok
+}
+
+// setPath stores value under a dotted path, creating nested objects as needed and
+// keeping their other keys.
+func setPath(raw map[string]json.RawMessage, path []string, value json.RawMessage) error {
+	if len(path) == 1 {
+		raw[path[0]] = value
+		return nil
+	}
+	nested := make(map[string]json.RawMessage)
+	if existing, ok := raw[path[0]]; ok {
+		if err := json.Unmarshal(existing, &nested); err != nil {
+			return fmt.Errorf("%s is not an object", path[0])
+		}
+	}
+	if err := setPath(nested, path[1:], value); err != nil {
+		return err
+	}
+	data, err := json.Marshal(nested)
+	if err != nil {
+		return err
+	}
+	raw[path[0]] = data
+	return nil
+}
@@ -0,0 +1,91 @@
+package settings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeSettings(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "server-settings.json")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func readSettings(t *testing.T, file string) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("settings file is not valid JSON: %v", err)
+	}
+	return m
+}
+
+func TestUpdatePasswordsKeepsPartialFile(t *testing.T) {
+	file := writeSettings(t, `{"name":"x","max_heartbeats_per_second":60,"_comment_name":"keep me"}`)
+
+	if err := UpdatePasswords(file, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"name":                      "x",
+		"max_heartbeats_per_second": float64(60),
+		"_comment_name":             "keep me",
+		"game_password":             "secret",
+		"rcon_password":             "secret",
+	}
+	if got := readSettings(t, file); !reflect.DeepEqual(got, want) {
+		t.Errorf("file after UpdatePasswords:\n got  %v\n want %v", got, want)
+	}
+}
+
+func TestSetWritesOnlyChangedKey(t *testing.T) {
+	file := writeSettings(t, `{"name":"x","visibility":{"lan":true}}`)
+	m := NewManager(file)
+
+	if _, err := m.Set("max_players", "10"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Set("visibility.public", "false"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"name":        "x",
+		"max_players": float64(10),
+		"visibility":  map[string]any{"lan": true, "public": false},
+	}
+	if got := readSettings(t, file); !reflect.DeepEqual(got, want) {
+		t.Errorf("file after Set:\n got  %v\n want %v", got, want)
+	}
+}
+
+func TestSetUnchangedValueDoesNotWrite(t *testing.T) {
+	file := writeSettings(t, `{"name":"x"}`)
+	m := NewManager(file)
+
+	if _, err := m.Set("name", "x"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"x"}` {
+		t.Errorf("file rewritten for a no-op change: %s", data)
+	}
+	if pending := m.Pending(time.Time{}); len(pending) != 0 {
+		t.Errorf("no-op change reported as pending: %v", pending)
+	}
+}
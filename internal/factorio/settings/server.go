@@ -0,0 +1,110 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServerSettings is the typed view of server-settings.json. Keys that are not listed
+// here ("_comment_*", options of newer Factorio versions) are kept in the file as is.
+type ServerSettings struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Tags        []string   `json:"tags"`
+	MaxPlayers  int        `json:"max_players"`
+	Visibility  Visibility `json:"visibility"`
+
+	Username                string `json:"username"`
+	Password                string `json:"password"`
+	Token                   string `json:"token"`
+	GamePassword            string `json:"game_password"`
+	RconPassword            string `json:"rcon_password"`
+	RequireUserVerification bool   `json:"require_user_verification"`
+
+	MaxUploadInKilobytesPerSecond        int    `json:"max_upload_in_kilobytes_per_second"`
+	MaxUploadSlots                       int    `json:"max_upload_slots"`
+	MinimumLatencyInTicks                int    `json:"minimum_latency_in_ticks"`
+	MaxHeartbeatsPerSecond               int    `json:"max_heartbeats_per_second"`
+	IgnorePlayerLimitForReturningPlayers bool   `json:"ignore_player_limit_for_returning_players"`
+	AllowCommands                        string `json:"allow_commands"`
+
+	AutosaveInterval            int  `json:"autosave_interval"`
+	AutosaveSlots               int  `json:"autosave_slots"`
+	AfkAutokickInterval         int  `json:"afk_autokick_interval"`
+	AutoPause                   bool `json:"auto_pause"`
+	AutoPauseWhenPlayersConnect bool `json:"auto_pause_when_players_connect"`
+	OnlyAdminsCanPauseTheGame   bool `json:"only_admins_can_pause_the_game"`
+	AutosaveOnlyOnServer        bool `json:"autosave_only_on_server"`
+	NonBlockingSaving           bool `json:"non_blocking_saving"`
+
+	MinimumSegmentSize          int `json:"minimum_segment_size"`
+	MinimumSegmentSizePeerCount int `json:"minimum_segment_size_peer_count"`
+	MaximumSegmentSize          int `json:"maximum_segment_size"`
+	MaximumSegmentSizePeerCount int `json:"maximum_segment_size_peer_count"`
+}
+
+type Visibility struct {
+	Public bool `json:"public"`
+	LAN    bool `json:"lan"`
+}
+
+// intRange is the accepted interval of an integer setting.
+type intRange struct {
+	min, max int
+}
+
+// intLimits follow the comments in Factorio's server-settings.example.json.
+var intLimits = map[string]intRange{
+	"max_players":                        {0, 65535},
+	"max_upload_in_kilobytes_per_second": {0, 1 << 20},
+	"max_upload_slots":                   {0, 1000},
+	"minimum_latency_in_ticks":           {0, 1000},
+	"max_heartbeats_per_second":          {6, 240},
+	"autosave_interval":                  {1, 24 * 60},
+	"autosave_slots":                     {1, 1000},
+	"afk_autokick_interval":              {0, 24 * 60},
+	"minimum_segment_size":               {1, 10000},
+	"minimum_segment_size_peer_count":    {1, 10000},
+	"maximum_segment_size":               {1, 10000},
+	"maximum_segment_size_peer_count":    {1, 10000},
+}
+
+// ValidationError lists every problem found in the settings.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid server settings: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks ranges and the rules Factorio enforces when the server starts.
+func (s *ServerSettings) Validate() error {
+	var problems []string
+
+	values := flatten(s)
+	for _, key := range sortedKeys(intLimits) {
+		r := intLimits[key]
+		v := values[key].(int)
+		if v < r.min || v > r.max {
+			problems = append(problems, fmt.Sprintf("%s = %d is outside [%d, %d]", key, v, r.min, r.max))
+		}
+	}
+
+	switch s.AllowCommands {
+	case "true", "false", "admins-only":
+	default:
+		problems = append(problems, fmt.Sprintf("allow_commands = %q, expected true, false or admins-only", s.AllowCommands))
+	}
+	if s.MinimumSegmentSize > s.MaximumSegmentSize {
+		problems = append(problems, "minimum_segment_size is greater than maximum_segment_size")
+	}
+	if s.Visibility.Public && (s.Username == "" || (s.Token == "" && s.Password == "")) {
+		problems = append(problems, "visibility.public requires username and token (or password) of a factorio.com account")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
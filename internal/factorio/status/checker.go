@@ -1,25 +1,193 @@
 package status
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"os/exec"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+const probeTimeout = 3 * time.Second
+
+// Factorio network message types (low 5 bits of the first byte of a datagram).
+const (
+	msgConnectionRequest      = 2
+	msgConnectionRequestReply = 3
 )
 
-// Checker probes the Factorio game port to determine if the server is reachable
+// Checker probes the Factorio server: the UDP game port with a connection handshake
+// and, if an executor is configured, the RCON interface with a cheap command.
 type Checker struct {
 	host string
 	port string
+	rcon domain.RconExecutor
+
+	mu          sync.Mutex
+	lastVersion string // последняя увиденная версия сервера, "major.minor.patch"
+}
+
+// NewChecker creates a Checker. rcon may be nil, in which case only the game port is probed.
+func NewChecker(host, port string, rcon domain.RconExecutor) *Checker {
+	return &Checker{host: host, port: port, rcon: rcon}
+}
+
+// Result is the outcome of a single Check.
+type Result struct {
+	CheckedAt time.Time
+	Game      Probe
+	Rcon      Probe
+}
+
+// Probe is the outcome of probing one interface.
+type Probe struct {
+	Checked bool // false, если проба не выполнялась (например, RCON не настроен)
+	OK      bool
+	Latency time.Duration
+	Version string // версия сервера, если её удалось узнать
+	Err     error
+}
+
+// Online reports whether players can reach the server, i.e. the game port answers.
+func (r Result) Online() bool { return r.Game.OK }
+
+// Check probes the game port and RCON concurrently.
+func (c *Checker) Check(ctx context.Context) Result {
+	res := Result{CheckedAt: time.Now()}
+
+	rconDone := make(chan Probe, 1)
+	go func() { rconDone <- c.probeRcon(ctx) }()
+
+	res.Game = c.probeGame(ctx)
+	res.Rcon = <-rconDone
+
+	for _, v := range []string{res.Rcon.Version, res.Game.Version} {
+		if v = numericVersion(v); v != "" {
+			c.mu.Lock()
+			c.lastVersion = v
+			c.mu.Unlock()
+			break
+		}
+	}
+	return res
+}
+
+// ServerVersion returns the exact version of the server, "major.minor.patch": probed
+// now if the server is up, otherwise the last one seen. Empty if the server has not
+// been seen running since the bot started.
+func (c *Checker) ServerVersion(ctx context.Context) string {
+	c.Check(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastVersion
+}
+
+// numericVersion keeps the leading "major.minor.patch" of a version string such as
+// "2.0.60" from RCON /version or "2.0.60 (build 81234)" from the game port.
+func numericVersion(v string) string {
+	fields := strings.Fields(v)
+	if len(fields) == 0 || strings.Count(fields[0], ".") != 2 {
+		return ""
+	}
+	return fields[0]
+}
+
+func (c *Checker) probeRcon(ctx context.Context) Probe {
+	if c.rcon == nil {
+		return Probe{}
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := c.rcon.ExecuteContext(domain.Idempotent(ctx), "/version")
+	p := Probe{Checked: true, Latency: time.Since(start), Err: err}
+	if err == nil {
+		p.OK = true
+		p.Version = strings.TrimSpace(resp)
+	}
+	return p
+}
+
+// probeGame sends a ConnectionRequest datagram to the game port. Any answer means the
+// server is accepting connections; a ConnectionRequestReply additionally carries its version.
+func (c *Checker) probeGame(ctx context.Context) Probe {
+	p := Probe{Checked: true}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", net.JoinHostPort(c.host, c.port))
+	if err != nil {
+		p.Err = fmt.Errorf("resolve game port: %w", err)
+		return p
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		p.Err = err
+		return p
+	}
+
+	req, err := connectionRequest()
+	if err != nil {
+		p.Err = err
+		return p
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		p.Err = fmt.Errorf("send handshake: %w", err)
+		return p
+	}
+
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			p.Err = errors.New("no handshake reply")
+		} else {
+			// На Linux «connection refused» приходит через ICMP port unreachable.
+			p.Err = fmt.Errorf("read handshake reply: %w", err)
+		}
+		return p
+	}
+
+	p.OK = true
+	p.Latency = time.Since(start)
+	p.Version = parseReplyVersion(buf[:n])
+	return p
 }
 
-func NewChecker(host, port string) *Checker {
-	return &Checker{host: host, port: port}
+// connectionRequest builds a ConnectionRequest message: type byte, client version
+// (major, minor, sub as space-optimized u16 plus u32 build) and a random request id.
+// The server replies even when versions differ, which is all a liveness probe needs.
+func connectionRequest() ([]byte, error) {
+	msg := []byte{msgConnectionRequest, 2, 0, 0, 0, 0, 0, 0}
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generating request id: %w", err)
+	}
+	return append(msg, id...), nil
 }
 
-// Check returns a human-readable status string
-func (c *Checker) Check() string {
-	cmd := exec.Command("sh", "-c", fmt.Sprintf("nc -zv %s %s 2>&1", c.host, c.port))
-	if err := cmd.Run(); err != nil {
-		return "🔴 Недоступен"
+// parseReplyVersion extracts "major.minor.sub" from a ConnectionRequestReply, or "".
+func parseReplyVersion(b []byte) string {
+	if len(b) < 8 || b[0]&0x1f != msgConnectionRequestReply {
+		return ""
+	}
+	build := binary.LittleEndian.Uint32(b[4:8])
+	if build == 0 {
+		return fmt.Sprintf("%d.%d.%d", b[1], b[2], b[3])
 	}
-	return "🟢 Работает"
+	return fmt.Sprintf("%d.%d.%d (build %d)", b[1], b[2], b[3], build)
 }
@@ -0,0 +1,124 @@
+package status
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/factorio/rcon"
+)
+
+// udpResponder answers every ConnectionRequest with reply; a nil reply means silence.
+// It returns the address to probe and the requests it has seen.
+func udpResponder(t *testing.T, reply []byte) (host, port string, requests <-chan []byte) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	seen := make(chan []byte, 10)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			seen <- append([]byte(nil), buf[:n]...)
+			if reply != nil {
+				pc.WriteTo(reply, addr)
+			}
+		}
+	}()
+	host, port, _ = net.SplitHostPort(pc.LocalAddr().String())
+	return host, port, seen
+}
+
+// connectionRequestReply builds a ConnectionRequestReply for version major.minor.sub.
+func connectionRequestReply(major, minor, sub byte, build uint32) []byte {
+	b := []byte{msgConnectionRequestReply, major, minor, sub, 0, 0, 0, 0, 0xaa, 0xbb}
+	binary.LittleEndian.PutUint32(b[4:8], build)
+	return b
+}
+
+func TestProbeGameReply(t *testing.T) {
+	host, port, requests := udpResponder(t, connectionRequestReply(2, 0, 60, 81234))
+	c := NewChecker(host, port, nil)
+
+	p := c.probeGame(context.Background())
+	if !p.OK || p.Err != nil {
+		t.Fatalf("probe = %+v", p)
+	}
+	if p.Version != "2.0.60 (build 81234)" {
+		t.Errorf("version = %q", p.Version)
+	}
+	req := <-requests
+	if len(req) != 12 || req[0] != msgConnectionRequest {
+		t.Errorf("request = % x, want a 12-byte ConnectionRequest", req)
+	}
+	if v := c.ServerVersion(context.Background()); v != "2.0.60" {
+		t.Errorf("ServerVersion = %q", v)
+	}
+}
+
+func TestProbeGameMalformedReply(t *testing.T) {
+	// Сервер ответил — значит, он жив, даже если ответ не разобрать.
+	for _, reply := range [][]byte{{0x1f}, {msgConnectionRequestReply, 2, 0}, []byte("garbage datagram")} {
+		host, port, _ := udpResponder(t, reply)
+		p := NewChecker(host, port, nil).probeGame(context.Background())
+		if !p.OK || p.Version != "" {
+			t.Errorf("reply % x: probe = %+v, want OK without a version", reply, p)
+		}
+	}
+}
+
+func TestProbeGameTimeout(t *testing.T) {
+	host, port, _ := udpResponder(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	p := NewChecker(host, port, nil).probeGame(ctx)
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("probe took %s, want the context deadline", elapsed)
+	}
+	if p.OK || p.Err == nil || p.Err.Error() != "no handshake reply" {
+		t.Errorf("probe = %+v, want no handshake reply", p)
+	}
+}
+
+func TestProbeGameClosedPort(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	pc.Close()
+
+	p := NewChecker(host, port, nil).probeGame(context.Background())
+	if p.OK || p.Err == nil {
+		t.Errorf("probe of a closed port = %+v", p)
+	}
+}
+
+func TestCheckCombinesProbes(t *testing.T) {
+	host, port, _ := udpResponder(t, connectionRequestReply(2, 0, 60, 0))
+	exec := rcon.NewFakeExecutor()
+	exec.Responses["/version"] = "2.0.61\n"
+
+	res := NewChecker(host, port, exec).Check(context.Background())
+	if !res.Online() || res.Game.Version != "2.0.60" {
+		t.Errorf("game = %+v", res.Game)
+	}
+	if !res.Rcon.OK || res.Rcon.Version != "2.0.61" {
+		t.Errorf("rcon = %+v", res.Rcon)
+	}
+	if strings.Join(exec.Calls(), ",") != "/version" {
+		t.Errorf("rcon calls = %q", exec.Calls())
+	}
+}
@@ -0,0 +1,200 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+const (
+	// historyRetention — переходы старше этого срока выбрасываются при записи.
+	historyRetention = 30 * 24 * time.Hour
+	// heartbeatInterval — как часто сохранять отметку «бот ещё следит за сервером».
+	// Столько времени до падения бота может попасть в аптайм как известное состояние.
+	heartbeatInterval = 5 * time.Minute
+)
+
+// Transition is a change of the server state observed by the Monitor. An Unknown
+// transition starts a period when nobody watched the server (the bot was down).
+type Transition struct {
+	At      time.Time `json:"at"`
+	Online  bool      `json:"online"`
+	Unknown bool      `json:"unknown,omitempty"`
+	Reason  string    `json:"reason,omitempty"`
+}
+
+// History persists state transitions to a JSON file and computes uptime from them.
+type History struct {
+	mu          sync.Mutex
+	file        string
+	transitions []Transition
+	seen        time.Time // последняя проверка, о которой известно, что она была
+	savedSeen   time.Time // значение seen в файле
+}
+
+// historyFile is the on-disk form of a History.
+type historyFile struct {
+	Seen        time.Time    `json:"seen,omitzero"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// LoadHistory reads the history file; a missing file yields an empty history.
+func LoadHistory(file string) (*History, error) {
+	h := &History{file: file}
+
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading status history: %w", err)
+	}
+	var stored historyFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		// Старый формат — просто массив переходов, без отметки последней проверки.
+		if errLegacy := json.Unmarshal(data, &stored.Transitions); errLegacy != nil {
+			return nil, fmt.Errorf("parsing status history: %w", err)
+		}
+	}
+	h.transitions = stored.Transitions
+	h.seen, h.savedSeen = stored.Seen, stored.Seen
+	return h, nil
+}
+
+// Started marks the period since the last check the bot is known to have made as
+// unknown: the bot was not running and the server may have been in any state.
+// Called once when monitoring starts, before the first check.
+func (h *History) Started() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.transitions) == 0 {
+		return nil
+	}
+	last := h.transitions[len(h.transitions)-1]
+	if last.Unknown {
+		return nil
+	}
+	from := last.At
+	if h.seen.After(from) {
+		from = h.seen
+	}
+	h.transitions = append(h.transitions, Transition{At: from, Unknown: true, Reason: "бот не работал"})
+	return h.save()
+}
+
+// Seen notes that the server was checked at t. The mark is persisted at most once per
+// heartbeatInterval, so after a crash Started knows roughly when the bot stopped.
+func (h *History) Seen(t time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if t.After(h.seen) {
+		h.seen = t
+	}
+	if h.seen.Sub(h.savedSeen) < heartbeatInterval {
+		return nil
+	}
+	return h.save()
+}
+
+// Record appends a transition and persists the history.
+func (h *History) Record(t Transition) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.transitions = append(h.transitions, t)
+	if t.At.After(h.seen) {
+		h.seen = t.At
+	}
+
+	cutoff := t.At.Add(-historyRetention)
+	i := 0
+	// Последний переход до cutoff оставляем: он задаёт состояние на начало окна.
+	for i+1 < len(h.transitions) && h.transitions[i+1].At.Before(cutoff) {
+		i++
+	}
+	h.transitions = h.transitions[i:]
+
+	return h.save()
+}
+
+// Last returns the most recent transition, if any.
+func (h *History) Last() (Transition, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.transitions) == 0 {
+		return Transition{}, false
+	}
+	return h.transitions[len(h.transitions)-1], true
+}
+
+// Recent returns up to n most recent transitions, newest first.
+func (h *History) Recent(n int) []Transition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var out []Transition
+	for i := len(h.transitions) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, h.transitions[i])
+	}
+	return out
+}
+
+// Uptime returns the fraction of time (0..1) the server was online during the window
+// ending at now. Time before the first recorded transition and Unknown periods are not
+// counted; ok is false when nothing is known about the window.
+func (h *History) Uptime(window time.Duration, now time.Time) (ratio float64, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	start := now.Add(-window)
+	var online, total time.Duration
+
+	for i, t := range h.transitions {
+		end := now
+		if i+1 < len(h.transitions) {
+			end = h.transitions[i+1].At
+		}
+		if end.After(now) {
+			end = now
+		}
+		from := t.At
+		if from.Before(start) {
+			from = start
+		}
+		if t.Unknown || !end.After(from) {
+			continue
+		}
+		total += end.Sub(from)
+		if t.Online {
+			online += end.Sub(from)
+		}
+	}
+
+	if total == 0 {
+		return 0, false
+	}
+	return float64(online) / float64(total), true
+}
+
+func (h *History) save() error {
+	data, err := json.MarshalIndent(historyFile{Seen: h.seen, Transitions: h.transitions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling status history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(h.file), 0755); err != nil {
+		return fmt.Errorf("creating status history dir: %w", err)
+	}
+	if err := safefile.WriteFile(h.file, data, 0644); err != nil {
+		return fmt.Errorf("writing status history: %w", err)
+	}
+	h.savedSeen = h.seen
+	return nil
+}
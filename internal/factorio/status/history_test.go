@@ -0,0 +1,148 @@
+package status
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newHistory(t *testing.T, transitions ...Transition) *History {
+	t.Helper()
+	h, err := LoadHistory(filepath.Join(t.TempDir(), "bot", "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tr := range transitions {
+		if err := h.Record(tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+func TestHistoryUptime(t *testing.T) {
+	h := newHistory(t,
+		Transition{At: t0, Online: true},
+		Transition{At: t0.Add(6 * time.Hour), Online: false, Reason: "no handshake reply"},
+		Transition{At: t0.Add(8 * time.Hour), Online: true},
+	)
+	now := t0.Add(10 * time.Hour)
+
+	tests := []struct {
+		window time.Duration
+		want   float64
+		ok     bool
+	}{
+		{window: 10 * time.Hour, want: 0.8, ok: true},
+		{window: 4 * time.Hour, want: 0.5, ok: true},
+		{window: time.Hour, want: 1, ok: true},
+		// До первого перехода ничего не известно — окно обрезается.
+		{window: 24 * time.Hour, want: 0.8, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := h.Uptime(tt.window, now)
+		if ok != tt.ok || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Uptime(%s) = %v, %v; want %v, %v", tt.window, got, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := newHistory(t).Uptime(time.Hour, now); ok {
+		t.Error("empty history reported an uptime")
+	}
+	if _, ok := h.Uptime(time.Hour, t0); ok {
+		t.Error("window before the first transition reported an uptime")
+	}
+}
+
+func TestHistoryUptimeSkipsBotDowntime(t *testing.T) {
+	h := newHistory(t, Transition{At: t0, Online: true})
+	// Бот проработал час и упал; отметка о последней проверке успела сохраниться.
+	if err := h.Seen(t0.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted, err := LoadHistory(h.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Started(); err != nil {
+		t.Fatal(err)
+	}
+	// Через пять часов после падения бот поднялся и увидел лежащий сервер.
+	if err := restarted.Record(Transition{At: t0.Add(6 * time.Hour), Online: false}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := restarted.Uptime(8*time.Hour, t0.Add(8*time.Hour))
+	if !ok || math.Abs(got-1.0/3) > 1e-9 {
+		t.Errorf("Uptime = %v, %v; want 1/3: one hour online, two offline, five unknown", got, ok)
+	}
+	gap := restarted.Recent(2)[1]
+	if !gap.Unknown || !gap.At.Equal(t0.Add(time.Hour)) {
+		t.Errorf("gap = %+v, want unknown from the last seen check", gap)
+	}
+
+	// Повторный старт без единой проверки не добавляет второй разрыв.
+	if err := restarted.Started(); err != nil {
+		t.Fatal(err)
+	}
+	if err := restarted.Started(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(restarted.Recent(10)); n != 4 {
+		t.Errorf("%d transitions, want one gap per unwatched period", n)
+	}
+}
+
+func TestHistorySeenIsThrottled(t *testing.T) {
+	h := newHistory(t, Transition{At: t0, Online: true})
+	h.Seen(t0.Add(time.Minute))
+	h.Seen(t0.Add(heartbeatInterval))
+
+	reloaded, err := LoadHistory(h.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.seen.Equal(t0.Add(heartbeatInterval)) {
+		t.Errorf("seen on disk = %s, want the check after heartbeatInterval", reloaded.seen)
+	}
+
+	h.Seen(t0.Add(heartbeatInterval + time.Minute))
+	if reloaded, _ = LoadHistory(h.file); !reloaded.seen.Equal(t0.Add(heartbeatInterval)) {
+		t.Errorf("seen on disk = %s, want it not rewritten every check", reloaded.seen)
+	}
+}
+
+func TestLoadHistoryLegacyFormat(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history.json")
+	legacy := `[{"at":"2026-03-01T12:00:00Z","online":true},{"at":"2026-03-01T13:00:00Z","online":false,"reason":"no handshake reply"}]`
+	if err := os.WriteFile(file, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := LoadHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, ok := h.Last()
+	if !ok || last.Online || last.Reason != "no handshake reply" {
+		t.Fatalf("last = %+v, %v", last, ok)
+	}
+	// Без отметки о последней проверке разрыв начинается с последнего перехода.
+	if err := h.Started(); err != nil {
+		t.Fatal(err)
+	}
+	if gap, _ := h.Last(); !gap.Unknown || !gap.At.Equal(last.At) {
+		t.Errorf("gap = %+v", gap)
+	}
+
+	if err := os.WriteFile(file, []byte("{broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHistory(file); err == nil {
+		t.Error("broken history loaded")
+	}
+}
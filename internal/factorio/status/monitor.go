@@ -0,0 +1,140 @@
+package status
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const defaultInterval = time.Minute
+
+// Monitor periodically checks the server, records state transitions in a History
+// and notifies subscribers when the server goes down or comes back, and of every check.
+type Monitor struct {
+	checker   *Checker
+	history   *History
+	interval  time.Duration
+	threshold int // сколько одинаковых проверок подряд нужно, чтобы признать смену состояния
+	onChange  []func(Transition)
+	onCheck   []func(Result)
+
+	// Состояние цикла Run.
+	current *bool // подтверждённое состояние; nil — ещё не известно
+	streak  int   // сколько проверок подряд расходятся с current
+	started bool  // первая проверка после старта уже была
+}
+
+// NewMonitor creates a Monitor. A non-positive interval falls back to one minute,
+// threshold < 1 is treated as 1.
+func NewMonitor(checker *Checker, history *History, interval time.Duration, threshold int) *Monitor {
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Monitor{
+		checker:   checker,
+		history:   history,
+		interval:  interval,
+		threshold: threshold,
+	}
+}
+
+// OnChange registers fn to be called on every recorded transition. Not safe to call after Run.
+func (m *Monitor) OnChange(fn func(Transition)) {
+	m.onChange = append(m.onChange, fn)
+}
+
+// OnCheck registers fn to be called with the result of every check, from the Run
+// goroutine: fn must not block for long. Not safe to call after Run.
+func (m *Monitor) OnCheck(fn func(Result)) {
+	m.onCheck = append(m.onCheck, fn)
+}
+
+// Run probes the server every interval until ctx is cancelled.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.start()
+	for {
+		res := m.checker.Check(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		m.observe(res)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// start restores the confirmed state from the history and marks the time the bot
+// was not running as unknown.
+func (m *Monitor) start() {
+	m.current, m.streak, m.started = nil, 0, false
+	if last, ok := m.history.Last(); ok && !last.Unknown {
+		m.current = &last.Online
+	}
+	// Пока бот не работал, за сервером никто не следил — это время в аптайм не идёт.
+	if err := m.history.Started(); err != nil {
+		log.Printf("status: %v", err)
+	}
+}
+
+// observe handles the result of one check.
+func (m *Monitor) observe(res Result) {
+	for _, fn := range m.onCheck {
+		fn(res)
+	}
+
+	online := res.Online()
+	switch {
+	case !m.started:
+		// Первое наблюдение после старта закрывает неизвестный период и пишется
+		// сразу, без подтверждения. Оповещаем, только если состояние сменилось,
+		// пока бот не работал.
+		m.record(Transition{At: res.CheckedAt, Online: online, Reason: reasonOf(res)}, m.current == nil || online == *m.current)
+		m.current = &online
+		m.started = true
+	case online == *m.current:
+		m.streak = 0
+	default:
+		m.streak++
+		if m.streak >= m.threshold {
+			m.record(Transition{At: res.CheckedAt, Online: online, Reason: reasonOf(res)}, false)
+			m.current = &online
+			m.streak = 0
+		}
+	}
+	if err := m.history.Seen(res.CheckedAt); err != nil {
+		log.Printf("status: %v", err)
+	}
+}
+
+// record saves t and notifies subscribers unless quiet is set.
+func (m *Monitor) record(t Transition, quiet bool) {
+	if err := m.history.Record(t); err != nil {
+		log.Printf("status: %v", err)
+	}
+	log.Printf("status: сервер online=%v (%s)", t.Online, t.Reason)
+
+	// О начальном состоянии после старта бота не оповещаем — это не событие.
+	if quiet {
+		return
+	}
+	for _, fn := range m.onChange {
+		fn(t)
+	}
+}
+
+func reasonOf(res Result) string {
+	if res.Game.OK || res.Game.Err == nil {
+		return ""
+	}
+	return res.Game.Err.Error()
+}
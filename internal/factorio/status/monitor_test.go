@@ -0,0 +1,96 @@
+package status
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestMonitor returns a started Monitor over h and the transitions it announced.
+func newTestMonitor(t *testing.T, h *History, threshold int) (*Monitor, *[]Transition) {
+	t.Helper()
+	m := NewMonitor(nil, h, time.Minute, threshold)
+	var alerts []Transition
+	m.OnChange(func(tr Transition) { alerts = append(alerts, tr) })
+	m.start()
+	return m, &alerts
+}
+
+func check(at time.Time, online bool) Result {
+	res := Result{CheckedAt: at, Game: Probe{Checked: true, OK: online}}
+	if !online {
+		res.Game.Err = errors.New("no handshake reply")
+	}
+	return res
+}
+
+func TestMonitorThreshold(t *testing.T) {
+	h := newHistory(t)
+	m, alerts := newTestMonitor(t, h, 3)
+
+	at := t0
+	step := func(online bool) {
+		at = at.Add(time.Minute)
+		m.observe(check(at, online))
+	}
+
+	step(true) // начальное состояние записывается сразу и без оповещения
+	if last, ok := h.Last(); !ok || !last.Online || len(*alerts) != 0 {
+		t.Fatalf("after the first check: last %+v, alerts %+v", last, *alerts)
+	}
+
+	// Два провала подряд и успех — это флаппинг, а не падение.
+	step(false)
+	step(false)
+	step(true)
+	step(false)
+	step(false)
+	if len(*alerts) != 0 || len(h.Recent(10)) != 1 {
+		t.Fatalf("alerted on a flap: %+v", *alerts)
+	}
+
+	step(false)
+	if len(*alerts) != 1 || (*alerts)[0].Online || (*alerts)[0].Reason != "no handshake reply" {
+		t.Fatalf("alerts = %+v, want one down alert after three failed checks", *alerts)
+	}
+	if !(*alerts)[0].At.Equal(at) {
+		t.Errorf("down recorded at %s, want the confirming check %s", (*alerts)[0].At, at)
+	}
+
+	step(true)
+	step(true)
+	step(true)
+	if len(*alerts) != 2 || !(*alerts)[1].Online {
+		t.Errorf("alerts = %+v, want the server back", *alerts)
+	}
+}
+
+func TestMonitorRestart(t *testing.T) {
+	h := newHistory(t, Transition{At: t0, Online: true})
+
+	// Сервер в том же состоянии — записываем конец разрыва, но не оповещаем.
+	m, alerts := newTestMonitor(t, h, 3)
+	m.observe(check(t0.Add(time.Hour), true))
+	if recent := h.Recent(3); len(recent) != 3 || !recent[1].Unknown || !recent[0].Online || len(*alerts) != 0 {
+		t.Errorf("transitions %+v, alerts %+v", recent, *alerts)
+	}
+
+	// Пока бот не работал, сервер упал — оповещаем сразу, разрыв и так был.
+	m, alerts = newTestMonitor(t, h, 3)
+	m.observe(check(t0.Add(2*time.Hour), false))
+	if len(*alerts) != 1 || (*alerts)[0].Online {
+		t.Errorf("alerts = %+v, want the server reported down", *alerts)
+	}
+}
+
+func TestMonitorOnCheck(t *testing.T) {
+	m, _ := newTestMonitor(t, newHistory(t), 1)
+	var got []Result
+	m.OnCheck(func(res Result) { got = append(got, res) })
+
+	m.observe(check(t0, true))
+	m.observe(check(t0.Add(time.Minute), true))
+	if len(got) != 2 {
+		t.Errorf("OnCheck called %d times, want on every check", len(got))
+	}
+}
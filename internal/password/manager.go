@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
 )
 
 const charset = "abcdefghijklmnopqrstuvwxyz" +
@@ -36,7 +38,7 @@ func (m *Manager) Generate(length int) error {
 		return fmt.Errorf("creating rcon pw dir: %w", err)
 	}
 
-	if err := os.WriteFile(m.rconPwFile, []byte(pw), 0600); err != nil {
+	if err := safefile.WriteFile(m.rconPwFile, []byte(pw), 0600); err != nil {
 		return fmt.Errorf("writing rcon pw file: %w", err)
 	}
 
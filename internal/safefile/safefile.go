@@ -0,0 +1,200 @@
+// Package safefile writes files so that a crash never leaves a half-written file
+// behind: data goes to a temporary file next to the destination, is flushed to disk
+// and renamed into place. The previous version is kept as <name>.bak, and Recover
+// restores it when the main file turns out to be unreadable.
+package safefile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// BackupSuffix is appended to the name of the previous version of a file.
+const BackupSuffix = ".bak"
+
+// File is a temporary file that replaces its destination on Commit. The temporary
+// name starts with a dot and ends in .tmp-<random>, so directory listings that look
+// for .zip or .json files skip it.
+type File struct {
+	*os.File
+	dst  string
+	perm os.FileMode
+
+	// NoBackup skips keeping the previous version as .bak, for large files that are
+	// versioned elsewhere (saves have snapshots).
+	NoBackup bool
+
+	committed bool
+}
+
+// Create starts writing a new version of dst. Call Commit to replace dst, and defer
+// Abort to clean up on failure.
+func Create(dst string, perm os.FileMode) (*File, error) {
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &File{File: f, dst: dst, perm: perm}, nil
+}
+
+// Commit flushes the file to disk, keeps the current dst as .bak and atomically
+// replaces dst.
+func (f *File) Commit() error {
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), f.perm); err != nil {
+		return err
+	}
+	if !f.NoBackup {
+		if err := keepBackup(f.dst); err != nil {
+			return fmt.Errorf("keeping %s%s: %w", filepath.Base(f.dst), BackupSuffix, err)
+		}
+	}
+	if err := os.Rename(f.Name(), f.dst); err != nil {
+		return err
+	}
+	f.committed = true
+	syncDir(filepath.Dir(f.dst))
+	return nil
+}
+
+// Abort discards the file unless it was committed. Safe to defer unconditionally.
+func (f *File) Abort() {
+	if f.committed {
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// WriteFile is the safe counterpart of os.WriteFile. The directory must exist.
+func WriteFile(name string, data []byte, perm os.FileMode) error {
+	f, err := Create(name, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// Recover checks name with valid and, if it is missing or invalid while the .bak
+// version passes, puts the .bak version back. It reports whether the file was
+// restored. A missing file without a backup is not an error.
+func Recover(name string, valid func([]byte) error) (bool, error) {
+	data, err := os.ReadFile(name)
+	if err == nil {
+		if err = valid(data); err == nil {
+			return false, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	mainErr := err
+
+	backup, bakErr := os.ReadFile(name + BackupSuffix)
+	if bakErr == nil {
+		bakErr = valid(backup)
+	}
+	if bakErr != nil {
+		if errors.Is(mainErr, os.ErrNotExist) && errors.Is(bakErr, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("%s: %w (no usable backup: %v)", filepath.Base(name), mainErr, bakErr)
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(name + BackupSuffix); err == nil {
+		perm = info.Mode().Perm()
+	}
+	// Испорченный файл не затираем молча — он остаётся рядом для разбора.
+	if !errors.Is(mainErr, os.ErrNotExist) {
+		if err := os.Rename(name, name+".broken"); err != nil {
+			return false, err
+		}
+	}
+	f, err := Create(name, perm)
+	if err != nil {
+		return false, err
+	}
+	defer f.Abort()
+	f.NoBackup = true
+	if _, err := f.Write(backup); err != nil {
+		return false, err
+	}
+	if err := f.Commit(); err != nil {
+		return false, err
+	}
+	log.Printf("safefile: %s восстановлен из %s (%v)", name, filepath.Base(name)+BackupSuffix, mainErr)
+	return true, nil
+}
+
+// ValidJSON is a Recover check for JSON files.
+func ValidJSON(data []byte) error {
+	var v any
+	return json.Unmarshal(data, &v)
+}
+
+// keepBackup replaces name.bak with the current name. A hard link costs nothing and
+// keeps the old content once name is renamed over; where links are not supported the
+// file is copied.
+func keepBackup(name string) error {
+	bak := name + BackupSuffix
+	if _, err := os.Lstat(name); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err := os.Remove(bak); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(name, bak); err == nil {
+		return nil
+	}
+	return copyFile(name, bak)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir flushes a rename to disk. Best effort: not every filesystem supports it.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync() //nolint:errcheck
+	d.Close()
+}
@@ -0,0 +1,214 @@
+package safefile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// leftovers lists temporary files in dir.
+func leftovers(t *testing.T, dir string) []string {
+	t.Helper()
+	temps, err := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return temps
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriteFileRotatesBackup(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "state.json")
+
+	if err := WriteFile(name, []byte(`{"v":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name + BackupSuffix); !os.IsNotExist(err) {
+		t.Errorf("first write left a backup: %v", err)
+	}
+	if err := WriteFile(name, []byte(`{"v":2}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(name, []byte(`{"v":3}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readFile(t, name); got != `{"v":3}` {
+		t.Errorf("file = %s", got)
+	}
+	if got := readFile(t, name+BackupSuffix); got != `{"v":2}` {
+		t.Errorf("backup = %s, want the previous version", got)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, %v; want 0600", info.Mode(), err)
+	}
+	if temps := leftovers(t, dir); len(temps) > 0 {
+		t.Errorf("temp files left: %q", temps)
+	}
+}
+
+func TestCommitNoBackup(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "save.zip")
+	if err := WriteFile(name, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Create(name, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Abort()
+	f.NoBackup = true
+	f.WriteString("new")
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, name); got != "new" {
+		t.Errorf("file = %s", got)
+	}
+	if _, err := os.Stat(name + BackupSuffix); !os.IsNotExist(err) {
+		t.Errorf("NoBackup kept a backup: %v", err)
+	}
+}
+
+func TestAbortRemovesTemp(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "state.json")
+	if err := WriteFile(name, []byte("kept"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Create(name, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("half-written")
+	if !strings.HasPrefix(filepath.Base(f.Name()), ".state.json.tmp-") {
+		t.Errorf("temp name %s is not hidden from *.json listings", f.Name())
+	}
+	f.Abort()
+	f.Abort() // повторный вызов безопасен
+
+	if got := readFile(t, name); got != "kept" {
+		t.Errorf("file = %s, want it untouched", got)
+	}
+	if temps := leftovers(t, dir); len(temps) > 0 {
+		t.Errorf("temp files left: %q", temps)
+	}
+}
+
+func TestWriteFileCleansUpOnError(t *testing.T) {
+	dir := t.TempDir()
+	// На месте файла каталог: ни .bak, ни rename не выйдут.
+	name := filepath.Join(dir, "state.json")
+	if err := os.Mkdir(name, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(name, []byte("{}"), 0644); err == nil {
+		t.Fatal("WriteFile over a directory succeeded")
+	}
+	if temps := leftovers(t, dir); len(temps) > 0 {
+		t.Errorf("temp files left: %q", temps)
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing", "state.json"), []byte("{}"), 0644); err == nil {
+		t.Error("WriteFile into a missing directory succeeded")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	tests := []struct {
+		name     string
+		main     string // пусто — файла нет
+		backup   string
+		restored bool
+		err      bool
+		want     string // содержимое файла после Recover
+	}{
+		{name: "valid", main: `{"v":2}`, backup: `{"v":1}`, want: `{"v":2}`},
+		{name: "corrupt main", main: `{"v":`, backup: `{"v":1}`, restored: true, want: `{"v":1}`},
+		{name: "missing main", backup: `{"v":1}`, restored: true, want: `{"v":1}`},
+		{name: "nothing at all"},
+		{name: "corrupt main, no backup", main: `{"v":`, err: true, want: `{"v":`},
+		{name: "both corrupt", main: `{"v":`, backup: `[`, err: true, want: `{"v":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			name := filepath.Join(dir, "state.json")
+			if tt.main != "" {
+				os.WriteFile(name, []byte(tt.main), 0644)
+			}
+			if tt.backup != "" {
+				os.WriteFile(name+BackupSuffix, []byte(tt.backup), 0600)
+			}
+
+			restored, err := Recover(name, ValidJSON)
+			if restored != tt.restored || (err != nil) != tt.err {
+				t.Fatalf("Recover = %v, %v; want restored %v, error %v", restored, err, tt.restored, tt.err)
+			}
+			data, readErr := os.ReadFile(name)
+			if tt.want == "" {
+				if !errors.Is(readErr, os.ErrNotExist) {
+					t.Errorf("file appeared: %q, %v", data, readErr)
+				}
+				return
+			}
+			if string(data) != tt.want {
+				t.Errorf("file = %q, want %q", data, tt.want)
+			}
+			if !restored {
+				return
+			}
+			// Испорченный файл сохраняется рядом, .bak остаётся на месте.
+			if tt.main != "" && readFile(t, name+".broken") != tt.main {
+				t.Error("corrupt file was not kept as .broken")
+			}
+			if readFile(t, name+BackupSuffix) != tt.backup {
+				t.Error("backup changed")
+			}
+			if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("restored file: %v, %v; want the backup's mode 0600", info, err)
+			}
+		})
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "state.json")
+	if err := os.WriteFile(src, []byte("data"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyFile(src, src+BackupSuffix); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(src + BackupSuffix)
+	if err != nil || info.Mode().Perm() != 0640 || readFile(t, src+BackupSuffix) != "data" {
+		t.Errorf("copy = %v, %v", info, err)
+	}
+	if os.SameFile(info, mustStat(t, src)) {
+		t.Error("copy is a link to the source")
+	}
+}
+
+func mustStat(t *testing.T, name string) os.FileInfo {
+	t.Helper()
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
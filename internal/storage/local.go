@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+// Local implements domain.BackupStore on a local directory.
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+// Put writes r through safefile: a temporary file next to the target, flushed to
+// disk and renamed into place, so a crash never leaves a truncated backup.
+func (l *Local) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	dest, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("local store: %w", err)
+	}
+
+	f, err := safefile.Create(dest, 0644)
+	if err != nil {
+		return fmt.Errorf("local store: %w", err)
+	}
+	defer f.Abort()
+	// Бэкапы версионируются ключами, .bak рядом не нужен.
+	f.NoBackup = true
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("local store: writing %s: %w", key, err)
+	}
+	if err := f.Commit(); err != nil {
+		return fmt.Errorf("local store: writing %s: %w", key, err)
+	}
+	return nil
+}
+
+func (l *Local) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (l *Local) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(l.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == l.dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || isTempFile(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("local store: %w", err)
+	}
+	return keys, nil
+}
+
+func (l *Local) Delete(_ context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("local store: %w", err)
+	}
+	return nil
+}
+
+// isTempFile reports whether name is an unfinished Put: a safefile temporary or a
+// ".put-" file left by older versions.
+func isTempFile(name string) bool {
+	return strings.HasPrefix(name, ".put-") || (strings.HasPrefix(name, ".") && strings.Contains(name, ".tmp-"))
+}
+
+// path maps a key to a file path, rejecting keys that would escape the store root.
+func (l *Local) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// failingReader returns some data and then err.
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "backups")
+	l := NewLocal(dir)
+
+	if keys, err := l.List(ctx, ""); err != nil || len(keys) != 0 {
+		t.Fatalf("List of a missing dir = %q, %v", keys, err)
+	}
+	for _, key := range []string{"factorio/a.zip", "factorio/b.zip", "other/c.zip"} {
+		if err := l.Put(ctx, key, strings.NewReader("data of "+key), -1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Put(ctx, "factorio/a.zip", strings.NewReader("second"), -1); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := l.List(ctx, "factorio/")
+	if err != nil || strings.Join(keys, ",") != "factorio/a.zip,factorio/b.zip" {
+		t.Errorf("List = %q, %v", keys, err)
+	}
+	rc, err := l.Get(ctx, "factorio/a.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "second" {
+		t.Errorf("Get = %q, want the overwritten content", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "factorio", "a.zip.bak")); !os.IsNotExist(err) {
+		t.Errorf("Put kept a .bak: %v", err)
+	}
+
+	if err := l.Delete(ctx, "factorio/a.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(ctx, "factorio/a.zip"); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+	if _, err := l.Get(ctx, "factorio/a.zip"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Get after Delete: %v", err)
+	}
+}
+
+func TestLocalPutFailureLeavesNothing(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	l := NewLocal(dir)
+	if err := l.Put(ctx, "a.zip", strings.NewReader("intact"), -1); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := errors.New("connection reset")
+	err := l.Put(ctx, "a.zip", &failingReader{data: "partial", err: broken}, -1)
+	if !errors.Is(err, broken) {
+		t.Fatalf("Put = %v, want the reader error", err)
+	}
+	if err := l.Put(ctx, "b.zip", &failingReader{data: "partial", err: broken}, -1); err == nil {
+		t.Fatal("Put of a failing reader succeeded")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "a.zip" {
+		t.Errorf("dir holds %v, want only a.zip", entries)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.zip")); string(data) != "intact" {
+		t.Errorf("a.zip = %q, want the previous upload", data)
+	}
+}
+
+func TestLocalListSkipsUnfinishedPuts(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{".a.zip.tmp-123", ".put-456", "a.zip"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	keys, err := NewLocal(dir).List(context.Background(), "")
+	if err != nil || strings.Join(keys, ",") != "a.zip" {
+		t.Errorf("List = %q, %v", keys, err)
+	}
+}
+
+func TestLocalRejectsEscapingKeys(t *testing.T) {
+	l := NewLocal(t.TempDir())
+	for _, key := range []string{"", "/etc/passwd", "../x.zip", "a/../../x.zip", "a//b.zip"} {
+		if err := l.Put(context.Background(), key, strings.NewReader("x"), -1); err == nil {
+			t.Errorf("Put(%q) accepted", key)
+		}
+	}
+}
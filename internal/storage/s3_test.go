@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// Пример вывода ключа из документации AWS SigV4.
+func TestSigningKey(t *testing.T) {
+	got := hex.EncodeToString(signingKey(testSecretKey, "20120215", "us-east-1", "iam"))
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got != want {
+		t.Errorf("signing key = %s, want %s", got, want)
+	}
+}
+
+// Проверяем сам проверяющий код сервера-заглушки на векторе get-vanilla из набора AWS.
+func TestExpectedSignatureVector(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "http://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.RequestURI = "/"
+	req.Header.Set("X-Amz-Date", "20150830T123600Z")
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31")
+
+	if err := checkSignature(req, testSecretKey); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3SignedRequests(t *testing.T) {
+	srv := newFakeS3(t, "backups", testSecretKey)
+	s, err := NewS3(S3Config{
+		Endpoint:  srv.URL,
+		Region:    "eu-central-1",
+		Bucket:    "backups",
+		Prefix:    "factorio",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Пробелы, плюс, тильда и не-ASCII проверяют экранирование пути.
+	keys := []string{"snapshots/a b+c~ü.zip", "snapshots/meta.json", "other/x.zip"}
+	for _, key := range keys {
+		if err := s.Put(ctx, key, strings.NewReader(key), int64(len(key))); err != nil {
+			t.Fatalf("put %s: %v", key, err)
+		}
+	}
+
+	rc, err := s.Get(ctx, keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != keys[0] {
+		t.Errorf("get = %q, want %q", data, keys[0])
+	}
+
+	// Заглушка отдаёт по одному ключу на страницу — заодно проверяется continuation-token.
+	listed, err := s.List(ctx, "snapshots/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(listed)
+	if strings.Join(listed, ",") != "snapshots/a b+c~ü.zip,snapshots/meta.json" {
+		t.Errorf("list = %v", listed)
+	}
+
+	if err := s.Delete(ctx, keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, keys[0]); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("get after delete: %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestS3WrongSecretRejected(t *testing.T) {
+	srv := newFakeS3(t, "backups", testSecretKey)
+	s, err := NewS3(S3Config{
+		Endpoint:  srv.URL,
+		Bucket:    "backups",
+		AccessKey: testAccessKey,
+		SecretKey: "wrong",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.List(context.Background(), "")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("list with a wrong secret: %v, want SignatureDoesNotMatch", err)
+	}
+}
+
+// newFakeS3 starts a path-style S3 stand-in that rejects requests whose SigV4
+// signature does not match the one it computes itself.
+func newFakeS3(t *testing.T, bucket, secret string) *httptest.Server {
+	var (
+		mu      sync.Mutex
+		objects = make(map[string][]byte)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := checkSignature(r, secret); err != nil {
+			t.Logf("fake s3: %s %s: %v", r.Method, r.RequestURI, err)
+			s3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+			return
+		}
+		key, ok := strings.CutPrefix(r.URL.Path, "/"+bucket)
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchBucket")
+			return
+		}
+		key = strings.TrimPrefix(key, "/")
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[key] = data
+		case r.Method == http.MethodGet && key == "":
+			listObjects(w, r, objects)
+		case r.Method == http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				s3Error(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			w.Write(data)
+		case r.Method == http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			s3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// listObjects answers ListObjectsV2 one key per page.
+func listObjects(w http.ResponseWriter, r *http.Request, objects map[string][]byte) {
+	q := r.URL.Query()
+	var keys []string
+	for k := range objects {
+		if strings.HasPrefix(k, q.Get("prefix")) && k > q.Get("continuation-token") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key string `xml:"Key"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}{}
+	if len(keys) > 0 {
+		result.Contents = []content{{Key: keys[0]}}
+		if len(keys) > 1 {
+			result.IsTruncated = true
+			result.NextContinuationToken = keys[0]
+		}
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func s3Error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string   `xml:"Code"`
+	}{Code: code})
+}
+
+// checkSignature recomputes the SigV4 signature of r the way a server does: from the
+// raw request URI, the re-encoded query and the headers named in SignedHeaders.
+func checkSignature(r *http.Request, secret string) error {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return errors.New("no SigV4 authorization")
+	}
+	fields := make(map[string]string)
+	for _, part := range strings.Split(auth, ", ") {
+		k, v, _ := strings.Cut(part, "=")
+		fields[k] = v
+	}
+	scope := strings.SplitN(fields["Credential"], "/", 5)
+	if len(scope) != 5 || scope[0] != testAccessKey {
+		return errors.New("bad credential " + fields["Credential"])
+	}
+
+	path, _, _ := strings.Cut(r.RequestURI, "?")
+	query := strings.ReplaceAll(r.URL.Query().Encode(), "+", "%20")
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	var headers strings.Builder
+	for _, h := range signed {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+	if payload == "" {
+		payload = sha256Hex("")
+	}
+	canonical := strings.Join([]string{r.Method, path, query, headers.String(), fields["SignedHeaders"], payload}, "\n")
+	stringToSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" +
+		strings.Join(scope[1:], "/") + "\n" + sha256Hex(canonical)
+
+	key := signingKey(secret, scope[1], scope[2], scope[3])
+	want := hmacSHA256(key, stringToSign)
+	got, err := hex.DecodeString(fields["Signature"])
+	if err != nil || !hmac.Equal(got, want) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
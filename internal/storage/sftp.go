@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SFTPConfig configures an SFTP backup target.
+type SFTPConfig struct {
+	Addr           string // host:port
+	User           string
+	Password       string // либо пароль, либо ключ
+	KeyFile        string
+	KnownHostsFile string // обязателен: ключ хоста проверяется всегда
+	Dir            string // корневая папка на удалённом сервере
+}
+
+// SFTP implements domain.BackupStore over SFTP. The SSH connection is opened lazily
+// and re-established if it breaks.
+type SFTP struct {
+	cfg     SFTPConfig
+	sshConf *ssh.ClientConfig
+
+	mu     sync.Mutex
+	ssh    *ssh.Client
+	client *sftp.Client
+}
+
+func NewSFTP(cfg SFTPConfig) (*SFTP, error) {
+	if cfg.Addr == "" || cfg.User == "" {
+		return nil, fmt.Errorf("sftp: address and user are required")
+	}
+	if cfg.KnownHostsFile == "" {
+		return nil, fmt.Errorf("sftp: known_hosts file is required to verify the server key")
+	}
+	hostKeys, err := knownhosts.New(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: reading known_hosts: %w", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if cfg.KeyFile != "" {
+		pem, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: reading key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: parsing key: %w", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if cfg.Password != "" {
+		auth = append(auth, ssh.Password(cfg.Password))
+	}
+	if len(auth) == 0 {
+		return nil, fmt.Errorf("sftp: password or key file is required")
+	}
+
+	if cfg.Dir == "" {
+		cfg.Dir = "."
+	}
+	return &SFTP{
+		cfg: cfg,
+		sshConf: &ssh.ClientConfig{
+			User:            cfg.User,
+			Auth:            auth,
+			HostKeyCallback: hostKeys,
+			Timeout:         15 * time.Second,
+		},
+	}, nil
+}
+
+func (s *SFTP) Put(ctx context.Context, key string, r io.Reader, _ int64) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	c, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.watch(ctx, c)()
+
+	dest := s.remotePath(key)
+	if err := c.MkdirAll(path.Dir(dest)); err != nil {
+		return s.fail(fmt.Errorf("sftp: mkdir: %w", cause(ctx, err)))
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы обрыв не оставил половину бэкапа.
+	tmp := dest + ".part"
+	f, err := c.Create(tmp)
+	if err != nil {
+		return s.fail(fmt.Errorf("sftp: create %s: %w", key, cause(ctx, err)))
+	}
+	if _, err := io.Copy(f, contextReader{ctx, r}); err != nil {
+		f.Close()
+		c.Remove(tmp) //nolint:errcheck
+		return s.fail(fmt.Errorf("sftp: writing %s: %w", key, cause(ctx, err)))
+	}
+	if err := f.Close(); err != nil {
+		c.Remove(tmp) //nolint:errcheck
+		return s.fail(fmt.Errorf("sftp: writing %s: %w", key, cause(ctx, err)))
+	}
+	if err := c.PosixRename(tmp, dest); err != nil {
+		c.Remove(tmp) //nolint:errcheck
+		return s.fail(fmt.Errorf("sftp: rename %s: %w", key, cause(ctx, err)))
+	}
+	return nil
+}
+
+// Get opens key for reading. Cancelling ctx while the body is being read closes the
+// session, so the reader fails instead of waiting for a server that stopped answering.
+func (s *SFTP) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+	c, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stop := s.watch(ctx, c)
+	f, err := c.Open(s.remotePath(key))
+	if err != nil {
+		stop()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("sftp: %s: %w", key, fs.ErrNotExist)
+		}
+		return nil, s.fail(fmt.Errorf("sftp: open %s: %w", key, cause(ctx, err)))
+	}
+	return &sftpFile{f: f, ctx: ctx, stop: stop}, nil
+}
+
+func (s *SFTP) List(ctx context.Context, prefix string) ([]string, error) {
+	c, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer s.watch(ctx, c)()
+
+	root := path.Clean(s.cfg.Dir)
+	var keys []string
+	walker := c.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if errors.Is(err, fs.ErrNotExist) && walker.Path() == root {
+				return nil, nil
+			}
+			return nil, s.fail(fmt.Errorf("sftp: list: %w", cause(ctx, err)))
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("sftp: list: %w", err)
+		}
+		if walker.Stat().IsDir() || strings.HasSuffix(walker.Path(), ".part") {
+			continue
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), root), "/")
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *SFTP) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+	c, err := s.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer s.watch(ctx, c)()
+	if err := c.Remove(s.remotePath(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return s.fail(fmt.Errorf("sftp: delete %s: %w", key, cause(ctx, err)))
+	}
+	return nil
+}
+
+// conn returns the current SFTP client, dialing a new SSH connection if needed. The
+// dial and both handshakes are bounded by the config timeout and by ctx.
+func (s *SFTP) conn(ctx context.Context) (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("sftp: connect %s: %w", s.cfg.Addr, err)
+	}
+
+	dialer := net.Dialer{Timeout: s.sshConf.Timeout}
+	raw, err := dialer.DialContext(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: connect %s: %w", s.cfg.Addr, err)
+	}
+	// ssh.Dial ограничивает таймаутом только TCP-соединение: сервер, который принял
+	// его и молчит, держал бы рукопожатие вечно. Отмена ctx закрывает сокет сразу.
+	raw.SetDeadline(time.Now().Add(s.sshConf.Timeout)) //nolint:errcheck
+	stop := context.AfterFunc(ctx, func() { raw.Close() })
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(raw, s.cfg.Addr, s.sshConf)
+	if err != nil {
+		stop()
+		raw.Close()
+		return nil, fmt.Errorf("sftp: connect %s: %w", s.cfg.Addr, cause(ctx, err))
+	}
+	sshClient := ssh.NewClient(sshConn, chans, reqs)
+	client, err := sftp.NewClient(sshClient)
+	if err != nil {
+		stop()
+		sshClient.Close()
+		return nil, fmt.Errorf("sftp: starting subsystem: %w", cause(ctx, err))
+	}
+	if !stop() {
+		client.Close()
+		sshClient.Close()
+		return nil, fmt.Errorf("sftp: connect %s: %w", s.cfg.Addr, ctx.Err())
+	}
+	raw.SetDeadline(time.Time{}) //nolint:errcheck
+	s.ssh, s.client = sshClient, client
+	return client, nil
+}
+
+// watch closes the session of c once ctx is cancelled, so a call blocked on a server
+// that stopped answering returns. The returned func stops watching.
+func (s *SFTP) watch(ctx context.Context, c *sftp.Client) (stop func() bool) {
+	return context.AfterFunc(ctx, func() { s.drop(c) })
+}
+
+// drop closes c if it is still the current client; a newer connection is left alone.
+func (s *SFTP) drop(c *sftp.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == c {
+		s.client.Close()
+		s.ssh.Close()
+		s.client, s.ssh = nil, nil
+	}
+}
+
+// fail drops the connection so the next call reconnects, and returns err unchanged.
+func (s *SFTP) fail(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil {
+		s.client.Close()
+		s.ssh.Close()
+	}
+	s.client, s.ssh = nil, nil
+	return err
+}
+
+func (s *SFTP) remotePath(key string) string {
+	return path.Join(s.cfg.Dir, key)
+}
+
+// cause returns ctx's error once it is done: the session was closed because of it,
+// and err only says the connection was lost.
+func cause(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// sftpFile is a remote file returned by Get; it stops watching ctx when closed.
+type sftpFile struct {
+	f    *sftp.File
+	ctx  context.Context
+	stop func() bool
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	n, err := f.f.Read(p)
+	if err != nil && err != io.EOF {
+		err = cause(f.ctx, err)
+	}
+	return n, err
+}
+
+func (f *sftpFile) Close() error {
+	f.stop()
+	return f.f.Close()
+}
+
+// contextReader aborts a copy once ctx is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// silentServer accepts TCP connections and never answers, like an SSH server that
+// hung after the kernel completed the handshake.
+func silentServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func newTestSFTP(t *testing.T, addr string) *SFTP {
+	t.Helper()
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(knownHosts, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSFTP(SFTPConfig{Addr: addr, User: "backup", Password: "secret", KnownHostsFile: knownHosts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSFTPHonorsContext(t *testing.T) {
+	s := newTestSFTP(t, silentServer(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := s.Get(ctx, "backups/x.zip")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Get: %v, want the ctx deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Get returned after %s, want it bounded by ctx", elapsed)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := s.List(ctx, "backups/"); !errors.Is(err, context.Canceled) {
+		t.Errorf("List: %v, want context.Canceled", err)
+	}
+
+	if _, err := s.List(ctx, "backups/"); !errors.Is(err, context.Canceled) {
+		t.Errorf("List with a cancelled ctx: %v", err)
+	}
+}
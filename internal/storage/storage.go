@@ -0,0 +1,38 @@
+// Package storage provides domain.BackupStore implementations: a local directory,
+// an S3-compatible object store and an SFTP server.
+package storage
+
+import (
+	"fmt"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+// Backend names accepted by New.
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+	BackendSFTP  = "sftp"
+)
+
+// Options holds the settings of every backend; only the selected one is used.
+type Options struct {
+	Backend  string
+	LocalDir string
+	S3       S3Config
+	SFTP     SFTPConfig
+}
+
+// New builds the store selected by opts.Backend.
+func New(opts Options) (domain.BackupStore, error) {
+	switch opts.Backend {
+	case "", BackendLocal:
+		return NewLocal(opts.LocalDir), nil
+	case BackendS3:
+		return NewS3(opts.S3)
+	case BackendSFTP:
+		return NewSFTP(opts.SFTP)
+	default:
+		return nil, fmt.Errorf("unknown backup store %q (want local, s3 or sftp)", opts.Backend)
+	}
+}
@@ -1,15 +1,22 @@
 package telegram
 
 import (
+	"context"
+	"io"
 	"log"
+	"sync"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"perezvonish/factorio-server-manager/internal/domain"
+	"perezvonish/factorio-server-manager/internal/factorio/backup"
+	"perezvonish/factorio-server-manager/internal/factorio/mapgen"
 	"perezvonish/factorio-server-manager/internal/factorio/mods"
 	"perezvonish/factorio-server-manager/internal/factorio/saves"
+	"perezvonish/factorio-server-manager/internal/factorio/settings"
 	"perezvonish/factorio-server-manager/internal/factorio/status"
 	"perezvonish/factorio-server-manager/internal/password"
+	"perezvonish/factorio-server-manager/internal/webapp"
 )
 
 // Bot is the Telegram bot that manages the Factorio server
@@ -20,9 +27,26 @@ type Bot struct {
 	container    domain.ContainerManager
 	saves        *saves.Manager
 	status       *status.Checker
+	history      *status.History
+	backups      *backup.Scheduler
 	passwords    *password.Manager
 	mods         *mods.Manager
 	webAppURL    string // публичный HTTPS-адрес WebApp для загрузки сейвов
+	downloads    *webapp.DownloadLinks
+	mapgen       *mapgen.Generator
+	settings     *settings.Manager
+
+	// modsUpdateOnlyWhenEmpty откладывает /mods update, пока на сервере есть игроки.
+	modsUpdateOnlyWhenEmpty bool
+
+	queuedMu     sync.Mutex
+	queuedUpdate *queuedModsUpdate // ждёт, пока сервер опустеет
+
+	editsMu sync.Mutex
+	edits   map[string]settingsEdit // неподтверждённые /set по id кнопки
+
+	tasksMu sync.Mutex
+	tasks   map[string]context.CancelFunc // выполняющиеся долгие операции по ключу
 }
 
 // Config holds all dependencies needed to build a Bot
@@ -33,9 +57,18 @@ type Config struct {
 	Container    domain.ContainerManager
 	Saves        *saves.Manager
 	Status       *status.Checker
+	History      *status.History
+	Backups      *backup.Scheduler
 	PasswordMgr  *password.Manager
 	Mods         *mods.Manager
 	WebAppURL    string
+	Downloads    *webapp.DownloadLinks
+	MapGen       *mapgen.Generator
+	Settings     *settings.Manager
+
+	// ModsUpdateOnlyWhenEmpty makes /mods update wait until no players are online;
+	// the queued update is started from OnStatusCheck.
+	ModsUpdateOnlyWhenEmpty bool
 }
 
 func NewBot(cfg Config) (*Bot, error) {
@@ -51,9 +84,19 @@ func NewBot(cfg Config) (*Bot, error) {
 		container:    cfg.Container,
 		saves:        cfg.Saves,
 		status:       cfg.Status,
+		history:      cfg.History,
+		backups:      cfg.Backups,
 		passwords:    cfg.PasswordMgr,
 		mods:         cfg.Mods,
 		webAppURL:    cfg.WebAppURL,
+		downloads:    cfg.Downloads,
+		mapgen:       cfg.MapGen,
+		settings:     cfg.Settings,
+
+		modsUpdateOnlyWhenEmpty: cfg.ModsUpdateOnlyWhenEmpty,
+
+		edits: make(map[string]settingsEdit),
+		tasks: make(map[string]context.CancelFunc),
 	}, nil
 }
 
@@ -85,8 +128,16 @@ func (b *Bot) reply(chatID int64, text string, parseMode ...string) {
 	}
 }
 
-func (b *Bot) replyDocument(chatID int64, name string, data []byte) {
-	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+// broadcast sends text to every allowed user (their private chat ID equals the user ID).
+func (b *Bot) broadcast(text string) {
+	for userID := range b.allowedUsers {
+		b.reply(userID, text)
+	}
+}
+
+// replyDocument uploads r as a file; tgbotapi streams it into the multipart request.
+func (b *Bot) replyDocument(chatID int64, name string, r io.Reader) {
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileReader{Name: name, Reader: r})
 	if _, err := b.api.Send(doc); err != nil {
 		log.Printf("replyDocument error: %v", err)
 	}
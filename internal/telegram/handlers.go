@@ -2,16 +2,36 @@ package telegram
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"perezvonish/factorio-server-manager/internal/docker"
+	"perezvonish/factorio-server-manager/internal/domain"
+	"perezvonish/factorio-server-manager/internal/factorio/mapgen"
+	"perezvonish/factorio-server-manager/internal/factorio/mods"
+	"perezvonish/factorio-server-manager/internal/factorio/rcon"
+	"perezvonish/factorio-server-manager/internal/factorio/saves"
+	"perezvonish/factorio-server-manager/internal/factorio/settings"
+	"perezvonish/factorio-server-manager/internal/factorio/status"
 )
 
 func (b *Bot) handleUpdate(update tgbotapi.Update) {
+	if update.CallbackQuery != nil {
+		b.handleCallback(update.CallbackQuery)
+		return
+	}
 	if update.Message == nil {
 		return
 	}
@@ -25,7 +45,7 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 	}
 
 	if update.Message.Document != nil {
-		b.handleUploadSave(chatID, update.Message.Document)
+		b.handleUploadSave(chatID, update.Message.From, update.Message.Document)
 		return
 	}
 
@@ -61,14 +81,15 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 	case "evolution":
 		b.handleEvolution(chatID)
 
+	// Долгие операции идут в фоне, чтобы бот не замолкал на время их выполнения.
 	case "restart":
-		b.handleRestart(chatID)
+		b.runTask(chatID, taskServer, func(ctx context.Context) { b.handleRestart(ctx, chatID) })
 
 	case "stop":
-		b.handleStopServer(chatID)
+		b.runTask(chatID, taskServer, func(ctx context.Context) { b.handleStopServer(ctx, chatID) })
 
 	case "startServer":
-		b.handleStartServer(chatID)
+		b.runTask(chatID, taskServer, func(ctx context.Context) { b.handleStartServer(ctx, chatID) })
 
 	case "getPassword":
 		b.handleGetPassword(chatID)
@@ -78,6 +99,47 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 
 	case "downloadSave":
 		b.handleDownloadSave(chatID)
+
+	case "saves":
+		b.handleSaves(chatID)
+
+	case "useSave":
+		b.handleUseSave(chatID, update.Message.From, args)
+
+	case "newMap":
+		b.handleNewMap(chatID, update.Message.From, args)
+
+	case "mapgen":
+		b.handleMapgen(chatID, update.Message.From, args)
+
+	case "settings":
+		b.handleSettings(chatID)
+
+	case "set":
+		b.handleSet(chatID, args)
+
+	case "restore":
+		b.handleRestore(chatID, update.Message.From, args)
+
+	case "backup":
+		from := update.Message.From
+		b.runTask(chatID, taskBackup, func(ctx context.Context) { b.handleBackup(ctx, chatID, from) })
+
+	case "backups":
+		b.handleBackups(chatID)
+
+	case "restoreBackup":
+		from := update.Message.From
+		b.runTask(chatID, taskBackup, func(ctx context.Context) { b.handleRestoreBackup(ctx, chatID, from, args) })
+
+	case "logs":
+		b.handleLogs(chatID, args)
+
+	case "mods":
+		b.handleMods(chatID, update.Message.From, args)
+
+	case "uptime":
+		b.handleUptime(chatID)
 	}
 }
 
@@ -87,6 +149,7 @@ func (b *Bot) handleHelp(chatID int64) {
 	b.reply(chatID, `🏭 Factorio Bot
 
 /status — статус сервера
+/uptime — аптайм и история падений
 /players — игроки онлайн
 /cmd <команда> — RCON команда
 /msg <текст> — сообщение в чат игры
@@ -94,27 +157,193 @@ func (b *Bot) handleHelp(chatID int64) {
 /time — время в игре
 /evolution — уровень эволюции
 /restart — остановить, обновить моды, запустить
+/logs [n] [error|warning] — последние строки лога сервера
+/logs follow | stop — следить за логом в одном сообщении
+/settings — настройки сервера
+/set <ключ> <значение> — изменить настройку сервера
+/mods [outdated|update <мод|all>] — моды и их обновление
 
 /stop — полностью остановить контейнер
 /startServer — запустить контейнер (с обновлением модов)
 
 /getPassword — пароль RCON подключения
 /downloadSave — скачать текущее сохранение
+/saves — сохранения и снапшоты
+/useSave <имя> — выбрать сейв для следующего запуска
+/newMap [seed] [пресет] — начать новую карту
+/mapgen get|set <путь> [значение] — настройки генерации карты
+/restore <id> — откатиться к снапшоту
+/backup — сделать бэкап сейчас
+/backups — список бэкапов
+/restoreBackup <id> — восстановить бэкап
 /uploadSave — загрузить сохранение через WebApp`)
 }
 
 // ── server status ─────────────────────────────────────────────────────────────
 
 func (b *Bot) handleStatus(chatID int64) {
-	b.reply(chatID, fmt.Sprintf("Сервер: %s", b.status.Check()))
+	text := formatCheckResult(b.status.Check(context.Background()))
+
+	st, err := b.container.Status(context.Background())
+	if err != nil {
+		text += "\nКонтейнер: ❓ " + err.Error()
+	} else {
+		text += "\nКонтейнер: " + formatContainerStatus(st, time.Now())
+	}
+
+	b.reply(chatID, text)
+}
+
+// formatCheckResult renders the game port and RCON probes.
+func formatCheckResult(res status.Result) string {
+	var sb strings.Builder
+
+	if res.Game.OK {
+		sb.WriteString(fmt.Sprintf("Сервер: 🟢 Работает (%d мс)", res.Game.Latency.Milliseconds()))
+		if res.Game.Version != "" {
+			sb.WriteString(", версия " + res.Game.Version)
+		}
+	} else {
+		sb.WriteString("Сервер: 🔴 Недоступен")
+		if res.Game.Err != nil {
+			sb.WriteString(" — " + res.Game.Err.Error())
+		}
+	}
+
+	if res.Rcon.Checked {
+		if res.Rcon.OK {
+			sb.WriteString(fmt.Sprintf("\nRCON: 🟢 отвечает (%d мс)", res.Rcon.Latency.Milliseconds()))
+		} else {
+			sb.WriteString("\nRCON: " + rconErrorText(res.Rcon.Err))
+		}
+	}
+
+	return sb.String()
+}
+
+// formatContainerStatus explains the container state, including why it is down.
+func formatContainerStatus(st domain.ContainerStatus, now time.Time) string {
+	var sb strings.Builder
+
+	switch st.State {
+	case domain.ContainerRunning:
+		sb.WriteString("🟢 запущен, аптайм " + formatDuration(st.Uptime(now)))
+		if st.Health != "" {
+			sb.WriteString(", health: " + st.Health)
+		}
+	case domain.ContainerRestarting:
+		sb.WriteString("🔁 перезапускается")
+	case domain.ContainerExited, domain.ContainerDead:
+		switch {
+		case st.OOMKilled:
+			sb.WriteString("💥 убит OOM-killer (не хватило памяти)")
+		case st.ExitCode != 0:
+			sb.WriteString(fmt.Sprintf("🔴 упал с кодом %d", st.ExitCode))
+		default:
+			sb.WriteString("⏹ остановлен")
+		}
+		if !st.FinishedAt.IsZero() {
+			sb.WriteString(", " + formatDuration(now.Sub(st.FinishedAt)) + " назад")
+		}
+	default:
+		sb.WriteString("⚪ " + string(st.State))
+	}
+
+	if st.Error != "" {
+		sb.WriteString("\nОшибка: " + st.Error)
+	}
+	if st.CrashLooping(now) {
+		sb.WriteString(fmt.Sprintf("\n⚠️ Похоже на crash loop: %d перезапусков", st.RestartCount))
+	} else if st.RestartCount > 0 {
+		sb.WriteString(fmt.Sprintf("\nПерезапусков: %d", st.RestartCount))
+	}
+
+	return sb.String()
+}
+
+// formatDuration renders d as "2д 3ч", "3ч 15м" or "42с".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	mins := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dд %dч", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dч %dм", hours, mins)
+	case mins > 0:
+		return fmt.Sprintf("%dм", mins)
+	default:
+		return fmt.Sprintf("%dс", int(d/time.Second))
+	}
+}
+
+// ── uptime ────────────────────────────────────────────────────────────────────
+
+func (b *Bot) handleUptime(chatID int64) {
+	now := time.Now()
+	var sb strings.Builder
+
+	sb.WriteString("📈 Аптайм\n")
+	for _, w := range []struct {
+		label  string
+		window time.Duration
+	}{
+		{"24ч", 24 * time.Hour},
+		{"7д", 7 * 24 * time.Hour},
+	} {
+		if ratio, ok := b.history.Uptime(w.window, now); ok {
+			sb.WriteString(fmt.Sprintf("%s: %.2f%%\n", w.label, ratio*100))
+		} else {
+			sb.WriteString(fmt.Sprintf("%s: нет данных\n", w.label))
+		}
+	}
+
+	recent := b.history.Recent(10)
+	if len(recent) > 0 {
+		sb.WriteString("\nПоследние события:\n")
+		for _, t := range recent {
+			sb.WriteString(t.At.Local().Format("02.01 15:04") + " ")
+			switch {
+			case t.Unknown:
+				sb.WriteString("⚪ нет данных — " + t.Reason)
+			case t.Online:
+				sb.WriteString("🟢 поднялся")
+			default:
+				sb.WriteString("🔴 упал")
+				if t.Reason != "" {
+					sb.WriteString(" — " + t.Reason)
+				}
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	b.reply(chatID, sb.String())
+}
+
+// NotifyStatusChange alerts all allowed users about a server state transition.
+// Registered with status.Monitor.OnChange.
+func (b *Bot) NotifyStatusChange(t status.Transition) {
+	if t.Online {
+		b.broadcast("🟢 Сервер снова доступен")
+		return
+	}
+	text := "🔴 Сервер недоступен"
+	if t.Reason != "" {
+		text += ": " + t.Reason
+	}
+	b.broadcast(text)
 }
 
 // ── players ───────────────────────────────────────────────────────────────────
 
 func (b *Bot) handlePlayers(chatID int64) {
-	resp, err := b.rcon.Execute("/players online")
+	resp, err := b.rcon.ExecuteContext(domain.Idempotent(context.Background()), "/players online")
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, rconErrorText(err))
 		return
 	}
 	if strings.TrimSpace(resp) == "" {
@@ -131,9 +360,9 @@ func (b *Bot) handleCmd(chatID int64, args string) {
 		b.reply(chatID, "Использование: /cmd <команда>")
 		return
 	}
-	resp, err := b.rcon.Execute(args)
+	resp, err := b.rcon.ExecuteContext(context.Background(), args)
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, rconErrorText(err))
 		return
 	}
 	if strings.TrimSpace(resp) == "" {
@@ -150,8 +379,8 @@ func (b *Bot) handleMsg(chatID int64, args string) {
 		b.reply(chatID, "Использование: /msg <текст>")
 		return
 	}
-	if _, err := b.rcon.Execute("/say " + args); err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+	if _, err := b.rcon.ExecuteContext(context.Background(), "/say "+args); err != nil {
+		b.reply(chatID, rconErrorText(err))
 		return
 	}
 	b.reply(chatID, "✅ Сообщение отправлено")
@@ -160,8 +389,8 @@ func (b *Bot) handleMsg(chatID int64, args string) {
 // ── save ──────────────────────────────────────────────────────────────────────
 
 func (b *Bot) handleSave(chatID int64) {
-	if _, err := b.rcon.Execute("/server-save"); err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+	if _, err := b.rcon.ExecuteContext(context.Background(), "/server-save"); err != nil {
+		b.reply(chatID, rconErrorText(err))
 		return
 	}
 	b.reply(chatID, "💾 Сохранение выполнено")
@@ -170,9 +399,9 @@ func (b *Bot) handleSave(chatID int64) {
 // ── time ──────────────────────────────────────────────────────────────────────
 
 func (b *Bot) handleTime(chatID int64) {
-	resp, err := b.rcon.Execute("/time")
+	resp, err := b.rcon.ExecuteContext(domain.Idempotent(context.Background()), "/time")
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, rconErrorText(err))
 		return
 	}
 	b.reply(chatID, "⏱ "+resp)
@@ -181,9 +410,9 @@ func (b *Bot) handleTime(chatID int64) {
 // ── evolution ─────────────────────────────────────────────────────────────────
 
 func (b *Bot) handleEvolution(chatID int64) {
-	resp, err := b.rcon.Execute("/evolution")
+	resp, err := b.rcon.ExecuteContext(domain.Idempotent(context.Background()), "/evolution")
 	if err != nil {
-		b.reply(chatID, "❌ "+err.Error())
+		b.reply(chatID, rconErrorText(err))
 		return
 	}
 	b.reply(chatID, "🦠 "+resp)
@@ -191,34 +420,171 @@ func (b *Bot) handleEvolution(chatID int64) {
 
 // ── restart (stop → sync mods → start) ───────────────────────────────────────
 
-func (b *Bot) handleRestart(chatID int64) {
+func (b *Bot) handleRestart(ctx context.Context, chatID int64) {
 	b.reply(chatID, "🔄 Перезапускаю сервер...")
 
-	if err := b.container.Stop(context.Background()); err != nil {
+	if err := b.container.Stop(ctx); err != nil {
 		b.reply(chatID, "❌ Не удалось остановить контейнер: "+err.Error())
 		return
 	}
 
-	// Удаляем автосейвы, чтобы сервер загрузил именно загруженную карту,
-	// а не последний _autosave*.zip, созданный пока сервер ещё работал.
-	if err := b.saves.CleanAutosaves(); err != nil {
-		log.Printf("CleanAutosaves error: %v", err)
-	}
-
-	b.syncModsWithReply(chatID)
+	b.syncModsWithReply(ctx, chatID)
+	b.reply(chatID, b.prepareStart())
 
-	if err := b.container.Start(context.Background()); err != nil {
+	if err := b.launch(ctx); err != nil {
 		b.reply(chatID, "❌ Не удалось запустить контейнер: "+err.Error())
 		return
 	}
 	b.reply(chatID, "✅ Сервер перезапущен")
 }
 
+// ── logs ──────────────────────────────────────────────────────────────────────
+
+const (
+	defaultLogLines = 50
+	maxLogLines     = 5000
+	// Длиннее этого лог уходит .txt-документом: лимит сообщения Telegram — 4096 символов.
+	maxLogMessageLen = 3500
+)
+
+// handleLogs sends the tail of the server log: /logs [n] [error|warning].
+// /logs follow and /logs stop start and stop following the log.
+func (b *Bot) handleLogs(chatID int64, args string) {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "follow":
+		b.handleLogsFollow(chatID)
+		return
+	case "stop":
+		if !b.cancelTask(logsTaskKey(chatID)) {
+			b.reply(chatID, "Слежение за логом не запущено")
+		}
+		return
+	}
+
+	n := defaultLogLines
+	level := docker.LogLevelAll
+
+	for _, arg := range strings.Fields(args) {
+		switch strings.ToLower(arg) {
+		case "error", "errors":
+			level = docker.LogLevelError
+		case "warning", "warnings", "warn":
+			level = docker.LogLevelWarning
+		default:
+			v, err := strconv.Atoi(arg)
+			if err != nil || v <= 0 {
+				b.reply(chatID, "Использование: /logs [n] [error|warning]")
+				return
+			}
+			n = min(v, maxLogLines)
+		}
+	}
+
+	// С фильтром по уровню берём хвост побольше, иначе нужных строк может не оказаться.
+	tail := n
+	if level != docker.LogLevelAll {
+		tail = maxLogLines
+	}
+
+	lines, err := b.container.Logs(context.Background(), tail)
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	lines = docker.FilterLogLevel(lines, level)
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if len(lines) == 0 {
+		b.reply(chatID, "📭 Подходящих строк в логе нет")
+		return
+	}
+
+	text := strings.Join(lines, "\n")
+	if len(text) > maxLogMessageLen {
+		b.replyDocument(chatID, fmt.Sprintf("factorio-%s.txt", time.Now().Format("20060102-150405")), strings.NewReader(text))
+		return
+	}
+	// Обратные кавычки внутри блока кода ломают разбор Markdown.
+	b.reply(chatID, "```\n"+strings.ReplaceAll(text, "`", "'")+"\n```", "Markdown")
+}
+
+const (
+	// logsFollowTimeout bounds /logs follow, so a forgotten stream stops by itself.
+	logsFollowTimeout = 15 * time.Minute
+	// logsFollowInterval — не чаще этого сообщение с логом правится: Telegram ограничивает правки.
+	logsFollowInterval = 3 * time.Second
+	logsFollowLines    = 20
+)
+
+func logsTaskKey(chatID int64) string { return fmt.Sprintf("logs:%d", chatID) }
+
+// handleLogsFollow shows the last lines of the server log in one message and keeps
+// editing it as new lines arrive, until /logs stop, the container stops or
+// logsFollowTimeout passes.
+func (b *Bot) handleLogsFollow(chatID int64) {
+	b.runTask(chatID, logsTaskKey(chatID), func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, logsFollowTimeout)
+		defer cancel()
+
+		var (
+			mu    sync.Mutex
+			lines []string
+		)
+		done := make(chan error, 1)
+		go func() {
+			done <- b.container.FollowLogs(ctx, logsFollowLines, func(line string) {
+				mu.Lock()
+				lines = append(lines, line)
+				if len(lines) > logsFollowLines {
+					lines = lines[len(lines)-logsFollowLines:]
+				}
+				mu.Unlock()
+			})
+		}()
+
+		msg := b.newLiveMessage(chatID, "📡 Слежу за логом сервера... /logs stop — остановить")
+		render := func(footer string) {
+			mu.Lock()
+			shown := lines
+			// Длинные строки не должны вывести сообщение за лимит Telegram.
+			for len(shown) > 1 && len(strings.Join(shown, "\n")) > maxLogMessageLen {
+				shown = shown[1:]
+			}
+			text := strings.Join(shown, "\n")
+			mu.Unlock()
+			if len(text) > maxLogMessageLen {
+				text = strings.ToValidUTF8(text[:maxLogMessageLen], "") + "…"
+			}
+			if text == "" {
+				text = "📭 Новых строк пока нет"
+			}
+			msg.set(text + "\n\n" + footer)
+		}
+
+		ticker := time.NewTicker(logsFollowInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case err := <-done:
+				if err != nil {
+					render("❌ " + err.Error())
+				} else {
+					render("⏹ Слежение за логом остановлено")
+				}
+				return
+			case <-ticker.C:
+				render("📡 /logs stop — остановить")
+			}
+		}
+	})
+}
+
 // ── stop container ────────────────────────────────────────────────────────────
 
-func (b *Bot) handleStopServer(chatID int64) {
+func (b *Bot) handleStopServer(ctx context.Context, chatID int64) {
 	b.reply(chatID, "⏹ Останавливаю контейнер...")
-	if err := b.container.Stop(context.Background()); err != nil {
+	if err := b.container.Stop(ctx); err != nil {
 		b.reply(chatID, "❌ "+err.Error())
 		return
 	}
@@ -227,29 +593,41 @@ func (b *Bot) handleStopServer(chatID int64) {
 
 // ── start container ───────────────────────────────────────────────────────────
 
-func (b *Bot) handleStartServer(chatID int64) {
-	// Удаляем автосейвы перед стартом — загружается именно загруженная карта.
-	if err := b.saves.CleanAutosaves(); err != nil {
-		log.Printf("CleanAutosaves error: %v", err)
-	}
-
-	b.syncModsWithReply(chatID)
+func (b *Bot) handleStartServer(ctx context.Context, chatID int64) {
+	b.syncModsWithReply(ctx, chatID)
+	b.reply(chatID, b.prepareStart())
 
 	b.reply(chatID, "▶️ Запускаю контейнер...")
-	if err := b.container.Start(context.Background()); err != nil {
+	if err := b.launch(ctx); err != nil {
 		b.reply(chatID, "❌ "+err.Error())
 		return
 	}
 	b.reply(chatID, "✅ Контейнер запущен")
 }
 
+// prepareStart makes the server load the active save and returns the text telling
+// the user which one. Autosaves are left alone: the active save is simply made the
+// newest file, unless a newer autosave holds progress the active save lacks.
+func (b *Bot) prepareStart() string {
+	plan, err := b.saves.PrepareStart()
+	if err != nil {
+		return "⚠️ Не удалось подготовить сейв: " + err.Error()
+	}
+	if plan.Skipped != "" {
+		return fmt.Sprintf("⚠️ Автосейв «%s» новее активного «%s» — похоже, сервер остановился без сохранения.\n"+
+			"🗺 Загружаю автосейв, чтобы не потерять прогресс. Вернуться к «%s»: /useSave %s и /restart",
+			plan.Name, plan.Skipped, plan.Skipped, plan.Skipped)
+	}
+	return "🗺 Загружаю сейв «" + plan.Name + "»"
+}
+
 // syncModsWithReply runs SyncMods and sends a status reply to the user.
-func (b *Bot) syncModsWithReply(chatID int64) {
+func (b *Bot) syncModsWithReply(ctx context.Context, chatID int64) {
 	b.reply(chatID, "🔍 Проверяю моды...")
 
-	count, failures, err := b.mods.SyncMods(context.Background())
+	count, failures, err := b.mods.SyncMods(ctx, b.newModsProgress(chatID).update)
 	if err != nil {
-		b.reply(chatID, "❌ Ошибка синхронизации модов: "+err.Error())
+		b.reply(chatID, formatModsError("Ошибка синхронизации модов", err))
 		return
 	}
 	if len(failures) > 0 {
@@ -276,13 +654,1001 @@ func (b *Bot) handleGetPassword(chatID int64) {
 func (b *Bot) handleDownloadSave(chatID int64) {
 	b.reply(chatID, "📦 Готовлю файл сохранения...")
 
-	name, data, err := b.saves.LatestSave()
+	save, f, err := b.saves.OpenLatest()
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	defer f.Close()
+
+	if save.Size > telegramUploadLimit {
+		b.sendDownloadLink(chatID, save)
+		return
+	}
+	b.replyDocument(chatID, save.Name, f)
+}
+
+// telegramUploadLimit — боты не могут отправлять файлы больше 50 MB.
+const telegramUploadLimit = 50 << 20
+
+// sendDownloadLink replies with a single-use WebApp link for a save too big for Telegram.
+func (b *Bot) sendDownloadLink(chatID int64, save saves.SaveFile) {
+	if b.downloads == nil {
+		b.reply(chatID, fmt.Sprintf("❌ Сейв «%s» весит %s — больше лимита Telegram", save.Name, formatSize(save.Size)))
+		return
+	}
+	link, expires, err := b.downloads.Link(save.Name)
+	if err != nil {
+		b.reply(chatID, fmt.Sprintf("❌ Сейв «%s» весит %s — больше лимита Telegram, а ссылку выдать не удалось: %v",
+			save.Name, formatSize(save.Size), err))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"📦 Сейв «%s» весит %s — больше лимита Telegram.\nСкачай по ссылке (одноразовая, действует до %s):\n%s",
+		save.Name, formatSize(save.Size), expires.Local().Format("15:04"), link))
+	// Превью ссылки не нужно — и Telegram не будет лишний раз её открывать.
+	msg.DisableWebPagePreview = true
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("sendDownloadLink error: %v", err)
+	}
+}
+
+// ── saves & snapshots ─────────────────────────────────────────────────────────
+
+func (b *Bot) handleSaves(chatID int64) {
+	files, err := b.saves.List()
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	snaps, err := b.saves.Snapshots(context.Background())
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+
+	active := b.saves.Active()
+
+	var sb strings.Builder
+	sb.WriteString("🗺 Сохранения (⭐ — загрузится при запуске):\n")
+	if len(files) == 0 {
+		sb.WriteString("— пусто\n")
+	}
+	for _, f := range files {
+		mark := "•"
+		if f.Name == active {
+			mark = "⭐"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s — %s, %s\n", mark, f.Name, formatSize(f.Size), f.ModTime.Local().Format("02.01 15:04")))
+		if h, err := b.saves.Inspect(f.Name); err == nil {
+			sb.WriteString("   " + formatSaveHeader(h) + "\n")
+		}
+	}
+
+	sb.WriteString("\n🗄 Снапшоты (/restore <id>):\n")
+	if len(snaps) == 0 {
+		sb.WriteString("— пусто\n")
+	}
+	const maxSnapshots = 15
+	for i, s := range snaps {
+		if i == maxSnapshots {
+			sb.WriteString(fmt.Sprintf("… и ещё %d\n", len(snaps)-maxSnapshots))
+			break
+		}
+		where := ""
+		switch {
+		case s.RemoteOnly:
+			where = " ☁️"
+		case s.Offsite:
+			where = " 💾☁️"
+		}
+		sb.WriteString(fmt.Sprintf("• %s%s — %s (%s, %s): %s\n",
+			s.ID, where, s.Reason, s.Origin.Actor, s.Origin.Source, strings.Join(s.Files, ", ")))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	if kb, ok := saveKeyboard(files, active); ok {
+		msg.ReplyMarkup = kb
+	}
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("handleSaves send error: %v", err)
+	}
+}
+
+// ── new map ───────────────────────────────────────────────────────────────────
+
+// newMapTimeout bounds map generation; large maps with many mods take a while.
+const newMapTimeout = 10 * time.Minute
+
+// handleNewMap stops the server, creates a new map with the configured settings
+// (optionally a preset and a fixed seed), archives the old saves and starts the server
+// on the new map: /newMap [seed] [preset].
+func (b *Bot) handleNewMap(chatID int64, from *tgbotapi.User, args string) {
+	presets, err := b.mapgen.Presets()
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+
+	var (
+		seed   *uint32
+		preset string
+	)
+	for _, arg := range strings.Fields(args) {
+		if v, err := strconv.ParseUint(arg, 10, 32); err == nil {
+			s := uint32(v)
+			seed = &s
+			continue
+		}
+		if _, ok := presets[arg]; !ok {
+			b.reply(chatID, "❌ Неизвестный пресет «"+arg+"»\n\n"+formatPresets(presets))
+			return
+		}
+		preset = arg
+	}
+
+	b.runTask(chatID, taskServer, func(ctx context.Context) {
+		b.createNewMap(ctx, chatID, from, seed, preset)
+	})
+}
+
+// createNewMap does the work of /newMap, reporting every step in one edited message.
+func (b *Bot) createNewMap(ctx context.Context, chatID int64, from *tgbotapi.User, seed *uint32, preset string) {
+	msg := b.newLiveMessage(chatID, "🗺 Создаю новую карту — сервер будет остановлен...")
+	ctx, cancel := context.WithTimeout(ctx, newMapTimeout)
+	defer cancel()
+
+	if err := b.container.Stop(ctx); err != nil {
+		msg.add("❌ Не удалось остановить контейнер: " + err.Error())
+		return
+	}
+
+	base := preset
+	if base == "" {
+		base = "map"
+	}
+	name := fmt.Sprintf("%s-%s.zip", base, time.Now().Format("20060102-1504"))
+	msg.add("⏳ Генерирую " + name + "...")
+	if err := b.mapgen.Create(ctx, name, preset, seed); err != nil {
+		msg.add("❌ Карта не создана: " + err.Error() + "\nСтарые сейвы не тронуты, запускаю сервер.")
+		msg.add(b.prepareStart())
+		msg.add(b.startContainer(ctx))
+		return
+	}
+
+	origin := originOf(from, "newMap")
+	if snap, err := b.saves.ArchiveExcept(name, origin, "new map "+name); err != nil {
+		msg.add("⚠️ Не удалось архивировать старые сейвы: " + err.Error())
+	} else if snap != nil {
+		msg.add("🗄 Старые сейвы в снапшоте " + snap.ID + " (/restore " + snap.ID + ")")
+	}
+	if err := b.saves.SetActive(name, origin); err != nil {
+		msg.add("⚠️ " + err.Error())
+	}
+
+	msg.add(b.prepareStart())
+	msg.add(b.startContainer(ctx))
+}
+
+// startContainer starts the server and returns the line reporting the outcome.
+func (b *Bot) startContainer(ctx context.Context) string {
+	if err := b.launch(ctx); err != nil {
+		return "❌ Не удалось запустить контейнер: " + err.Error()
+	}
+	return "✅ Сервер запущен"
+}
+
+// launch starts the container prepared by prepareStart and, once it is up, records
+// the start for the active save choice.
+func (b *Bot) launch(ctx context.Context) error {
+	if err := b.container.Start(ctx); err != nil {
+		return err
+	}
+	if err := b.saves.MarkStarted(); err != nil {
+		log.Printf("saves: %v", err)
+	}
+	return nil
+}
+
+func formatPresets(presets map[string]mapgen.Preset) string {
+	if len(presets) == 0 {
+		return "Пресетов нет"
+	}
+	var sb strings.Builder
+	sb.WriteString("Пресеты:")
+	for _, name := range mapgen.PresetNames(presets) {
+		sb.WriteString("\n• " + name)
+		if d := presets[name].Description; d != "" {
+			sb.WriteString(" — " + d)
+		}
+	}
+	return sb.String()
+}
+
+// ── map settings ──────────────────────────────────────────────────────────────
+
+const mapgenUsage = `Использование:
+/mapgen get <путь> — показать значение
+/mapgen set <путь> <значение> — изменить значение
+
+Путь через точку, например autoplace_controls.iron-ore.richness или enemy_expansion.enabled.`
+
+// handleMapgen reads and edits map-gen-settings.json and map-settings.json by dotted
+// path. The file is chosen by the first path segment. Changes apply to the next /newMap.
+func (b *Bot) handleMapgen(chatID int64, from *tgbotapi.User, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.sendMapgenForm(chatID)
+		return
+	}
+	if len(fields) < 2 || (fields[0] != "get" && fields[0] != "set") || (fields[0] == "set" && len(fields) < 3) {
+		b.reply(chatID, mapgenUsage)
+		return
+	}
+
+	path := fields[1]
+	doc, err := b.mapgen.DocumentFor(path)
+	if errors.Is(err, mapgen.ErrUnknownSetting) {
+		b.reply(chatID, "❌ Неизвестная настройка «"+path+"»\n\n"+mapgenUsage)
+		return
+	}
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	kind := doc.Kind
+
+	if fields[0] == "get" {
+		value, err := doc.Get(path)
+		if err != nil {
+			b.reply(chatID, "❌ "+err.Error())
+			return
+		}
+		b.reply(chatID, fmt.Sprintf("%s → %s:\n%s", kind, path, value))
+		return
+	}
+
+	// Значение — всё после пути, чтобы строки с пробелами не обрезались.
+	rest := strings.TrimSpace(strings.TrimSpace(args)[len("set"):])
+	value := strings.TrimSpace(rest[len(path):])
+	old, _ := doc.Get(path)
+	if err := doc.Set(path, value); err != nil {
+		b.reply(chatID, "❌ "+formatMapgenError(err))
+		return
+	}
+	if err := doc.Save(); err != nil {
+		b.reply(chatID, "❌ Не удалось сохранить: "+err.Error())
+		return
+	}
+	updated, _ := doc.Get(path)
+	log.Printf("mapgen: %s set %s from %s to %s by %s", kind, path, old, updated, originOf(from, "mapgen").Actor)
+	b.reply(chatID, fmt.Sprintf("✅ %s: %s = %s\nПрименится к следующей /newMap.", kind, path, updated))
+}
+
+func formatMapgenError(err error) string {
+	var invalid *mapgen.ValidationError
+	if errors.As(err, &invalid) {
+		return "Недопустимые значения:\n• " + strings.Join(invalid.Problems, "\n• ")
+	}
+	return err.Error()
+}
+
+// sendMapgenForm offers the WebApp form for the common settings.
+func (b *Bot) sendMapgenForm(chatID int64) {
+	if b.webAppURL == "" {
+		b.reply(chatID, mapgenUsage)
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, "🗺 Настройки генерации карты:\n\n"+mapgenUsage)
+	msg.ReplyMarkup = webAppKeyboard{
+		InlineKeyboard: [][]webAppBtn{{{
+			Text:   "⚙️ Открыть форму",
+			WebApp: webAppInfo{URL: strings.TrimRight(b.webAppURL, "/") + "/mapgen"},
+		}}},
+	}
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("sendMapgenForm send error: %v", err)
+	}
+}
+
+// ── mods ──────────────────────────────────────────────────────────────────────
+
+const modsUsage = `Использование:
+/mods — установленные моды
+/mods outdated — какие моды можно обновить
+/mods update <мод> [мод…] — обновить указанные моды
+/mods update all — обновить все устаревшие
+/mods verify — проверить SHA-1 установленных zip`
+
+// modsTimeout bounds portal checks and downloads started from chat.
+const modsTimeout = 30 * time.Minute
+
+// modsProgressInterval limits how often the progress message is edited: Telegram
+// rate-limits edits, and a new number every few seconds is enough.
+const modsProgressInterval = 3 * time.Second
+
+// modsProgress shows mod downloads in a single message that is edited in place.
+type modsProgress struct {
+	b      *Bot
+	chatID int64
+	msgID  int
+	last   time.Time
+}
+
+func (b *Bot) newModsProgress(chatID int64) *modsProgress {
+	return &modsProgress{b: b, chatID: chatID}
+}
+
+// update is a mods.ProgressFunc: the first call sends the message, later ones edit
+// it, intermediate updates no more often than modsProgressInterval.
+func (p *modsProgress) update(pr mods.Progress) {
+	text := fmt.Sprintf("⬇️ Скачиваю моды: %d/%d, %s", pr.Done, pr.Total, formatSize(pr.Bytes))
+	if pr.Done == pr.Total {
+		text = fmt.Sprintf("📦 Загрузка модов завершена: %d/%d, %s", pr.Done, pr.Total, formatSize(pr.Bytes))
+	}
+
+	if p.msgID == 0 {
+		msg, err := p.b.api.Send(tgbotapi.NewMessage(p.chatID, text))
+		if err != nil {
+			log.Printf("mods progress send error: %v", err)
+			return
+		}
+		p.msgID, p.last = msg.MessageID, time.Now()
+		return
+	}
+	if pr.Done < pr.Total && time.Since(p.last) < modsProgressInterval {
+		return
+	}
+	p.last = time.Now()
+	if _, err := p.b.api.Send(tgbotapi.NewEditMessageText(p.chatID, p.msgID, text)); err != nil {
+		log.Printf("mods progress edit error: %v", err)
+	}
+}
+
+func (b *Bot) handleMods(chatID int64, from *tgbotapi.User, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.handleModsList(chatID)
+		return
+	}
+	switch fields[0] {
+	case "outdated":
+		b.runTask(chatID, taskMods, func(ctx context.Context) { b.handleModsOutdated(ctx, chatID) })
+	case "verify":
+		b.runTask(chatID, taskMods, func(ctx context.Context) { b.handleModsVerify(ctx, chatID) })
+	case "update":
+		if len(fields) < 2 {
+			b.reply(chatID, modsUsage)
+			return
+		}
+		names := fields[1:]
+		if len(names) == 1 && names[0] == "all" {
+			names = nil
+		}
+		b.runTask(chatID, taskMods, func(ctx context.Context) { b.handleModsUpdate(ctx, chatID, from, names) })
+	default:
+		b.reply(chatID, modsUsage)
+	}
+}
+
+func (b *Bot) handleModsList(chatID int64) {
+	lock, err := b.mods.Lock()
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	if len(lock.Mods) == 0 {
+		b.reply(chatID, "Модов нет (или ещё не было синхронизации)\n\n"+modsUsage)
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🧩 Моды (%d):\n", len(lock.Mods))
+	for _, name := range lock.Names() {
+		sb.WriteString("\n• " + name + " " + lock.Mods[name].Version)
+	}
+	sb.WriteString("\n\n" + modsUsage)
+	b.reply(chatID, sb.String())
+}
+
+func (b *Bot) handleModsOutdated(ctx context.Context, chatID int64) {
+	b.reply(chatID, "🔍 Проверяю версии на mods.factorio.com...")
+	ctx, cancel := context.WithTimeout(ctx, modsTimeout)
+	defer cancel()
+
+	versions, err := b.mods.Versions(ctx)
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	var outdated, unknown []string
+	for _, v := range versions {
+		switch {
+		case v.Latest == "":
+			unknown = append(unknown, v.Name)
+		case v.Outdated():
+			line := fmt.Sprintf("• %s %s → %s", v.Name, orDash(v.Installed), v.Latest)
+			if v.Pinned {
+				line += " 📌"
+			}
+			outdated = append(outdated, line)
+		}
+	}
+
+	var sb strings.Builder
+	if len(outdated) == 0 {
+		fmt.Fprintf(&sb, "✅ Все моды актуальны (%d)", len(versions))
+	} else {
+		fmt.Fprintf(&sb, "⬆️ Можно обновить (%d из %d):\n%s\n\n/mods update all — обновить все",
+			len(outdated), len(versions), strings.Join(outdated, "\n"))
+		if strings.Contains(sb.String(), "📌") {
+			sb.WriteString("\n📌 — версия закреплена в mod-list.json, обновление её не тронет")
+		}
+	}
+	if len(unknown) > 0 {
+		sb.WriteString("\n\n⚠️ Не удалось проверить: " + strings.Join(unknown, ", "))
+	}
+	b.reply(chatID, sb.String())
+}
+
+func (b *Bot) handleModsVerify(ctx context.Context, chatID int64) {
+	b.reply(chatID, "🔍 Проверяю контрольные суммы модов...")
+	ctx, cancel := context.WithTimeout(ctx, modsTimeout)
+	defer cancel()
+
+	results, err := b.mods.Verify(ctx)
 	if err != nil {
 		b.reply(chatID, "❌ "+err.Error())
 		return
 	}
+	var bad, lockOnly []string
+	for _, r := range results {
+		switch {
+		case !r.OK():
+			bad = append(bad, fmt.Sprintf("• %s: %s", r.FileName, r.Problem))
+		case !r.PortalChecked:
+			lockOnly = append(lockOnly, r.Name)
+		}
+	}
+
+	var sb strings.Builder
+	if len(bad) == 0 {
+		fmt.Fprintf(&sb, "✅ Все моды целы (%d)", len(results))
+	} else {
+		fmt.Fprintf(&sb, "⚠️ Проблемы (%d из %d):\n%s\n\nБитые zip переименованы в *%s — /restart скачает их заново",
+			len(bad), len(results), strings.Join(bad, "\n"), mods.BrokenSuffix)
+	}
+	if len(lockOnly) > 0 {
+		sb.WriteString("\n\nℹ️ Сверены только с mod-lock.json (портал не ответил): " + strings.Join(lockOnly, ", "))
+	}
+	b.reply(chatID, sb.String())
+}
+
+func (b *Bot) handleModsUpdate(ctx context.Context, chatID int64, from *tgbotapi.User, names []string) {
+	if b.modsUpdateOnlyWhenEmpty {
+		checkCtx, cancel := context.WithTimeout(ctx, playersCheckTimeout)
+		players, err := b.onlinePlayers(checkCtx)
+		cancel()
+		if err != nil && !errors.Is(err, errServerDown) {
+			b.reply(chatID, "❌ Не удалось узнать, есть ли игроки: "+err.Error())
+			return
+		}
+		if players > 0 {
+			b.queueModsUpdate(chatID, from, names, players)
+			return
+		}
+	}
+	b.runModsUpdate(ctx, chatID, from, names)
+}
+
+// queuedModsUpdate is a /mods update postponed until the server is empty.
+type queuedModsUpdate struct {
+	chatID int64
+	from   *tgbotapi.User
+	names  []string // nil — все устаревшие
+}
+
+// playersCheckTimeout bounds the /players query that decides whether to update now.
+const playersCheckTimeout = 10 * time.Second
+
+// queueModsUpdate remembers the update until OnStatusCheck sees no players online.
+// Only the latest request is kept.
+func (b *Bot) queueModsUpdate(chatID int64, from *tgbotapi.User, names []string, players int) {
+	b.queuedMu.Lock()
+	replaced := b.queuedUpdate != nil
+	b.queuedUpdate = &queuedModsUpdate{chatID: chatID, from: from, names: names}
+	b.queuedMu.Unlock()
+
+	text := fmt.Sprintf("⏸ На сервере %d игрок(ов) — моды обновятся, когда сервер опустеет (MODS_UPDATE_ONLY_WHEN_EMPTY)", players)
+	if replaced {
+		text += "\nПредыдущий отложенный /mods update заменён этим."
+	}
+	b.reply(chatID, text)
+}
+
+// OnStatusCheck starts the queued /mods update once no players are online.
+// Registered with status.Monitor.OnCheck.
+func (b *Bot) OnStatusCheck(res status.Result) {
+	b.queuedMu.Lock()
+	queued := b.queuedUpdate != nil
+	b.queuedMu.Unlock()
+	// Пока идёт другая операция с модами, ждём следующей проверки.
+	if !queued || b.taskRunning(taskMods) {
+		return
+	}
 
-	b.replyDocument(chatID, name, data)
+	players := 0
+	if res.Online() {
+		ctx, cancel := context.WithTimeout(context.Background(), playersCheckTimeout)
+		n, err := b.onlinePlayers(ctx)
+		cancel()
+		if err != nil && !errors.Is(err, errServerDown) {
+			log.Printf("mods: отложенное обновление ждёт, игроков узнать не удалось: %v", err)
+			return
+		}
+		players = n
+	}
+	if players > 0 {
+		return
+	}
+
+	b.queuedMu.Lock()
+	u := b.queuedUpdate
+	b.queuedUpdate = nil
+	b.queuedMu.Unlock()
+	if u == nil {
+		return
+	}
+	b.reply(u.chatID, "▶️ Сервер опустел — запускаю отложенный /mods update")
+	b.runTask(u.chatID, taskMods, func(ctx context.Context) { b.runModsUpdate(ctx, u.chatID, u.from, u.names) })
+}
+
+// runModsUpdate downloads newer versions of names (all outdated mods if empty) and reports the results.
+func (b *Bot) runModsUpdate(ctx context.Context, chatID int64, from *tgbotapi.User, names []string) {
+	ctx, cancel := context.WithTimeout(ctx, modsTimeout)
+	defer cancel()
+
+	b.reply(chatID, "⬇️ Обновляю моды...")
+	results, err := b.mods.Update(ctx, names, b.newModsProgress(chatID).update)
+	if err != nil && len(results) == 0 {
+		b.reply(chatID, formatModsError("Моды не обновлены", err))
+		return
+	}
+
+	var lines []string
+	updated := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			lines = append(lines, fmt.Sprintf("❌ %s: %v", r.Name, r.Err))
+		case r.Skipped != "":
+			lines = append(lines, fmt.Sprintf("➖ %s: %s", r.Name, r.Skipped))
+		default:
+			updated++
+			lines = append(lines, fmt.Sprintf("✅ %s %s → %s", r.Name, orDash(r.From), r.To))
+			log.Printf("mods: %s %s → %s by %s", r.Name, r.From, r.To, originOf(from, "mods").Actor)
+		}
+	}
+	if len(lines) == 0 {
+		b.reply(chatID, "✅ Все моды актуальны")
+		return
+	}
+	text := strings.Join(lines, "\n")
+	if err != nil {
+		text += "\n\n❌ " + err.Error()
+	}
+	if updated > 0 {
+		text += "\n\n⏳ Новые версии загрузятся после перезапуска сервера: /restart"
+	}
+	b.reply(chatID, text)
+}
+
+// formatModsError renders dependency conflicts one per line; anything else as is.
+func formatModsError(prefix string, err error) string {
+	var conflict *mods.ConflictError
+	if !errors.As(err, &conflict) {
+		return "❌ " + prefix + ": " + err.Error()
+	}
+	var sb strings.Builder
+	sb.WriteString("⛔ " + prefix + " — конфликты зависимостей, ничего не скачано:\n")
+	for _, c := range conflict.Conflicts {
+		sb.WriteString("\n• " + c)
+	}
+	return sb.String()
+}
+
+// errServerDown is returned by onlinePlayers when RCON is unreachable: a stopped
+// server has no players.
+var errServerDown = errors.New("server is not running")
+
+// onlinePlayers returns the number of connected players.
+func (b *Bot) onlinePlayers(ctx context.Context) (int, error) {
+	resp, err := b.rcon.ExecuteContext(domain.Idempotent(ctx), "/players online count")
+	if err != nil {
+		var connErr *rcon.ConnectError
+		if errors.As(err, &connErr) {
+			return 0, errServerDown
+		}
+		return 0, err
+	}
+	// "Online players (2):" — число в первых скобках.
+	open, closing := strings.Index(resp, "("), strings.Index(resp, ")")
+	if open < 0 || closing < open {
+		return 0, fmt.Errorf("unexpected /players reply %q", resp)
+	}
+	return strconv.Atoi(resp[open+1 : closing])
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+// ── server settings ───────────────────────────────────────────────────────────
+
+// setApplyCallback and setCancelCallback prefix the buttons under a /set preview; the
+// edit itself is kept in b.edits, because callback data is limited to 64 bytes.
+const (
+	setApplyCallback  = "set:"
+	setCancelCallback = "setCancel:"
+	// settingsEditTTL — сколько живёт неподтверждённое изменение.
+	settingsEditTTL = 15 * time.Minute
+)
+
+// settingsEdit is a /set waiting for confirmation.
+type settingsEdit struct {
+	key, value string
+	created    time.Time
+}
+
+// handleSettings shows server-settings.json. Settings changed since the server started
+// are marked: Factorio reads the file only on start.
+func (b *Bot) handleSettings(chatID int64) {
+	s, err := b.settings.Load()
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	pending := make(map[string]bool)
+	for _, key := range b.settingsPending() {
+		pending[key] = true
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⚙️ Настройки сервера:\n")
+	for _, key := range settings.Keys() {
+		value, _ := s.Get(key)
+		sb.WriteString("\n")
+		if pending[key] {
+			sb.WriteString("⏳ ")
+		}
+		sb.WriteString(key + " = " + displaySetting(key, value))
+	}
+	if len(pending) > 0 {
+		sb.WriteString("\n\n⏳ — изменено, применится после перезапуска (/restart)")
+	}
+	sb.WriteString("\n\nИзменить: /set <ключ> <значение>")
+	b.reply(chatID, sb.String())
+}
+
+// handleSet previews a change of server-settings.json and asks for confirmation:
+// /set <key> <value>.
+func (b *Bot) handleSet(chatID int64, args string) {
+	key, value, _ := strings.Cut(strings.TrimSpace(args), " ")
+	value = strings.TrimSpace(value)
+	if key == "" || value == "" {
+		b.reply(chatID, "Использование: /set <ключ> <значение>\nНапример: /set max_players 10, /set visibility.public false\nСписок ключей: /settings")
+		return
+	}
+
+	change, err := b.settings.Preview(key, value)
+	if err != nil {
+		b.reply(chatID, "❌ "+formatSettingsError(err))
+		return
+	}
+	if change.Old == change.New {
+		b.reply(chatID, fmt.Sprintf("%s уже %s", key, displaySetting(key, change.New)))
+		return
+	}
+
+	id, err := b.addSettingsEdit(settingsEdit{key: key, value: value, created: time.Now()})
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	msg := tgbotapi.NewMessage(chatID, "Изменить server-settings.json?\n\n"+formatChange(change))
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Применить", setApplyCallback+id),
+		tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", setCancelCallback+id),
+	))
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("handleSet send error: %v", err)
+	}
+}
+
+// applySettingsEdit handles the buttons under a /set preview and replaces the preview
+// with the outcome.
+func (b *Bot) applySettingsEdit(q *tgbotapi.CallbackQuery, id string, apply bool) {
+	b.editsMu.Lock()
+	edit, ok := b.edits[id]
+	delete(b.edits, id)
+	b.editsMu.Unlock()
+
+	var text string
+	switch {
+	case !ok:
+		text = "⌛ Изменение устарело, повтори /set"
+	case !apply:
+		text = "✖️ Отменено"
+	default:
+		change, err := b.settings.Set(edit.key, edit.value)
+		if err != nil {
+			text = "❌ " + formatSettingsError(err)
+			break
+		}
+		log.Printf("settings: %s = %s by %s", change.Key, displaySetting(change.Key, change.New), originOf(q.From, "set").Actor)
+		text = "✅ Сохранено\n\n" + formatChange(change) + "\n\n⏳ Применится после перезапуска сервера: /restart"
+	}
+
+	b.answerCallback(q.ID, "")
+	if q.Message == nil {
+		return
+	}
+	if _, err := b.api.Send(tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, text)); err != nil {
+		log.Printf("applySettingsEdit send error: %v", err)
+	}
+}
+
+func (b *Bot) addSettingsEdit(edit settingsEdit) (string, error) {
+	var buf [6]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf[:])
+
+	b.editsMu.Lock()
+	defer b.editsMu.Unlock()
+	for k, e := range b.edits {
+		if time.Since(e.created) > settingsEditTTL {
+			delete(b.edits, k)
+		}
+	}
+	b.edits[id] = edit
+	return id, nil
+}
+
+// settingsPending returns the keys the running server has not read yet.
+func (b *Bot) settingsPending() []string {
+	var startedAt time.Time
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if st, err := b.container.Status(ctx); err == nil && st.State == domain.ContainerRunning {
+		startedAt = st.StartedAt
+	}
+	return b.settings.Pending(startedAt)
+}
+
+func formatChange(c settings.Change) string {
+	return fmt.Sprintf("- %s = %s\n+ %s = %s",
+		c.Key, displaySetting(c.Key, c.Old), c.Key, displaySetting(c.Key, c.New))
+}
+
+// displaySetting masks secrets and makes empty values visible.
+func displaySetting(key, value string) string {
+	switch {
+	case value == "":
+		return "(пусто)"
+	case settings.IsSecret(key):
+		return "••••••"
+	}
+	return value
+}
+
+func formatSettingsError(err error) string {
+	var invalid *settings.ValidationError
+	if errors.As(err, &invalid) {
+		return "Недопустимое значение:\n• " + strings.Join(invalid.Problems, "\n• ")
+	}
+	return err.Error()
+}
+
+// ── active save ───────────────────────────────────────────────────────────────
+
+// useSaveCallback prefixes inline-button data; the save is identified by saveKey,
+// because callback data is limited to 64 bytes and save names can be longer.
+const useSaveCallback = "useSave:"
+
+// maxSaveButtons caps the picker so the keyboard stays usable on a phone.
+const maxSaveButtons = 10
+
+// handleUseSave selects the save to load on the next start: /useSave <name>, or
+// without arguments shows a picker.
+func (b *Bot) handleUseSave(chatID int64, from *tgbotapi.User, args string) {
+	name := strings.TrimSpace(args)
+	if name == "" {
+		files, err := b.saves.List()
+		if err != nil {
+			b.reply(chatID, "❌ "+err.Error())
+			return
+		}
+		kb, ok := saveKeyboard(files, b.saves.Active())
+		if !ok {
+			b.reply(chatID, "Нет других сохранений. Использование: /useSave <имя>")
+			return
+		}
+		msg := tgbotapi.NewMessage(chatID, "Какой сейв загрузить при следующем запуске?")
+		msg.ReplyMarkup = kb
+		if _, err := b.api.Send(msg); err != nil {
+			log.Printf("handleUseSave send error: %v", err)
+		}
+		return
+	}
+
+	if !strings.HasSuffix(name, ".zip") {
+		name += ".zip"
+	}
+	b.reply(chatID, b.useSave(name, from))
+}
+
+// handleCallback processes inline keyboard presses.
+func (b *Bot) handleCallback(q *tgbotapi.CallbackQuery) {
+	if q.From == nil || !b.isAllowedUser(q.From.ID) {
+		b.answerCallback(q.ID, "⛔ Нет доступа")
+		return
+	}
+
+	if id, ok := strings.CutPrefix(q.Data, setApplyCallback); ok {
+		b.applySettingsEdit(q, id, true)
+		return
+	}
+	if id, ok := strings.CutPrefix(q.Data, setCancelCallback); ok {
+		b.applySettingsEdit(q, id, false)
+		return
+	}
+	key, ok := strings.CutPrefix(q.Data, useSaveCallback)
+	if !ok {
+		b.answerCallback(q.ID, "")
+		return
+	}
+	files, err := b.saves.List()
+	if err != nil {
+		b.answerCallback(q.ID, "❌ "+err.Error())
+		return
+	}
+	for _, f := range files {
+		if saveKey(f.Name) == key {
+			text := b.useSave(f.Name, q.From)
+			b.answerCallback(q.ID, text)
+			if q.Message != nil {
+				b.reply(q.Message.Chat.ID, text)
+			}
+			return
+		}
+	}
+	b.answerCallback(q.ID, "❌ Сейв больше не существует")
+}
+
+// useSave makes name the active save and returns the reply for the user.
+func (b *Bot) useSave(name string, from *tgbotapi.User) string {
+	if err := b.saves.SetActive(name, originOf(from, "useSave")); err != nil {
+		return "❌ " + err.Error()
+	}
+	return fmt.Sprintf("⭐ При следующем запуске загрузится «%s». Перезапусти сервер: /restart", name)
+}
+
+func (b *Bot) answerCallback(id, text string) {
+	if _, err := b.api.Request(tgbotapi.NewCallback(id, text)); err != nil {
+		log.Printf("answerCallback error: %v", err)
+	}
+}
+
+// saveKeyboard builds one button per save except the active one; false if there are none.
+func saveKeyboard(files []saves.SaveFile, active string) (tgbotapi.InlineKeyboardMarkup, bool) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, f := range files {
+		if f.Name == active {
+			continue
+		}
+		if len(rows) == maxSaveButtons {
+			break
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ "+f.Name, useSaveCallback+saveKey(f.Name)),
+		))
+	}
+	if len(rows) == 0 {
+		return tgbotapi.InlineKeyboardMarkup{}, false
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...), true
+}
+
+// saveKey is a short stable identifier of a save name for callback data.
+func saveKey(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// formatSaveHeader renders a one-line summary of a save header.
+func formatSaveHeader(h *saves.Header) string {
+	parts := []string{"v" + h.Version.String()}
+	if h.LevelName != "" {
+		parts = append(parts, h.LevelName)
+	}
+	if h.PlayedTicks > 0 {
+		parts = append(parts, "наиграно "+formatDuration(h.PlayTime()))
+	}
+	parts = append(parts, fmt.Sprintf("модов: %d", len(h.Mods)))
+	if h.SpaceAge {
+		parts = append(parts, "🚀 Space Age")
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (b *Bot) handleRestore(chatID int64, from *tgbotapi.User, args string) {
+	id := strings.TrimSpace(args)
+	if id == "" {
+		b.reply(chatID, "Использование: /restore <id> (список — /saves)")
+		return
+	}
+	if err := b.saves.Restore(context.Background(), id, originOf(from, "restore")); err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("✅ Снапшот %s восстановлен. Текущие сейвы сохранены в новый снапшот. Перезапусти сервер для применения.", id))
+}
+
+// ── backups ───────────────────────────────────────────────────────────────────
+
+func (b *Bot) handleBackup(ctx context.Context, chatID int64, from *tgbotapi.User) {
+	b.reply(chatID, "💾 Сохраняю игру и делаю бэкап...")
+
+	bk, err := b.backups.BackupNow(ctx, originOf(from, "backup").Actor)
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("✅ Бэкап %s: %s, %s", bk.ID, bk.SaveName, formatSize(bk.Size)))
+}
+
+func (b *Bot) handleBackups(chatID int64) {
+	list, err := b.backups.List(context.Background())
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	if len(list) == 0 {
+		b.reply(chatID, "🗄 Бэкапов пока нет")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🗄 Бэкапы (/restoreBackup <id>):\n")
+	const maxBackups = 30
+	for i, bk := range list {
+		if i == maxBackups {
+			sb.WriteString(fmt.Sprintf("… и ещё %d\n", len(list)-maxBackups))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("• %s — %s, %s (%s)\n", bk.ID, bk.SaveName, formatSize(bk.Size), bk.Trigger))
+	}
+	b.reply(chatID, sb.String())
+}
+
+func (b *Bot) handleRestoreBackup(ctx context.Context, chatID int64, from *tgbotapi.User, args string) {
+	id := strings.TrimSpace(args)
+	if id == "" {
+		b.reply(chatID, "Использование: /restoreBackup <id> (список — /backups)")
+		return
+	}
+
+	bk, err := b.backups.Restore(ctx, id, originOf(from, "restoreBackup"))
+	if err != nil {
+		b.reply(chatID, "❌ "+err.Error())
+		return
+	}
+	b.reply(chatID, fmt.Sprintf("✅ Бэкап %s (%s) восстановлен, контрольная сумма сошлась. Перезапусти сервер для применения.", bk.ID, bk.SaveName))
 }
 
 // ── upload save command (/uploadSave) ─────────────────────────────────────────
@@ -324,31 +1690,95 @@ type webAppKeyboard struct {
 
 // ── upload save (document sent directly to chat) ──────────────────────────────
 
-func (b *Bot) handleUploadSave(chatID int64, doc *tgbotapi.Document) {
+func (b *Bot) handleUploadSave(chatID int64, from *tgbotapi.User, doc *tgbotapi.Document) {
 	if !strings.HasSuffix(strings.ToLower(doc.FileName), ".zip") {
 		b.reply(chatID, "❌ Ожидается .zip файл сохранения")
 		return
 	}
+	if limit := b.saves.MaxSaveSize(); limit > 0 && int64(doc.FileSize) > limit {
+		b.reply(chatID, fmt.Sprintf("❌ Файл слишком большой: %s, максимум %s", formatSize(int64(doc.FileSize)), formatSize(limit)))
+		return
+	}
 
 	b.reply(chatID, "📥 Загружаю файл...")
 
-	data, err := b.downloadTelegramFile(doc.FileID)
+	body, err := b.openTelegramFile(doc.FileID)
 	if err != nil {
 		b.reply(chatID, "❌ Ошибка загрузки: "+err.Error())
 		return
 	}
+	defer body.Close()
 
-	if err := b.saves.Replace(doc.FileName, data); err != nil {
-		b.reply(chatID, "❌ Ошибка записи: "+err.Error())
+	saved, err := b.saves.Replace(doc.FileName, body, originOf(from, "telegram"))
+	if err != nil {
+		var (
+			invalid      *saves.ValidationError
+			incompatible *saves.IncompatibleError
+		)
+		switch {
+		case errors.As(err, &invalid):
+			b.reply(chatID, "❌ Файл не похож на сейв Factorio: "+invalid.Reason)
+		case errors.As(err, &incompatible):
+			b.reply(chatID, "❌ Сейв несовместим с сервером:\n• "+strings.Join(incompatible.Reasons, "\n• "))
+		default:
+			b.reply(chatID, "❌ Ошибка записи: "+err.Error())
+		}
 		return
 	}
 
-	b.reply(chatID, fmt.Sprintf("✅ Сохранение «%s» загружено. Перезапусти сервер для применения.", doc.FileName))
+	text := fmt.Sprintf("✅ Сохранение «%s» загружено. Перезапусти сервер для применения.", saved.Name)
+	if saved.Unchecked != "" {
+		text += "\n\n⚠️ " + saved.Unchecked
+	}
+	b.reply(chatID, text)
 }
 
 // ── helpers ───────────────────────────────────────────────────────────────────
 
-func (b *Bot) downloadTelegramFile(fileID string) ([]byte, error) {
+// originOf identifies a Telegram user as the author of a change to the saves dir.
+func originOf(from *tgbotapi.User, source string) saves.Origin {
+	actor := "tg"
+	if from != nil {
+		actor = fmt.Sprintf("tg:%d", from.ID)
+		if from.UserName != "" {
+			actor += " @" + from.UserName
+		}
+	}
+	return saves.Origin{Actor: actor, Source: source}
+}
+
+// formatSize renders a byte count as KB/MB/GB.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	default:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+}
+
+// rconErrorText turns a typed RCON error into a user-facing message.
+func rconErrorText(err error) string {
+	var (
+		connErr *rcon.ConnectError
+		authErr *rcon.AuthError
+	)
+	switch {
+	case errors.As(err, &connErr):
+		return "🔴 Сервер недоступен по RCON: " + connErr.Err.Error()
+	case errors.As(err, &authErr):
+		return "🔑 RCON отклонил пароль — перезапусти сервер, чтобы он подхватил новый"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "⏳ Сервер не ответил вовремя"
+	default:
+		return "❌ " + err.Error()
+	}
+}
+
+// openTelegramFile starts downloading a file sent to the bot; the caller streams and closes the body.
+func (b *Bot) openTelegramFile(fileID string) (io.ReadCloser, error) {
 	fileConfig := tgbotapi.FileConfig{FileID: fileID}
 	file, err := b.api.GetFile(fileConfig)
 	if err != nil {
@@ -360,13 +1790,11 @@ func (b *Bot) downloadTelegramFile(fileID string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("downloading file: %w", err)
 	}
-	defer resp.Body.Close()
-
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading file: %s", resp.Status)
 	}
 
-	log.Printf("downloaded file %s: %d bytes", fileID, len(data))
-	return data, nil
+	log.Printf("downloading file %s: %d bytes", fileID, resp.ContentLength)
+	return resp.Body, nil
 }
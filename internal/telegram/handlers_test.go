@@ -0,0 +1,215 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"perezvonish/factorio-server-manager/internal/factorio/mods"
+	"perezvonish/factorio-server-manager/internal/factorio/rcon"
+	"perezvonish/factorio-server-manager/internal/factorio/status"
+)
+
+// fakeTelegram answers the Bot API methods the handlers use and records sent texts.
+type fakeTelegram struct {
+	mu    sync.Mutex
+	texts []string
+	edits []string
+}
+
+func (f *fakeTelegram) Do(req *http.Request) (*http.Response, error) {
+	body := `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1}}}`
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/getMe"):
+		body = `{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot"}}`
+	case strings.HasSuffix(req.URL.Path, "/sendMessage"):
+		if err := req.ParseForm(); err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		f.texts = append(f.texts, req.PostForm.Get("text"))
+		f.mu.Unlock()
+	case strings.HasSuffix(req.URL.Path, "/editMessageText"):
+		if err := req.ParseForm(); err != nil {
+			return nil, err
+		}
+		f.mu.Lock()
+		f.edits = append(f.edits, req.PostForm.Get("text"))
+		f.mu.Unlock()
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body)), Header: make(http.Header)}, nil
+}
+
+func (f *fakeTelegram) replies() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
+
+func (f *fakeTelegram) edited() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.edits...)
+}
+
+// newTestBot builds a Bot around a fake Telegram API and a fake RCON executor.
+func newTestBot(t *testing.T) (*Bot, *fakeTelegram, *rcon.FakeExecutor) {
+	t.Helper()
+	tg := &fakeTelegram{}
+	api, err := tgbotapi.NewBotAPIWithClient("token", "http://telegram/bot%s/%s", tg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := rcon.NewFakeExecutor()
+	return &Bot{api: api, rcon: exec, edits: make(map[string]settingsEdit), tasks: make(map[string]context.CancelFunc)}, tg, exec
+}
+
+func TestRconHandlersReply(t *testing.T) {
+	b, tg, exec := newTestBot(t)
+	exec.Responses["/players online"] = "Online players (1):\n  engineer (online)"
+	exec.Responses["/time"] = "1 hour"
+
+	b.handlePlayers(1)
+	b.handleTime(1)
+	b.handleCmd(1, "/c game.print(1)")
+
+	want := []string{"👥 Online players (1):\n  engineer (online)", "⏱ 1 hour", "✅ Выполнено"}
+	if got := tg.replies(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("replies = %q, want %q", got, want)
+	}
+	if calls := exec.Calls(); len(calls) != 3 || calls[2] != "/c game.print(1)" {
+		t.Errorf("rcon calls = %q", calls)
+	}
+}
+
+func TestRconHandlersReportTypedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "connect",
+			err:  &rcon.ConnectError{Addr: "factorio:27015", Err: syscall.ECONNREFUSED},
+			want: "🔴 Сервер недоступен по RCON: connection refused",
+		},
+		{
+			name: "auth",
+			err:  &rcon.AuthError{Err: errors.New("authentication failed")},
+			want: "🔑 RCON отклонил пароль — перезапусти сервер, чтобы он подхватил новый",
+		},
+		{
+			name: "timeout",
+			err:  &rcon.ExecError{Command: "/server-save", Err: context.DeadlineExceeded},
+			want: "⏳ Сервер не ответил вовремя",
+		},
+		{
+			name: "exec",
+			err:  &rcon.ExecError{Command: "/server-save", Err: io.ErrUnexpectedEOF},
+			want: `❌ RCON exec "/server-save": unexpected EOF`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg, exec := newTestBot(t)
+			exec.Err = tt.err
+
+			b.handleSave(1)
+
+			if got := tg.replies(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("replies = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// newModsManager returns a mods.Manager whose mod-list.json holds only base, so an
+// update finishes without talking to the portal.
+func newModsManager(t *testing.T) *mods.Manager {
+	t.Helper()
+	dir := t.TempDir()
+	list := filepath.Join(dir, "mod-list.json")
+	if err := os.WriteFile(list, []byte(`{"mods":[{"name":"base","enabled":true}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return mods.NewManager(dir, list, filepath.Join(dir, "mod-lock.json"), "user", "token", "2.0")
+}
+
+// waitReplies polls until tg has sent n messages.
+func waitReplies(t *testing.T, tg *fakeTelegram, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := tg.replies()
+		if len(got) >= n || time.Now().After(deadline) {
+			return got
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestModsUpdateWaitsForEmptyServer(t *testing.T) {
+	b, tg, exec := newTestBot(t)
+	b.mods = newModsManager(t)
+	b.modsUpdateOnlyWhenEmpty = true
+	exec.Responses["/players online count"] = "Online players (2):"
+
+	b.handleModsUpdate(context.Background(), 1, nil, nil)
+	if got := tg.replies(); len(got) != 1 || !strings.HasPrefix(got[0], "⏸ На сервере 2 игрок(ов)") {
+		t.Fatalf("replies = %q, want the update queued", got)
+	}
+
+	online := status.Result{Game: status.Probe{Checked: true, OK: true}}
+	b.OnStatusCheck(online)
+	if got := tg.replies(); len(got) != 1 {
+		t.Fatalf("replies = %q, want the update still waiting for players to leave", got)
+	}
+
+	exec.Responses["/players online count"] = "Online players (0):"
+	b.OnStatusCheck(online)
+	got := waitReplies(t, tg, 4)
+	want := []string{"▶️ Сервер опустел — запускаю отложенный /mods update", "⬇️ Обновляю моды...", "✅ Все моды актуальны"}
+	if strings.Join(got[1:], "|") != strings.Join(want, "|") {
+		t.Errorf("replies = %q, want %q after the queued ones", got[1:], want)
+	}
+
+	// Очередь опустела: следующая проверка ничего не запускает.
+	b.OnStatusCheck(online)
+	if n := len(tg.replies()); n != 4 {
+		t.Errorf("%d replies, want no second run", n)
+	}
+}
+
+func TestModsUpdateQueuedRunsWhenServerDown(t *testing.T) {
+	b, tg, exec := newTestBot(t)
+	b.mods = newModsManager(t)
+	b.modsUpdateOnlyWhenEmpty = true
+	exec.Responses["/players online count"] = "Online players (1):"
+
+	b.handleModsUpdate(context.Background(), 1, nil, nil)
+	b.handleModsUpdate(context.Background(), 1, nil, []string{"base"})
+	if got := tg.replies(); len(got) != 2 || !strings.Contains(got[1], "заменён") {
+		t.Fatalf("replies = %q, want the second request to replace the first", got)
+	}
+
+	calls := len(exec.Calls())
+	b.OnStatusCheck(status.Result{Game: status.Probe{Checked: true, Err: errors.New("no handshake reply")}})
+	// Запущенное обновление отвечает ещё дважды: «обновляю» и результат.
+	if got := waitReplies(t, tg, 5); len(got) != 5 || got[2] != "▶️ Сервер опустел — запускаю отложенный /mods update" {
+		t.Errorf("replies = %q, want the queued update run", got)
+	}
+	if n := len(exec.Calls()); n != calls {
+		t.Errorf("asked RCON for players of a stopped server")
+	}
+}
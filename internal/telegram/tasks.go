@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Keys of background tasks: one task per key runs at a time.
+const (
+	taskServer = "server" // остановка, запуск, перезапуск, новая карта
+	taskMods   = "mods"
+	taskBackup = "backup"
+)
+
+// runTask runs fn in its own goroutine, so the update loop keeps answering other
+// commands while a long operation (map generation, mod downloads, a restart) is in
+// progress. Only one task per key runs at a time: a second one is refused with a reply.
+// ctx is cancelled by cancelTask.
+func (b *Bot) runTask(chatID int64, key string, fn func(ctx context.Context)) bool {
+	b.tasksMu.Lock()
+	if _, running := b.tasks[key]; running {
+		b.tasksMu.Unlock()
+		b.reply(chatID, "⏳ Предыдущая операция ещё выполняется — дождись её окончания")
+		return false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.tasks[key] = cancel
+	b.tasksMu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			b.tasksMu.Lock()
+			delete(b.tasks, key)
+			b.tasksMu.Unlock()
+		}()
+		fn(ctx)
+	}()
+	return true
+}
+
+// taskRunning reports whether a task is running under key.
+func (b *Bot) taskRunning(key string) bool {
+	b.tasksMu.Lock()
+	defer b.tasksMu.Unlock()
+	_, ok := b.tasks[key]
+	return ok
+}
+
+// cancelTask stops the task running under key and reports whether there was one.
+func (b *Bot) cancelTask(key string) bool {
+	b.tasksMu.Lock()
+	defer b.tasksMu.Unlock()
+	cancel, ok := b.tasks[key]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// liveMessage is a chat message that a background task edits to report its progress,
+// instead of sending a new message for every step.
+type liveMessage struct {
+	b         *Bot
+	chatID    int64
+	parseMode string
+
+	mu    sync.Mutex
+	msgID int
+	lines []string
+	text  string // последний отправленный текст
+}
+
+// newLiveMessage sends first and returns the message for later edits.
+func (b *Bot) newLiveMessage(chatID int64, first string) *liveMessage {
+	m := &liveMessage{b: b, chatID: chatID}
+	m.add(first)
+	return m
+}
+
+// add appends a line to the message.
+func (m *liveMessage) add(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lines = append(m.lines, line)
+	m.showLocked(strings.Join(m.lines, "\n"))
+}
+
+// set replaces the whole text of the message.
+func (m *liveMessage) set(text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lines = []string{text}
+	m.showLocked(text)
+}
+
+func (m *liveMessage) showLocked(text string) {
+	// Telegram отвергает правку, которая ничего не меняет.
+	if text == m.text {
+		return
+	}
+	if m.msgID == 0 {
+		msg := tgbotapi.NewMessage(m.chatID, text)
+		msg.ParseMode = m.parseMode
+		sent, err := m.b.api.Send(msg)
+		if err != nil {
+			log.Printf("live message send error: %v", err)
+			return
+		}
+		m.msgID, m.text = sent.MessageID, text
+		return
+	}
+	edit := tgbotapi.NewEditMessageText(m.chatID, m.msgID, text)
+	edit.ParseMode = m.parseMode
+	if _, err := m.b.api.Send(edit); err != nil {
+		log.Printf("live message edit error: %v", err)
+		return
+	}
+	m.text = text
+}
@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"perezvonish/factorio-server-manager/internal/domain"
+)
+
+// fakeContainer blocks Stop until release is closed and streams lines to FollowLogs.
+type fakeContainer struct {
+	release chan struct{}
+	lines   chan string
+}
+
+func (c *fakeContainer) Start(context.Context) error { return nil }
+
+func (c *fakeContainer) Stop(ctx context.Context) error {
+	select {
+	case <-c.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *fakeContainer) Status(context.Context) (domain.ContainerStatus, error) {
+	return domain.ContainerStatus{State: domain.ContainerRunning}, nil
+}
+
+func (c *fakeContainer) Logs(context.Context, int) ([]string, error) { return nil, nil }
+
+func (c *fakeContainer) FollowLogs(ctx context.Context, _ int, fn func(line string)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case line := <-c.lines:
+			fn(line)
+		}
+	}
+}
+
+// command builds an update with a command from an allowed user.
+func command(text string) tgbotapi.Update {
+	cmd, _, _ := strings.Cut(text, " ")
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		From:     &tgbotapi.User{ID: 7},
+		Chat:     &tgbotapi.Chat{ID: 1},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(cmd)}},
+	}}
+}
+
+// waitTask polls until the task under key has finished.
+func waitTask(t *testing.T, b *Bot, key string) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if !b.taskRunning(key) {
+			return
+		}
+	}
+	t.Fatalf("task %q is still running", key)
+}
+
+func TestLongCommandRunsInBackground(t *testing.T) {
+	b, tg, exec := newTestBot(t)
+	container := &fakeContainer{release: make(chan struct{})}
+	b.container = container
+	b.allowedUsers = map[int64]struct{}{7: {}}
+	exec.Responses["/players online"] = "Online players (0):"
+
+	b.handleUpdate(command("/stop"))
+	// Пока контейнер останавливается, бот отвечает на другие команды.
+	b.handleUpdate(command("/players"))
+	b.handleUpdate(command("/stop"))
+	got := waitReplies(t, tg, 3)
+	if len(got) != 3 || !strings.Contains(strings.Join(got, "|"), "👥 Online players (0):") ||
+		!strings.Contains(strings.Join(got, "|"), "⏳ Предыдущая операция ещё выполняется") {
+		t.Fatalf("replies = %q, want /players answered and the second /stop refused", got)
+	}
+
+	close(container.release)
+	waitTask(t, b, taskServer)
+	if got := tg.replies(); got[len(got)-1] != "✅ Контейнер остановлен" {
+		t.Errorf("replies = %q, want the stop reported", got)
+	}
+	b.handleUpdate(command("/stop"))
+	if got := waitReplies(t, tg, 6); got[len(got)-1] != "✅ Контейнер остановлен" {
+		t.Errorf("replies = %q, want /stop accepted after the first one finished", got)
+	}
+}
+
+func TestLogsFollowEditsOneMessage(t *testing.T) {
+	b, tg, _ := newTestBot(t)
+	container := &fakeContainer{lines: make(chan string)}
+	b.container = container
+	b.allowedUsers = map[int64]struct{}{7: {}}
+
+	b.handleUpdate(command("/logs follow"))
+	container.lines <- "Info ServerMultiplayerManager.cpp: joined"
+	container.lines <- "Info ServerMultiplayerManager.cpp: left"
+	b.handleUpdate(command("/logs follow"))
+	b.handleUpdate(command("/logs stop"))
+	waitTask(t, b, logsTaskKey(1))
+
+	got := tg.replies()
+	if len(got) != 2 || !strings.HasPrefix(got[0], "📡 Слежу за логом") || !strings.Contains(got[1], "⏳ Предыдущая операция") {
+		t.Fatalf("replies = %q, want one live message and the second follow refused", got)
+	}
+	edits := tg.edited()
+	want := "Info ServerMultiplayerManager.cpp: joined\nInfo ServerMultiplayerManager.cpp: left\n\n⏹ Слежение за логом остановлено"
+	if len(edits) == 0 || edits[len(edits)-1] != want {
+		t.Errorf("edits = %q, want the last one %q", edits, want)
+	}
+
+	b.handleUpdate(command("/logs stop"))
+	if got := tg.replies(); got[len(got)-1] != "Слежение за логом не запущено" {
+		t.Errorf("replies = %q, want /logs stop without a follow reported", got)
+	}
+}
@@ -0,0 +1,300 @@
+package webapp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/factorio/saves"
+)
+
+// Resumable upload protocol used by static/upload.html:
+//
+//	POST /upload/init     {"name","size","id"?} → {"id","chunk_size","offset"}
+//	PUT  /upload/chunk?id=…&offset=…   body: chunk, X-Chunk-SHA256: hex → {"offset"}
+//	POST /upload/complete?id=…          → {"status","filename"}
+//
+// A client that lost its connection calls init again with the id it got before and
+// continues from the returned offset. Chunks must arrive in order; a chunk whose
+// checksum does not match is discarded and has to be resent.
+const (
+	uploadChunkSize = 4 << 20
+	// uploadTTL — через сколько бездействия незавершённая загрузка удаляется.
+	uploadTTL = 24 * time.Hour
+)
+
+// upload is an in-progress chunked upload, spooled to a part file in uploadDir.
+type upload struct {
+	mu       sync.Mutex
+	id       string
+	userID   int64
+	name     string
+	size     int64
+	offset   int64
+	path     string
+	lastSeen time.Time
+}
+
+type uploadInitRequest struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+type uploadInitResponse struct {
+	ID        string `json:"id"`
+	ChunkSize int64  `json:"chunk_size"`
+	Offset    int64  `json:"offset"`
+}
+
+// handleUploadInit starts a new upload or resumes an existing one.
+func (s *Server) handleUploadInit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	var req uploadInitRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4<<10)).Decode(&req); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := saves.SanitizeFilename(req.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Size <= 0 {
+		http.Error(w, "size must be positive", http.StatusBadRequest)
+		return
+	}
+	if limit := s.saves.MaxSaveSize(); limit > 0 && req.Size > limit {
+		http.Error(w, fmt.Sprintf("file is larger than %d MB", limit>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	s.expireUploads()
+
+	if req.ID != "" {
+		if u := s.lookupUpload(req.ID, userID); u != nil && u.name == req.Name && u.size == req.Size {
+			u.mu.Lock()
+			u.lastSeen = time.Now()
+			offset := u.offset
+			u.mu.Unlock()
+			log.Printf("webapp: user %d resumes upload %s at %d/%d", userID, u.id, offset, u.size)
+			writeJSON(w, uploadInitResponse{ID: u.id, ChunkSize: uploadChunkSize, Offset: offset})
+			return
+		}
+	}
+
+	u, err := s.newUpload(userID, req.Name, req.Size)
+	if err != nil {
+		http.Error(w, "upload error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, uploadInitResponse{ID: u.id, ChunkSize: uploadChunkSize})
+}
+
+// handleUploadChunk appends one chunk at the current offset after verifying its SHA-256.
+func (s *Server) handleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	u := s.lookupUpload(r.URL.Query().Get("id"), userID)
+	if u == nil {
+		http.Error(w, "unknown upload", http.StatusNotFound)
+		return
+	}
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		http.Error(w, "bad offset", http.StatusBadRequest)
+		return
+	}
+	want, err := hex.DecodeString(r.Header.Get("X-Chunk-SHA256"))
+	if err != nil || len(want) != sha256.Size {
+		http.Error(w, "missing or malformed X-Chunk-SHA256", http.StatusBadRequest)
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.lastSeen = time.Now()
+
+	if offset != u.offset {
+		// Клиент и сервер разошлись (например, ответ на прошлый кусок потерялся) — сообщаем, откуда продолжать.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]int64{"offset": u.offset}) //nolint:errcheck
+		return
+	}
+
+	f, err := os.OpenFile(u.path, os.O_WRONLY, 0)
+	if err != nil {
+		http.Error(w, "upload error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	limit := min(uploadChunkSize, u.size-u.offset)
+	n, err := io.Copy(io.MultiWriter(io.NewOffsetWriter(f, u.offset), h), io.LimitReader(r.Body, limit))
+	if err == nil && !bytes.Equal(h.Sum(nil), want) {
+		err = errors.New("chunk checksum mismatch")
+	}
+	if err != nil || n == 0 {
+		// Отбрасываем недописанный кусок, чтобы файл заканчивался ровно на u.offset.
+		f.Truncate(u.offset) //nolint:errcheck
+		if err == nil {
+			err = errors.New("empty chunk")
+		}
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := f.Sync(); err != nil {
+		f.Truncate(u.offset) //nolint:errcheck
+		http.Error(w, "upload error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	u.offset += n
+	writeJSON(w, map[string]int64{"offset": u.offset})
+}
+
+// handleUploadComplete hands a fully received upload to saves.Replace.
+func (s *Server) handleUploadComplete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	u := s.lookupUpload(r.URL.Query().Get("id"), userID)
+	if u == nil {
+		http.Error(w, "unknown upload", http.StatusNotFound)
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.offset != u.size {
+		http.Error(w, fmt.Sprintf("upload incomplete: %d of %d bytes", u.offset, u.size), http.StatusConflict)
+		return
+	}
+
+	f, err := os.Open(u.path)
+	if err != nil {
+		http.Error(w, "upload error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	origin := saves.Origin{Actor: fmt.Sprintf("tg:%d", userID), Source: "webapp"}
+	saved, err := s.saves.Replace(u.name, f, origin)
+	// Отклонённый сейв докачивать бессмысленно — загрузка закрывается в любом случае.
+	s.removeUpload(u)
+	if err != nil {
+		writeReplaceError(w, err)
+		return
+	}
+
+	log.Printf("webapp: user %d uploaded save %q as %q (%d bytes, chunked)", userID, u.name, saved.Name, u.size)
+	writeJSON(w, replacedResponse(saved))
+}
+
+func (s *Server) newUpload(userID int64, name string, size int64) (*upload, error) {
+	if err := os.MkdirAll(s.uploadDir, 0755); err != nil {
+		return nil, err
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(b[:])
+	path := filepath.Join(s.uploadDir, id+".part")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	u := &upload{id: id, userID: userID, name: name, size: size, path: path, lastSeen: time.Now()}
+	s.uploadsMu.Lock()
+	s.uploads[id] = u
+	s.uploadsMu.Unlock()
+
+	log.Printf("webapp: user %d starts upload %s: %q, %d bytes", userID, id, name, size)
+	return u, nil
+}
+
+// lookupUpload returns the upload id if it belongs to userID.
+func (s *Server) lookupUpload(id string, userID int64) *upload {
+	s.uploadsMu.Lock()
+	defer s.uploadsMu.Unlock()
+	if u, ok := s.uploads[id]; ok && u.userID == userID {
+		return u
+	}
+	return nil
+}
+
+func (s *Server) removeUpload(u *upload) {
+	s.uploadsMu.Lock()
+	delete(s.uploads, u.id)
+	s.uploadsMu.Unlock()
+	os.Remove(u.path)
+}
+
+// expireUploads drops uploads that have been idle for longer than uploadTTL.
+func (s *Server) expireUploads() {
+	s.uploadsMu.Lock()
+	var stale []*upload
+	for _, u := range s.uploads {
+		if u.mu.TryLock() {
+			if time.Since(u.lastSeen) > uploadTTL {
+				stale = append(stale, u)
+			}
+			u.mu.Unlock()
+		}
+	}
+	s.uploadsMu.Unlock()
+
+	for _, u := range stale {
+		log.Printf("webapp: upload %s expired", u.id)
+		s.removeUpload(u)
+	}
+}
+
+// cleanUploadDir removes part files left over from a previous run: sessions live in
+// memory, so they cannot be resumed after a restart.
+func (s *Server) cleanUploadDir() {
+	parts, _ := filepath.Glob(filepath.Join(s.uploadDir, "*.part"))
+	for _, p := range parts {
+		os.Remove(p)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) //nolint:errcheck
+}
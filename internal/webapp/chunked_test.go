@@ -0,0 +1,283 @@
+package webapp
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"perezvonish/factorio-server-manager/internal/factorio/saves"
+)
+
+const testBotToken = "123:token"
+
+// signInitData builds WebApp initData for userID the way Telegram signs it.
+func signInitData(userID int64) string {
+	vals := url.Values{
+		"auth_date": {"1760000000"},
+		"user":      {fmt.Sprintf(`{"id":%d,"first_name":"Test"}`, userID)},
+	}
+	var parts []string
+	for k := range vals {
+		parts = append(parts, k+"="+vals.Get(k))
+	}
+	sort.Strings(parts)
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(testBotToken))
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	vals.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+	return vals.Encode()
+}
+
+// testSaveZip is a minimal save that passes saves.Validate.
+func testSaveZip(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"world/control.lua", "world/level.dat0"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(strings.Repeat(name, 20)))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadClient talks the chunked upload protocol to a test server as one Telegram user.
+type uploadClient struct {
+	t      *testing.T
+	url    string
+	userID int64
+}
+
+func newUploadServer(t *testing.T) (*Server, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	savesDir := filepath.Join(dir, "saves")
+	if err := os.Mkdir(savesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	allowed := map[int64]struct{}{1: {}, 2: {}}
+	s := NewServer(testBotToken, allowed, saves.NewManager(savesDir, filepath.Join(dir, "snapshots"), nil), filepath.Join(dir, "uploads"), nil, nil)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upload/init", s.handleUploadInit)
+	mux.HandleFunc("/upload/chunk", s.handleUploadChunk)
+	mux.HandleFunc("/upload/complete", s.handleUploadComplete)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return s, ts.URL, savesDir
+}
+
+func (c *uploadClient) do(method, path string, body []byte, header http.Header, out any) int {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if c.userID != 0 {
+		req.Header.Set("X-Telegram-Init-Data", signInitData(c.userID))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if out != nil && strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(data, out); err != nil {
+			c.t.Fatalf("%s %s: %v in %q", method, path, err, data)
+		}
+	}
+	return resp.StatusCode
+}
+
+func (c *uploadClient) init(id, name string, size int) (int, uploadInitResponse) {
+	body, _ := json.Marshal(uploadInitRequest{ID: id, Name: name, Size: int64(size)})
+	var resp uploadInitResponse
+	return c.do(http.MethodPost, "/upload/init", body, nil, &resp), resp
+}
+
+// chunk sends data at offset; sum overrides its SHA-256 when not empty.
+func (c *uploadClient) chunk(id string, offset int, data []byte, sum string) (int, int64) {
+	if sum == "" {
+		h := sha256.Sum256(data)
+		sum = hex.EncodeToString(h[:])
+	}
+	var resp struct {
+		Offset int64 `json:"offset"`
+	}
+	code := c.do(http.MethodPut, fmt.Sprintf("/upload/chunk?id=%s&offset=%d", id, offset), data,
+		http.Header{"X-Chunk-Sha256": {sum}}, &resp)
+	return code, resp.Offset
+}
+
+func (c *uploadClient) complete(id string) (int, map[string]string) {
+	var resp map[string]string
+	return c.do(http.MethodPost, "/upload/complete?id="+id, nil, nil, &resp), resp
+}
+
+func TestChunkedUploadResume(t *testing.T) {
+	s, base, savesDir := newUploadServer(t)
+	c := &uploadClient{t: t, url: base, userID: 1}
+	data := testSaveZip(t)
+	half := len(data) / 2
+
+	code, up := c.init("", "world.zip", len(data))
+	if code != http.StatusOK || up.ID == "" || up.Offset != 0 || up.ChunkSize != uploadChunkSize {
+		t.Fatalf("init: %d %+v", code, up)
+	}
+	if code, offset := c.chunk(up.ID, 0, data[:half], ""); code != http.StatusOK || offset != int64(half) {
+		t.Fatalf("first chunk: %d, offset %d", code, offset)
+	}
+
+	// Обрыв связи: клиент переспрашивает по старому id и продолжает с выданного смещения.
+	code, resumed := c.init(up.ID, "world.zip", len(data))
+	if code != http.StatusOK || resumed.ID != up.ID || resumed.Offset != int64(half) {
+		t.Fatalf("resume: %d %+v, want offset %d", code, resumed, half)
+	}
+	// Другое имя или размер — это уже новая загрузка.
+	if _, other := c.init(up.ID, "world.zip", len(data)+1); other.ID == up.ID || other.Offset != 0 {
+		t.Errorf("init with another size resumed %+v", other)
+	}
+
+	if code, offset := c.chunk(up.ID, half, data[half:], ""); code != http.StatusOK || offset != int64(len(data)) {
+		t.Fatalf("second chunk: %d, offset %d", code, offset)
+	}
+	code, done := c.complete(up.ID)
+	if code != http.StatusOK || done["filename"] != "world.zip" {
+		t.Fatalf("complete: %d %v", code, done)
+	}
+	got, err := os.ReadFile(filepath.Join(savesDir, "world.zip"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("saved file differs from the upload (%v)", err)
+	}
+	if s.lookupUpload(up.ID, 1) != nil {
+		t.Error("completed upload is still open")
+	}
+	if code, _ := c.chunk(up.ID, len(data), []byte("x"), ""); code != http.StatusNotFound {
+		t.Errorf("chunk after complete: %d, want 404", code)
+	}
+}
+
+func TestChunkedUploadOffsetMismatch(t *testing.T) {
+	_, base, _ := newUploadServer(t)
+	c := &uploadClient{t: t, url: base, userID: 1}
+	data := testSaveZip(t)
+
+	_, up := c.init("", "world.zip", len(data))
+	c.chunk(up.ID, 0, data[:10], "")
+
+	// Ответ на первый кусок потерялся, клиент шлёт его повторно.
+	code, offset := c.chunk(up.ID, 0, data[:10], "")
+	if code != http.StatusConflict || offset != 10 {
+		t.Errorf("stale chunk: %d, offset %d; want 409 with offset 10", code, offset)
+	}
+	if code, offset := c.chunk(up.ID, 20, data[20:30], ""); code != http.StatusConflict || offset != 10 {
+		t.Errorf("chunk ahead: %d, offset %d; want 409 with offset 10", code, offset)
+	}
+}
+
+func TestChunkedUploadChecksumMismatchTruncates(t *testing.T) {
+	s, base, _ := newUploadServer(t)
+	c := &uploadClient{t: t, url: base, userID: 1}
+	data := testSaveZip(t)
+
+	_, up := c.init("", "world.zip", len(data))
+	c.chunk(up.ID, 0, data[:10], "")
+
+	bad := sha256.Sum256([]byte("something else"))
+	if code, _ := c.chunk(up.ID, 10, data[10:40], hex.EncodeToString(bad[:])); code != http.StatusUnprocessableEntity {
+		t.Fatalf("corrupt chunk: %d, want 422", code)
+	}
+	info, err := os.Stat(s.lookupUpload(up.ID, 1).path)
+	if err != nil || info.Size() != 10 {
+		t.Fatalf("part file after a corrupt chunk: %v, %v; want 10 bytes", info, err)
+	}
+	if code, offset := c.chunk(up.ID, 10, data[10:], ""); code != http.StatusOK || offset != int64(len(data)) {
+		t.Errorf("resent chunk: %d, offset %d", code, offset)
+	}
+	if code, _ := c.complete(up.ID); code != http.StatusOK {
+		t.Errorf("complete: %d", code)
+	}
+}
+
+func TestChunkedUploadBelongsToUser(t *testing.T) {
+	_, base, _ := newUploadServer(t)
+	owner := &uploadClient{t: t, url: base, userID: 1}
+	other := &uploadClient{t: t, url: base, userID: 2}
+	data := testSaveZip(t)
+
+	_, up := owner.init("", "world.zip", len(data))
+	if code, _ := other.chunk(up.ID, 0, data, ""); code != http.StatusNotFound {
+		t.Errorf("chunk from another user: %d, want 404", code)
+	}
+	if code, _ := other.complete(up.ID); code != http.StatusNotFound {
+		t.Errorf("complete from another user: %d, want 404", code)
+	}
+	if _, theirs := other.init(up.ID, "world.zip", len(data)); theirs.ID == up.ID {
+		t.Error("another user resumed the upload")
+	}
+	if code, offset := owner.chunk(up.ID, 0, data, ""); code != http.StatusOK || offset != int64(len(data)) {
+		t.Errorf("owner chunk: %d, offset %d", code, offset)
+	}
+}
+
+func TestChunkedUploadCompleteIncomplete(t *testing.T) {
+	_, base, savesDir := newUploadServer(t)
+	c := &uploadClient{t: t, url: base, userID: 1}
+	data := testSaveZip(t)
+
+	_, up := c.init("", "world.zip", len(data))
+	c.chunk(up.ID, 0, data[:10], "")
+	if code, _ := c.complete(up.ID); code != http.StatusConflict {
+		t.Fatalf("complete of a partial upload: %d, want 409", code)
+	}
+	if _, err := os.Stat(filepath.Join(savesDir, "world.zip")); !os.IsNotExist(err) {
+		t.Errorf("partial upload reached the saves dir: %v", err)
+	}
+	// Загрузка не закрыта — её можно докачать.
+	if _, resumed := c.init(up.ID, "world.zip", len(data)); resumed.Offset != 10 {
+		t.Errorf("resume after a failed complete: %+v", resumed)
+	}
+}
+
+func TestChunkedUploadAuthorization(t *testing.T) {
+	_, base, _ := newUploadServer(t)
+	body, _ := json.Marshal(uploadInitRequest{Name: "world.zip", Size: 100})
+
+	anonymous := &uploadClient{t: t, url: base}
+	if code := anonymous.do(http.MethodPost, "/upload/init", body, nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("without initData: %d, want 401", code)
+	}
+	forged := anonymous.do(http.MethodPost, "/upload/init", body,
+		http.Header{"X-Telegram-Init-Data": {strings.Replace(signInitData(1), "%3A1%2C", "%3A2%2C", 1)}}, nil)
+	if forged != http.StatusUnauthorized {
+		t.Errorf("tampered initData: %d, want 401", forged)
+	}
+	stranger := &uploadClient{t: t, url: base, userID: 3}
+	if code := stranger.do(http.MethodPost, "/upload/init", body, nil, nil); code != http.StatusForbidden {
+		t.Errorf("user outside the allowed list: %d, want 403", code)
+	}
+}
@@ -0,0 +1,249 @@
+package webapp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/safefile"
+)
+
+// DownloadLinks issues and redeems short-lived, single-use links to a save file.
+// Links are signed with a key derived from the bot token, so they stay valid across
+// bot restarts until they expire. A link is used up once the whole file has been
+// delivered; with LoadUsed the redeemed links survive restarts too.
+type DownloadLinks struct {
+	key     []byte
+	baseURL string
+	ttl     time.Duration
+
+	mu       sync.Mutex
+	used     map[string]time.Time // nonce → срок действия ссылки
+	usedFile string               // "" — список использованных ссылок только в памяти
+}
+
+// NewDownloadLinks creates links under baseURL (the public WebApp address) that
+// expire after ttl. With an empty baseURL Link always fails.
+func NewDownloadLinks(botToken, baseURL string, ttl time.Duration) *DownloadLinks {
+	// Отдельный ключ, чтобы подпись ссылки нельзя было спутать с подписью initData.
+	mac := hmac.New(sha256.New, []byte("DownloadLink"))
+	mac.Write([]byte(botToken))
+	return &DownloadLinks{
+		key:     mac.Sum(nil),
+		baseURL: strings.TrimRight(baseURL, "/"),
+		ttl:     ttl,
+		used:    make(map[string]time.Time),
+	}
+}
+
+// LoadUsed reads the redeemed links from file and keeps it up to date from now on,
+// so a used link does not work again after a bot restart. A missing file is not an error.
+func (l *DownloadLinks) LoadUsed(file string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.usedFile = file
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading used download links: %w", err)
+	}
+	if err := json.Unmarshal(data, &l.used); err != nil {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+	l.pruneLocked(time.Now())
+	return nil
+}
+
+// Link returns a download URL for the named save and the moment it stops working.
+func (l *DownloadLinks) Link(name string) (string, time.Time, error) {
+	if l.baseURL == "" {
+		return "", time.Time{}, errors.New("WEBAPP_URL is not set")
+	}
+	var b [12]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", time.Time{}, err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b[:])
+	expires := time.Now().Add(l.ttl).Truncate(time.Second)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+
+	q := url.Values{
+		"name":  {name},
+		"exp":   {exp},
+		"nonce": {nonce},
+		"sig":   {l.sign(name, exp, nonce)},
+	}
+	return l.baseURL + "/download?" + q.Encode(), expires, nil
+}
+
+// link is a download link that passed check.
+type link struct {
+	name    string
+	nonce   string
+	expires time.Time
+}
+
+// check verifies the signature and expiry of a link and that it has not been used.
+func (l *DownloadLinks) check(q url.Values, now time.Time) (link, error) {
+	name, exp, nonce, sig := q.Get("name"), q.Get("exp"), q.Get("nonce"), q.Get("sig")
+	if !hmac.Equal([]byte(sig), []byte(l.sign(name, exp, nonce))) {
+		return link{}, errors.New("invalid signature")
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return link{}, errors.New("invalid expiry")
+	}
+	expires := time.Unix(unix, 0)
+	if now.After(expires) {
+		return link{}, errors.New("link expired")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.used[nonce]; ok {
+		return link{}, errors.New("link already used")
+	}
+	return link{name: name, nonce: nonce, expires: expires}, nil
+}
+
+// redeem marks k as used and persists the list if LoadUsed was called.
+func (l *DownloadLinks) redeem(k link, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pruneLocked(now)
+	l.used[k.nonce] = k.expires
+	if l.usedFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(l.used)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.usedFile), 0755); err != nil {
+		return fmt.Errorf("creating used download links dir: %w", err)
+	}
+	if err := safefile.WriteFile(l.usedFile, data, 0644); err != nil {
+		return fmt.Errorf("writing used download links: %w", err)
+	}
+	return nil
+}
+
+// pruneLocked forgets links that have expired anyway.
+func (l *DownloadLinks) pruneLocked(now time.Time) {
+	for n, e := range l.used {
+		if now.After(e) {
+			delete(l.used, n)
+		}
+	}
+}
+
+func (l *DownloadLinks) sign(name, exp, nonce string) string {
+	mac := hmac.New(sha256.New, l.key)
+	fmt.Fprintf(mac, "%s\n%s\n%s", name, exp, nonce)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// handleDownload serves the save named by a signed link. The link is used up only
+// when a response has delivered the file through to its last byte: HEAD requests
+// (link previews, download managers probing the size), a save that cannot be opened,
+// partial Range requests and interrupted downloads leave it working, so a browser
+// can resume until the link expires.
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.links == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	k, err := s.links.check(r.URL.Query(), time.Now())
+	if err != nil {
+		log.Printf("webapp: download from %s rejected: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	f, err := s.saves.Open(k.name)
+	if err != nil {
+		http.Error(w, "save not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		log.Printf("webapp: download of %q (%d bytes, range %q) to %s", k.name, info.Size(), r.Header.Get("Range"), r.RemoteAddr)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", k.name))
+	dw := &deliveryWriter{ResponseWriter: w}
+	http.ServeContent(dw, r, k.name, info.ModTime(), f)
+
+	if r.Method == http.MethodGet && dw.deliveredEnd(info.Size()) {
+		if err := s.links.redeem(k, time.Now()); err != nil {
+			log.Printf("webapp: download link for %q: %v", k.name, err)
+		}
+	}
+}
+
+// deliveryWriter records the status and the number of body bytes of a response.
+type deliveryWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *deliveryWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *deliveryWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// deliveredEnd reports whether the response carried a file of size bytes through to
+// its last byte: the whole file, or a single range ending at the end of the file.
+// Multipart ranges never count.
+func (w *deliveryWriter) deliveredEnd(size int64) bool {
+	switch w.status {
+	case http.StatusOK:
+		return w.written == size
+	case http.StatusPartialContent:
+		var start, end, total int64
+		if _, err := fmt.Sscanf(w.Header().Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			return false
+		}
+		return total == size && end == size-1 && w.written == end-start+1
+	}
+	return false
+}
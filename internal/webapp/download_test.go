@@ -0,0 +1,212 @@
+package webapp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"perezvonish/factorio-server-manager/internal/factorio/saves"
+)
+
+const testSave = "0123456789abcdef" // содержимое сейва world.zip
+
+// newDownloadServer serves /download for a saves dir holding world.zip.
+func newDownloadServer(t *testing.T) (*Server, *httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	savesDir := filepath.Join(dir, "saves")
+	if err := os.Mkdir(savesDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(savesDir, "world.zip"), []byte(testSave), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer("token", nil, saves.NewManager(savesDir, filepath.Join(dir, "snapshots"), nil), t.TempDir(), nil, nil)
+	ts := httptest.NewServer(http.HandlerFunc(s.handleDownload))
+	t.Cleanup(ts.Close)
+	s.links = NewDownloadLinks("token", ts.URL, time.Minute)
+	return s, ts, savesDir
+}
+
+func fetch(t *testing.T, method, link, rangeHeader string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, link, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// waitUsed waits until the handler has redeemed link: the client can read the last
+// byte before the handler gets to mark the link used.
+func waitUsed(t *testing.T, l *DownloadLinks, link string) {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if _, err := l.check(u.Query(), time.Now()); err != nil {
+			return
+		}
+	}
+	t.Fatal("link was not redeemed")
+}
+
+func TestDownloadLinkIsSingleUse(t *testing.T) {
+	s, _, _ := newDownloadServer(t)
+	link, _, err := s.links.Link("world.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := fetch(t, http.MethodHead, link, ""); code != http.StatusOK {
+		t.Fatalf("HEAD: %d", code)
+	}
+	if code, body := fetch(t, http.MethodGet, link, ""); code != http.StatusOK || body != testSave {
+		t.Fatalf("GET: %d %q", code, body)
+	}
+	waitUsed(t, s.links, link)
+	if code, body := fetch(t, http.MethodGet, link, ""); code != http.StatusForbidden || !strings.Contains(body, "already used") {
+		t.Errorf("second GET: %d %q, want 403", code, body)
+	}
+}
+
+func TestDownloadLinkSurvivesPartialRequests(t *testing.T) {
+	s, _, _ := newDownloadServer(t)
+	link, _, err := s.links.Link("world.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Докачка: первая часть не тратит ссылку, часть до конца файла — тратит.
+	if code, body := fetch(t, http.MethodGet, link, "bytes=0-5"); code != http.StatusPartialContent || body != testSave[:6] {
+		t.Fatalf("first range: %d %q", code, body)
+	}
+	if code, _ := fetch(t, http.MethodGet, link, "bytes=0-1,4-5"); code != http.StatusPartialContent {
+		t.Fatalf("multipart range: %d", code)
+	}
+	if code, body := fetch(t, http.MethodGet, link, "bytes=6-"); code != http.StatusPartialContent || body != testSave[6:] {
+		t.Fatalf("last range: %d %q", code, body)
+	}
+	waitUsed(t, s.links, link)
+	if code, _ := fetch(t, http.MethodGet, link, "bytes=0-5"); code != http.StatusForbidden {
+		t.Errorf("after the file was delivered: %d, want 403", code)
+	}
+}
+
+func TestDownloadLinkMissingSaveIsNotConsumed(t *testing.T) {
+	s, _, savesDir := newDownloadServer(t)
+	link, _, err := s.links.Link("later.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := fetch(t, http.MethodGet, link, ""); code != http.StatusNotFound {
+		t.Fatalf("GET of a missing save: %d", code)
+	}
+
+	if err := os.WriteFile(filepath.Join(savesDir, "later.zip"), []byte(testSave), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := fetch(t, http.MethodGet, link, ""); code != http.StatusOK || body != testSave {
+		t.Errorf("GET after the save appeared: %d %q", code, body)
+	}
+}
+
+func TestDownloadLinkRejectsTampering(t *testing.T) {
+	s, _, _ := newDownloadServer(t)
+	link, _, err := s.links.Link("world.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for param, value := range map[string]string{
+		"name":  "other.zip",
+		"exp":   "9999999999",
+		"nonce": "fresh",
+		"sig":   "",
+	} {
+		q := u.Query()
+		q.Set(param, value)
+		forged := *u
+		forged.RawQuery = q.Encode()
+		if code, body := fetch(t, http.MethodGet, forged.String(), ""); code != http.StatusForbidden || !strings.Contains(body, "invalid signature") {
+			t.Errorf("%s changed: %d %q, want 403", param, code, body)
+		}
+	}
+
+	other := NewDownloadLinks("another token", u.Scheme+"://"+u.Host, time.Minute)
+	foreign, _, err := other.Link("world.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := fetch(t, http.MethodGet, foreign, ""); code != http.StatusForbidden {
+		t.Errorf("link signed with another token: %d, want 403", code)
+	}
+}
+
+func TestDownloadLinkExpires(t *testing.T) {
+	l := NewDownloadLinks("token", "https://example.com", time.Minute)
+	link, expires, err := l.Link("world.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.check(u.Query(), expires.Add(-time.Second)); err != nil {
+		t.Errorf("before expiry: %v", err)
+	}
+	if _, err := l.check(u.Query(), expires.Add(time.Second)); err == nil || err.Error() != "link expired" {
+		t.Errorf("after expiry: %v, want link expired", err)
+	}
+}
+
+func TestDownloadLinkStaysUsedAfterRestart(t *testing.T) {
+	s, ts, _ := newDownloadServer(t)
+	usedFile := filepath.Join(t.TempDir(), "bot", "used.json")
+	if err := s.links.LoadUsed(usedFile); err != nil {
+		t.Fatal(err)
+	}
+	link, _, err := s.links.Link("world.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := fetch(t, http.MethodGet, link, ""); code != http.StatusOK {
+		t.Fatalf("GET: %d", code)
+	}
+	waitUsed(t, s.links, link)
+
+	// Рестарт бота: новые DownloadLinks с тем же токеном читают список использованных.
+	s.links = NewDownloadLinks("token", ts.URL, time.Minute)
+	if err := s.links.LoadUsed(usedFile); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := fetch(t, http.MethodGet, link, ""); code != http.StatusForbidden {
+		t.Errorf("GET after restart: %d, want 403", code)
+	}
+}
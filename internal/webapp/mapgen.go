@@ -0,0 +1,180 @@
+package webapp
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"perezvonish/factorio-server-manager/internal/factorio/mapgen"
+)
+
+//go:embed static/mapgen.html
+var mapgenHTML []byte
+
+// Map settings form used by static/mapgen.html:
+//
+//	GET  /mapgen/settings → {"fields":[{kind,path,label,type,min,max,value}]}
+//	POST /mapgen/settings   {"values":{"<path>": <json value>}} → {"status":"ok"}
+//
+// A POST is applied to both files or to none: on any invalid value the response is
+// 400 with {"errors":{"<path>":"…"}} and nothing is written. Problems that cannot be
+// tied to a submitted path are under "".
+
+type mapgenField struct {
+	mapgen.Field
+	Value json.RawMessage `json:"value"`
+}
+
+// handleMapgenPage serves the settings form.
+func (s *Server) handleMapgenPage(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(mapgenHTML) //nolint:errcheck
+}
+
+func (s *Server) handleMapgenSettings(w http.ResponseWriter, r *http.Request) {
+	if s.mapgen == nil {
+		http.NotFound(w, r)
+		return
+	}
+	userID, ok := s.authorize(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		s.getMapgenSettings(w)
+	case http.MethodPost:
+		s.postMapgenSettings(w, r, userID)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) getMapgenSettings(w http.ResponseWriter) {
+	docs, err := s.mapgenDocuments()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var fields []mapgenField
+	for _, f := range mapgen.FormFields() {
+		value := json.RawMessage("null")
+		if v, err := docs[f.Kind].Get(f.Path); err == nil {
+			value = json.RawMessage(v)
+		}
+		fields = append(fields, mapgenField{Field: f, Value: value})
+	}
+	writeJSON(w, map[string]any{"fields": fields})
+}
+
+func (s *Server) postMapgenSettings(w http.ResponseWriter, r *http.Request, userID int64) {
+	var req struct {
+		Values map[string]json.RawMessage `json:"values"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	docs, err := s.mapgenDocuments()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	problems := make(map[string]string)
+	byKind := make(map[mapgen.Kind]map[string]string)
+	for path, value := range req.Values {
+		kind, ok := mapgen.KindOf(path, docs[mapgen.KindMapGen], docs[mapgen.KindMap])
+		if !ok {
+			problems[path] = "unknown setting"
+			continue
+		}
+		if err := docs[kind].CheckPath(path); err != nil {
+			problems[path] = err.Error()
+			continue
+		}
+		if byKind[kind] == nil {
+			byKind[kind] = make(map[string]string)
+		}
+		byKind[kind][path] = string(value)
+	}
+	// Все значения файла применяются разом: проверки вида min ≤ max видят новую пару целиком.
+	if len(problems) == 0 {
+		for kind, values := range byKind {
+			if err := docs[kind].SetAll(values); err != nil {
+				attributeProblems(problems, values, err)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]any{"errors": problems})
+		return
+	}
+
+	for kind := range byKind {
+		if err := docs[kind].Save(); err != nil {
+			http.Error(w, "saving "+string(kind)+": "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	log.Printf("webapp: user %d changed %d map settings", userID, len(req.Values))
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// attributeProblems files the problems of a failed SetAll under the submitted paths
+// they mention; the rest go under "" and are shown for the whole form.
+func attributeProblems(problems map[string]string, values map[string]string, err error) {
+	var invalid *mapgen.ValidationError
+	if !errors.As(err, &invalid) {
+		problems[""] = err.Error()
+		return
+	}
+	for _, problem := range invalid.Problems {
+		matched := false
+		for path := range values {
+			if !mentions(problem, path) {
+				continue
+			}
+			matched = true
+			if problems[path] != "" {
+				problems[path] += "; "
+			}
+			problems[path] += problem
+		}
+		if !matched {
+			if problems[""] != "" {
+				problems[""] += "; "
+			}
+			problems[""] += problem
+		}
+	}
+}
+
+// mentions reports whether a validation problem is about path, either spelled out in
+// full or, as in "a.min is greater than max", by its last segment after the section.
+func mentions(problem, path string) bool {
+	if strings.Contains(problem, path) {
+		return true
+	}
+	i := strings.LastIndex(path, ".")
+	return i > 0 && strings.HasPrefix(problem, path[:i+1]) && strings.Contains(problem, path[i+1:])
+}
+
+func (s *Server) mapgenDocuments() (map[mapgen.Kind]*mapgen.Document, error) {
+	docs := make(map[mapgen.Kind]*mapgen.Document)
+	for _, kind := range []mapgen.Kind{mapgen.KindMapGen, mapgen.KindMap} {
+		doc, err := s.mapgen.Document(kind)
+		if err != nil {
+			return nil, err
+		}
+		docs[kind] = doc
+	}
+	return docs, nil
+}
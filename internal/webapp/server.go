@@ -6,15 +6,19 @@ import (
 	_ "embed"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 
+	"perezvonish/factorio-server-manager/internal/factorio/mapgen"
 	"perezvonish/factorio-server-manager/internal/factorio/saves"
 )
 
@@ -27,13 +31,25 @@ type Server struct {
 	allowedUsers map[int64]struct{}
 	saves        *saves.Manager
 	ready        atomic.Bool // true after initial SyncMods completes
+
+	uploadDir string // part-файлы докачиваемых загрузок
+	uploadsMu sync.Mutex
+	uploads   map[string]*upload
+
+	links *DownloadLinks // nil — /download отключён
+
+	mapgen *mapgen.Generator // nil — форма настроек карты отключена
 }
 
-func NewServer(botToken string, allowedUsers map[int64]struct{}, saves *saves.Manager) *Server {
+func NewServer(botToken string, allowedUsers map[int64]struct{}, saves *saves.Manager, uploadDir string, links *DownloadLinks, mapGen *mapgen.Generator) *Server {
 	return &Server{
 		botToken:     botToken,
 		allowedUsers: allowedUsers,
 		saves:        saves,
+		uploadDir:    uploadDir,
+		uploads:      make(map[string]*upload),
+		links:        links,
+		mapgen:       mapGen,
 	}
 }
 
@@ -46,6 +62,13 @@ func (s *Server) ListenAndServe(addr string) error {
 	mux.HandleFunc("/health", s.handleHealth)
 	mux.HandleFunc("/", s.handleIndex)
 	mux.HandleFunc("/upload", s.handleUpload)
+	mux.HandleFunc("/upload/init", s.handleUploadInit)
+	mux.HandleFunc("/upload/chunk", s.handleUploadChunk)
+	mux.HandleFunc("/upload/complete", s.handleUploadComplete)
+	mux.HandleFunc("/download", s.handleDownload)
+	mux.HandleFunc("/mapgen", s.handleMapgenPage)
+	mux.HandleFunc("/mapgen/settings", s.handleMapgenSettings)
+	s.cleanUploadDir()
 	log.Printf("webapp: listening on %s", addr)
 	return http.ListenAndServe(addr, mux)
 }
@@ -68,7 +91,8 @@ func (s *Server) handleIndex(w http.ResponseWriter, _ *http.Request) {
 	w.Write(uploadHTML) //nolint:errcheck
 }
 
-// handleUpload accepts a multipart POST with a "save" file field.
+// handleUpload accepts a multipart POST with a "save" file field in a single request.
+// The WebApp page uses the resumable protocol in chunked.go instead.
 // The request must carry a valid Telegram initData in X-Telegram-Init-Data header.
 func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodOptions {
@@ -82,56 +106,122 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// ── auth ──────────────────────────────────────────────────────────────
-	initData := r.Header.Get("X-Telegram-Init-Data")
-	userID, ok := s.validateInitData(initData)
+	userID, ok := s.authorize(w, r)
 	if !ok {
-		log.Printf("webapp: invalid initData from %s", r.RemoteAddr)
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-	if _, allowed := s.allowedUsers[userID]; !allowed {
-		log.Printf("webapp: user %d is not in allowed list", userID)
-		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
 
-	// ── parse file ────────────────────────────────────────────────────────
-	if err := r.ParseMultipartForm(500 << 20); err != nil { // 500 MB max
-		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
-		return
+	// ── stream file ───────────────────────────────────────────────────────
+	// Сейв читается прямо из тела запроса в saves.Replace, без буфера в памяти.
+	if limit := s.saves.MaxSaveSize(); limit > 0 {
+		// Запас на заголовки multipart; точный размер файла проверит saves.Validate.
+		r.Body = http.MaxBytesReader(w, r.Body, limit+1<<20)
 	}
-
-	file, header, err := r.FormFile("save")
+	part, err := savePart(r)
 	if err != nil {
-		http.Error(w, "missing file field: "+err.Error(), http.StatusBadRequest)
+		http.Error(w, "bad request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
+	defer part.Close()
 
-	if !strings.HasSuffix(strings.ToLower(header.Filename), ".zip") {
+	if !strings.HasSuffix(strings.ToLower(part.FileName()), ".zip") {
 		http.Error(w, "only .zip files are allowed", http.StatusBadRequest)
 		return
 	}
 
-	data, err := io.ReadAll(file)
+	origin := saves.Origin{Actor: fmt.Sprintf("tg:%d", userID), Source: "webapp"}
+	counted := &countingReader{r: part}
+	saved, err := s.saves.Replace(part.FileName(), counted, origin)
 	if err != nil {
-		http.Error(w, "read error: "+err.Error(), http.StatusInternalServerError)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("file is larger than %d MB", s.saves.MaxSaveSize()>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		writeReplaceError(w, err)
 		return
 	}
 
-	if err := s.saves.Replace(header.Filename, data); err != nil {
+	log.Printf("webapp: user %d uploaded save %q as %q (%d bytes)", userID, part.FileName(), saved.Name, counted.n)
+
+	writeJSON(w, replacedResponse(saved))
+}
+
+// replacedResponse is the JSON reply to a successful upload; "warning" is set when
+// the compatibility check could not be done in full.
+func replacedResponse(saved saves.Replaced) map[string]string {
+	resp := map[string]string{"status": "ok", "filename": saved.Name}
+	if saved.Unchecked != "" {
+		resp["warning"] = saved.Unchecked
+	}
+	return resp
+}
+
+// authorize checks the Telegram initData in X-Telegram-Init-Data and that the user is
+// allowed. On failure it writes the response and returns false.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	initData := r.Header.Get("X-Telegram-Init-Data")
+	userID, ok := s.validateInitData(initData)
+	if !ok {
+		log.Printf("webapp: invalid initData from %s", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+	if _, allowed := s.allowedUsers[userID]; !allowed {
+		log.Printf("webapp: user %d is not in allowed list", userID)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return 0, false
+	}
+	return userID, true
+}
+
+// writeReplaceError maps a saves.Replace error to an HTTP status.
+func writeReplaceError(w http.ResponseWriter, err error) {
+	var (
+		invalid      *saves.ValidationError
+		incompatible *saves.IncompatibleError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &incompatible):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
 		http.Error(w, "save error: "+err.Error(), http.StatusInternalServerError)
-		return
 	}
+}
+
+// savePart returns the "save" file field of a multipart request without buffering it.
+func savePart(r *http.Request) (*multipart.Part, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("missing file field \"save\"")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "save" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
 
-	log.Printf("webapp: user %d uploaded save %q (%d bytes)", userID, header.Filename, len(data))
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{ //nolint:errcheck
-		"status":   "ok",
-		"filename": header.Filename,
-	})
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // ── Telegram WebApp initData validation ──────────────────────────────────────